@@ -18,40 +18,210 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/stefanprodan/kustomizer/pkg/dependency"
+	"github.com/stefanprodan/kustomizer/pkg/drift"
+	"github.com/stefanprodan/kustomizer/pkg/fetch"
 	"github.com/stefanprodan/kustomizer/pkg/inventory"
 	"github.com/stefanprodan/kustomizer/pkg/registry"
+	"github.com/stefanprodan/kustomizer/pkg/snapshot"
 )
 
 var applyInventoryCmd = &cobra.Command{
 	Use:     "inventory",
 	Aliases: []string{"inv"},
 	Short:   "Apply builds the given inventory, then it validates and reconciles the Kubernetes objects using server-side apply.",
-	Example: `  kustomizer apply inventory <name> [-a] [-p] [-f] -k --prune --wait --force --source --revision
+	Long: `The apply command builds the given inventory, then it validates and reconciles the Kubernetes objects using server-side apply.
+Before applying an object, the apply command performs a server-side dry-run and compares the result with the in-cluster object,
+the objects that have not drifted are reported as unchanged and are not applied, to avoid bumping their resource version.
+The objects annotated with 'kustomizer.dev/depends-on: [<kind>/]<namespace>/<name>' are applied after the referenced objects
+become ready, the references are comma separated and the namespace is omitted for cluster-scoped objects.
+The references must target objects applied by the same inventory and, when using --stage-label, by the same or a previous stage.`,
+	Example: `  kustomizer apply inventory [name] [-a] [-p] [-f] -k --prune --wait --force --source --revision
+
+  # Apply a local kustomize overlay with the inventory name derived from the overlay directory e.g. 'prod'
+  kustomizer apply inventory -n apps -k ./overlays/prod
 
   # Apply an inventory from remote OCI artifacts
   kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:latest
 
+  # Apply an inventory from the newest OCI artifact in the 1.x release channel
+  kustomizer apply inventory my-app -n apps -a 'oci://registry/org/repo:^1.0'
+
+  # Apply an inventory from an OCI artifact after verifying its signature with cosign
+  kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:v1.0.0 --verify --cosign-key ./keys/cosign.pub
+
+  # Apply an inventory from an OCI artifact containing SOPS encrypted Secrets, decrypting them with the local age key
+  SOPS_AGE_KEY_FILE=./keys/age.txt kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:v1.0.0 --sops
+
+  # Apply the production variant of a bundle artifact
+  kustomizer apply inventory my-app -n apps -a 'oci://registry/org/repo:v1.0.0#production'
+
+  # Apply an inventory using the digests locked with 'kustomizer lock -a oci://registry/org/repo:v1.0.0 -o ./deploy/kustomizer.lock'
+  kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:v1.0.0 --lock-file ./deploy/kustomizer.lock
+
+  # Apply a locked artifact together with an artifact that is resolved from the registry
+  kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:v1.0.0 -a oci://registry/org/addons:latest --lock-file ./deploy/kustomizer.lock --allow-unlocked
+
   # Apply an inventory using an OCI artifact digest
   kustomizer apply inventory my-app -n apps -a oci://registry/org/repo@sha256:<digest>
 
+  # Apply only artifacts pinned to digests
+  kustomizer apply inventory my-app -n apps -a oci://registry/org/repo@sha256:<digest> --require-digest
+
   # Apply an inventory from an encrypted OCI artifact
   kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:latest --age-identities ./keys/id.txt
 
+  # Apply an inventory from OCI artifacts encrypted for different teams
+  kustomizer apply inventory my-app -n apps -a oci://registry/org/app:latest -a oci://registry/org/db:latest --decrypt-identity ./keys/app.txt --decrypt-identity ./keys/db.txt
+
   # Apply an inventory from remote OCI artifacts and local patches
   kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:latest -p ./patches/safe-to-evict.yaml
 
   # Force apply a local kustomize overlay then wait for all resources to become ready
   kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --wait --force
 
+  # Apply a local kustomize overlay using ten parallel workers
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --concurrency 10
+
+  # Apply Kubernetes YAML manifests read from stdin
+  helm template my-app ./charts/my-app | kustomizer apply inventory my-app -n apps -f -
+
+  # Apply a kustomize overlay from a Git repository tag
+  kustomizer apply inventory my-app -n apps --git-url https://github.com/org/repo --git-ref v1.0.0 --git-path ./deploy/prod
+
+  # Preview the changes of a local kustomize overlay without mutating the cluster
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --dry-run
+
+  # Apply a local kustomize overlay and print the change set as JSON
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod -o json
+
+  # Apply only the objects labeled with 'app=frontend' from a local kustomize overlay
+  kustomizer apply inventory my-app-frontend -n apps -k ./overlays/prod -l app=frontend
+
+  # Apply a local kustomize overlay without the Secrets
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --exclude kind=Secret
+
+  # Apply Kubernetes YAML manifests where the Deployment is annotated with 'kustomizer.dev/depends-on: Job/apps/db-migration',
+  # the Deployment is applied after the Job completes
+  kustomizer apply inventory my-app -n apps -f ./deploy/manifests
+
+  # Apply a local kustomize overlay and retry the failed applies up to 5 times
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --retries 5 --retry-interval 5s
+
+  # Preview the changes of a local kustomize overlay and ask for confirmation before applying them
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --confirm
+
+  # Apply a local kustomize overlay and roll back the changes if the apply fails
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --atomic
+
+  # Apply a local kustomize overlay and create the namespaces that don't exist
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --create-namespace
+
+  # Apply a local kustomize overlay in a different namespace than the one set in the manifests
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --target-namespace apps-preview --create-namespace
+
+  # Apply a local kustomize overlay using a dedicated field manager
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --field-manager team-a
+
+  # Skip an object from apply and prune while keeping it in the inventory
+  kubectl -n apps annotate deployment/my-app kustomizer.dev/reconcile=disabled
+
+  # Apply a local kustomize overlay and take ownership of the objects managed by Helm
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --adopt helm
+
+  # Apply a local kustomize overlay and reapply it every time the overlay files change
+  kustomizer apply inventory my-app -n apps -k ./overlays/dev --prune --watch
+
+  # Apply an inventory from remote OCI artifacts every 5 minutes, correcting drift and pruning stale objects
+  kustomizer apply inventory my-app -n apps -a oci://registry/org/repo:latest --prune --interval 5m
+
+  # Apply a local kustomize overlay and prune only the stale Deployments, Services and ConfigMaps
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --prune-allowlist Deployment,Service,ConfigMap
+
+  # Preview the changes of a local kustomize overlay including the stale objects that would be pruned
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --dry-run
+
+  # Apply a local kustomize overlay giving each object at most 30 seconds to be applied
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --timeout 10m --resource-timeout 30s
+
+  # Apply a local kustomize overlay in stages ordered by the 'rollout-stage' label value
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --stage-label rollout-stage --atomic
+
+  # Apply a local kustomize overlay using client-side apply
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --server-side=false
+
+  # Resume a failed apply, skipping the objects applied before the failure
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --resume
+
+  # Apply a local kustomize overlay and set a common label and annotation on all objects
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --label team=dev --annotation owner=dev@example.com
+
+  # Apply a second instance of an OCI artifact in the same namespace by adding a suffix to the object names
+  kustomizer apply inventory my-app-v2 -n apps -a oci://registry/org/repo:latest --name-suffix -v2
+
+  # Apply a local kustomize overlay and exit with code 2 if any object was changed
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --exit-code
+
+  # Apply a local kustomize overlay and prune stale objects only if there are at most 50 changes
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --max-changes 50
+
+  # Apply a local kustomize overlay ignoring the replicas drift caused by autoscaling
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --ignore-paths /spec/replicas
+
+  # Apply a local kustomize overlay and record the inventory in an Inventory custom resource (the CRD is installed if missing)
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --inventory-kind inventory
+
+  # Apply a local kustomize overlay and keep the last 20 revisions in the inventory history
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --history-limit 20
+
+  # Apply a local kustomize overlay and record the manifests for disaster recovery
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --store-manifests
+
+  # Apply a local kustomize overlay and take over the inventory lock if it wasn't renewed for more than 30 minutes
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --lock-timeout 30m
+
+  # Apply a local kustomize overlay as part of the platform group, after the cert-manager inventory
+  kustomizer apply inventory ingress -n platform -k ./overlays/ingress --group platform --depends-on cert-manager
+
+  # Apply a preview environment that expires one week after the last apply
+  kustomizer apply inventory pr-123 -n previews -k ./overlays/preview --inventory-ttl 168h
+
+  # Apply a local kustomize overlay and prune the objects removed from it, even from namespaces it no longer targets
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --allow-cross-namespace-prune
+
+  # Apply a local kustomize overlay, sign the inventory and verify its signature before pruning
+  kubectl -n apps create secret generic inventory-signing-key --from-literal=key="$(openssl rand -hex 32)"
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --signing-secret inventory-signing-key
+
+  # Sign an inventory applied before signing was enabled, trusting its current entries for this apply
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --signing-secret inventory-signing-key --sign-existing
+
+  # Apply a local kustomize overlay and record who owns the inventory
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --set-meta description="payments prod",owner=team-payments
+
   # Apply Kubernetes YAML manifests from a locally cloned Git repository
   kustomizer apply inventory my-app -n apps -f ./deploy/manifests --source="$(git ls-remote --get-url)" --revision="$(git describe --always)"
 `,
@@ -70,17 +240,73 @@ type applyInventoryFlags struct {
 	revision        string
 	createNamespace bool
 	ageIdentities   string
+	decryptIdentity []string
+	sops            bool
+	dryRun          bool
+	httpHeaders     []string
+	httpToken       string
+	httpChecksums   []string
+	gitURL          string
+	gitRef          string
+	gitPath         string
+	concurrency     int
+	output          string
+	selector        string
+	include         []string
+	exclude         []string
+	retries         int
+	retryInterval   time.Duration
+	confirm         bool
+	atomic          bool
+	targetNamespace string
+	fieldManager    string
+	forceConflicts  bool
+	adopt           []string
+	watch           bool
+	interval        time.Duration
+	pruneAllowlist  []string
+	resourceTimeout time.Duration
+	cascade         string
+	stageLabel      string
+	serverSide      bool
+	resume          bool
+	labels          []string
+	annotations     []string
+	namePrefix      string
+	nameSuffix      string
+	exitCode        bool
+	maxChanges      int
+	ignorePaths     []string
+	historyLimit    int
+	storeManifests  bool
+	lockTimeout     time.Duration
+	group           string
+	dependsOn       []string
+	inventoryTTL    time.Duration
+	setMeta         []string
+	allowCrossNS    bool
+	signingSecret   string
+	signExisting    bool
+	lockFile        string
+	allowUnlocked   bool
+	requireDigest   bool
+	verify          bool
+	verifyKey       string
+	certIdentity    string
+	certOIDCIssuer  string
+	derivedFrom     string
 }
 
 var applyInventoryArgs applyInventoryFlags
 
 func init() {
 	applyInventoryCmd.Flags().StringSliceVarP(&applyInventoryArgs.filename, "filename", "f", nil,
-		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively.")
+		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively. Use '-' to read from stdin or an HTTP(S) URL to download the manifests, the downloads are limited to 50MiB.")
 	applyInventoryCmd.Flags().StringVarP(&applyInventoryArgs.kustomize, "kustomize", "k", "",
 		"Path to a directory that contains a kustomization.yaml.")
 	applyInventoryCmd.Flags().StringSliceVarP(&applyInventoryArgs.artifact, "artifact", "a", nil,
-		"OCI artifact URL in the format 'oci://registry/org/repo:tag' e.g. 'oci://docker.io/stefanprodan/app-deploy:v1.0.0'.")
+		"OCI artifact URL in the format 'oci://registry/org/repo:tag' e.g. 'oci://docker.io/stefanprodan/app-deploy:v1.0.0', "+
+			"the tag can be a semantic version constraint e.g. 'oci://docker.io/stefanprodan/app-deploy:^1.0'.")
 	applyInventoryCmd.Flags().StringSliceVarP(&applyInventoryArgs.patch, "patch", "p", nil,
 		"Path to a kustomization file that contains a list of patches.")
 	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.wait, "wait", false, "Wait for the applied Kubernetes objects to become ready.")
@@ -88,24 +314,222 @@ func init() {
 	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.prune, "prune", false, "Delete stale objects from the cluster.")
 	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.source, "source", "", "The URL to the source code.")
 	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.revision, "revision", "", "The revision identifier.")
-	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.createNamespace, "create-namespace", false, "Create the inventory namespace if not present.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.createNamespace, "create-namespace", false,
+		"Create the inventory namespace and the namespaces of the applied objects if not present, the objects namespaces are recorded in the inventory.")
 	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	applyInventoryCmd.Flags().StringSliceVar(&applyInventoryArgs.decryptIdentity, "decrypt-identity", nil,
+		"Path to an age identity file used to decrypt encrypted artifacts, can be specified multiple times.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.sops, "sops", false,
+		"Decrypt the SOPS encrypted objects with the sops binary using the local age, PGP or KMS keys, without this flag encrypted objects are rejected.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.dryRun, "dry-run", false,
+		"Perform a server-side dry-run apply and print the resulting changes, including the objects that would be pruned, without mutating the cluster or the inventory.")
+	applyInventoryCmd.Flags().StringArrayVar(&applyInventoryArgs.httpHeaders, "http-header", nil,
+		"HTTP header in the format 'key: value' added to the requests for manifests hosted at HTTP(S) URLs.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.httpToken, "http-token", "",
+		"Bearer token used to authenticate the requests for manifests hosted at HTTP(S) URLs.")
+	applyInventoryCmd.Flags().StringSliceVar(&applyInventoryArgs.httpChecksums, "http-checksum", nil,
+		"SHA-256 checksum of the manifests hosted at HTTP(S) URLs, specified in the same order as the URLs.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.gitURL, "git-url", "",
+		"Git repository URL to clone, the kustomize overlay at '--git-path' is built and applied.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.gitRef, "git-ref", "",
+		"Git branch, tag or commit SHA to check out, defaults to the remote HEAD.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.gitPath, "git-path", "./",
+		"Path to a directory inside the Git repository that contains a kustomization.yaml.")
+	applyInventoryCmd.Flags().IntVar(&applyInventoryArgs.concurrency, "concurrency", 1,
+		"The number of objects applied in parallel, CRDs and namespaces are always applied first.")
+	applyInventoryCmd.Flags().StringVarP(&applyInventoryArgs.output, "output", "o", "",
+		"Write the change set to stdout in JSON or YAML format, or as a Markdown or HTML report.")
+	applyInventoryCmd.Flags().StringVarP(&applyInventoryArgs.selector, "selector", "l", "",
+		"Label selector e.g. 'app=frontend', only the matching objects are applied and recorded in the inventory.")
+	applyInventoryCmd.Flags().StringArrayVar(&applyInventoryArgs.include, "include", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', only the matching objects are processed. The values can contain shell patterns e.g. 'name=web*'.")
+	applyInventoryCmd.Flags().StringArrayVar(&applyInventoryArgs.exclude, "exclude", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', the matching objects are skipped. The values can contain shell patterns e.g. 'kind=Secret'.")
+	applyInventoryCmd.Flags().IntVar(&applyInventoryArgs.retries, "retries", 0,
+		"The number of times an apply is retried on API throttling, server errors or webhook connection failures.")
+	applyInventoryCmd.Flags().DurationVar(&applyInventoryArgs.retryInterval, "retry-interval", 2*time.Second,
+		"The initial wait between retries, the interval is doubled after each attempt.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.confirm, "confirm", false,
+		"Print the changes computed with a server-side dry-run apply and ask for confirmation before applying them.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.atomic, "atomic", false,
+		"Restore the objects to their previous state and leave the inventory untouched if the apply fails.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.targetNamespace, "target-namespace", "",
+		"Set or override the namespace of all the namespaced objects, cluster-scoped objects are left untouched.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.fieldManager, "field-manager", "",
+		"The name of the manager used to track field ownership, defaults to the field manager set in the config file.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.forceConflicts, "force-conflicts", false,
+		"Take ownership of the fields managed by other field managers, by default the apply fails listing the conflicting managers and fields.")
+	applyInventoryCmd.Flags().StringSliceVar(&applyInventoryArgs.adopt, "adopt", nil,
+		"Transfer the ownership of the fields managed by the given field managers (matched by name prefix) e.g. 'helm', the fields managed by kubectl are always transferred.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.watch, "watch", false,
+		"Watch the local files and directories specified with -k and -f, then rebuild and reapply the inventory on changes until interrupted.")
+	applyInventoryCmd.Flags().DurationVar(&applyInventoryArgs.interval, "interval", 0,
+		"Rebuild and reapply the inventory at the given interval until interrupted, correcting drift and pruning stale objects.")
+	applyInventoryCmd.Flags().StringSliceVar(&applyInventoryArgs.pruneAllowlist, "prune-allowlist", nil,
+		"List of kinds e.g. 'Deployment,Service,ConfigMap' that can be pruned, the stale objects of other kinds are kept in the inventory and can be pruned later with a wider allowlist.")
+	applyInventoryCmd.Flags().DurationVar(&applyInventoryArgs.resourceTimeout, "resource-timeout", 0,
+		"The length of time to wait for the apply or dry-run of a single object, by default only the global timeout applies.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.cascade, "cascade", "background",
+		"The deletion propagation policy used when pruning stale objects, can be background, foreground or orphan.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.stageLabel, "stage-label", "",
+		"Label key used to apply the objects in stages ordered by the label value, each stage must become ready before the next one is applied. The objects without the label are applied first, followed by the stages with integer values in numeric order and then by the other stages in lexical order.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.serverSide, "server-side", true,
+		"Apply the objects with server-side apply, when set to false the objects are patched client-side using the last applied configuration annotation.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.resume, "resume", false,
+		"Skip the objects applied before the previous apply failed, if the build output hasn't changed since.")
+	applyInventoryCmd.Flags().StringArrayVar(&applyInventoryArgs.labels, "label", nil,
+		"Label in the format 'key=value' to set on all objects, can be specified multiple times.")
+	applyInventoryCmd.Flags().StringArrayVar(&applyInventoryArgs.annotations, "annotation", nil,
+		"Annotation in the format 'key=value' to set on all objects, can be specified multiple times.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.namePrefix, "name-prefix", "",
+		"Prefix added to the names of all objects, the references to the renamed objects are updated accordingly.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.nameSuffix, "name-suffix", "",
+		"Suffix added to the names of all objects, the references to the renamed objects are updated accordingly.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.exitCode, "exit-code", false,
+		"Exit with code 2 when objects were created, configured or deleted, and with code 0 when there were no changes.")
+	applyInventoryCmd.Flags().IntVar(&applyInventoryArgs.maxChanges, "max-changes", 0,
+		"Abort the apply if the server-side dry run shows more objects to be created, configured or pruned than the given limit, zero disables the limit.")
+	applyInventoryCmd.Flags().StringSliceVar(&applyInventoryArgs.ignorePaths, "ignore-paths", nil,
+		"List of JSON pointers e.g. '/spec/replicas' to fields excluded from drift detection, the objects that drifted only in these fields are not applied.")
+	applyInventoryCmd.Flags().IntVar(&applyInventoryArgs.historyLimit, "history-limit", inventory.DefaultHistoryLimit,
+		"Maximum number of revisions kept in the inventory history, zero keeps all revisions.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.storeManifests, "store-manifests", false,
+		"Record a gzip compressed copy of the applied manifests in Secrets next to the inventory, enabling rollbacks when the original source is gone.")
+	applyInventoryCmd.Flags().DurationVar(&applyInventoryArgs.lockTimeout, "lock-timeout", 10*time.Minute,
+		"Lock the inventory for the duration of the apply, the lock is renewed while the apply runs and a lock not renewed by another apply for longer than the timeout is considered stale and is taken over, zero disables locking. The first apply to a namespace that doesn't exist yet is not locked.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.group, "group", "",
+		"The name of the group of inventories this inventory belongs to e.g. 'platform'.")
+	applyInventoryCmd.Flags().StringSliceVar(&applyInventoryArgs.dependsOn, "depends-on", nil,
+		"List of inventories in the format '<namespace>/<name>' or '<name>' that this inventory depends on, used to delete a group in dependency order.")
+	applyInventoryCmd.Flags().DurationVar(&applyInventoryArgs.inventoryTTL, "inventory-ttl", 0,
+		"The duration after the last apply when the inventory expires and its objects are deleted by 'kustomizer prune expired', zero disables the expiry.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.allowCrossNS, "allow-cross-namespace-prune", false,
+		"Allow pruning the stale objects from namespaces outside the scope of the inventory, by default the scope is made of the inventory namespace and the namespaces of the applied objects.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.signingSecret, "signing-secret", "",
+		"The name of a Secret in the inventory namespace with the HMAC key stored in the '"+signingKeySecretKey+"' field, used to sign the inventory and to verify it before pruning. An unsigned inventory can be signed by applying it without --prune or with --sign-existing.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.signExisting, "sign-existing", false,
+		"Trust the in-cluster inventory if it's not signed yet and sign it on this apply, the signed inventories that were modified are still rejected.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.lockFile, "lock-file", registry.LockFileName,
+		"Path to the lock file generated with 'kustomizer lock', the OCI artifacts found in the lock file are pulled by their locked digests.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.allowUnlocked, "allow-unlocked", false,
+		"Resolve the OCI artifacts that are not found in the lock file from the registry, by default the artifacts missing from an existing lock file are rejected.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.requireDigest, "require-digest", false,
+		"Reject the OCI artifacts that are not referenced by digest e.g. 'oci://registry/org/repo@sha256:<digest>', either directly or through the lock file.")
+	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.verify, "verify", false,
+		"Verify the signature of the OCI artifacts with cosign before pulling them, unsigned or wrongly signed artifacts are rejected.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.verifyKey, "cosign-key", "",
+		"Path to the consign public key file, KMS URI or Kubernetes Secret. "+
+			"When not specified, cosign verifies the keyless signature using Rekor and the certificate identity constraints.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.certIdentity, "certificate-identity", "",
+		"The identity expected in the keyless signing certificate e.g. 'https://github.com/org/repo/.github/workflows/release.yaml@refs/tags/v1.0.0'.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.certOIDCIssuer, "certificate-oidc-issuer", "",
+		"The OIDC issuer expected in the keyless signing certificate e.g. 'https://token.actions.githubusercontent.com'.")
+	applyInventoryCmd.Flags().StringSliceVar(&applyInventoryArgs.setMeta, "set-meta", nil,
+		"Metadata in the format 'key=value' recorded in the inventory e.g. 'description=\"payments prod\",owner=team-payments', an empty value removes the key.")
 
 	applyCmd.AddCommand(applyInventoryCmd)
 }
 
 func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("you must specify an inventory name")
+	if applyInventoryArgs.kustomize == "" && len(applyInventoryArgs.filename) == 0 && len(applyInventoryArgs.artifact) == 0 &&
+		applyInventoryArgs.gitURL == "" {
+		return fmt.Errorf("-a, -f, -k or --git-url is required")
+	}
+
+	applyInventoryArgs.derivedFrom = ""
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	} else {
+		derivedName, identity, err := deriveInventoryName()
+		if err != nil {
+			return err
+		}
+		name = derivedName
+		applyInventoryArgs.derivedFrom = identity
+		logger.Println(fmt.Sprintf("using inventory name %s derived from %s", name, identity))
+	}
+
+	if applyInventoryArgs.gitURL != "" && applyInventoryArgs.kustomize != "" {
+		return fmt.Errorf("-k and --git-url are mutually exclusive")
+	}
+
+	if applyInventoryArgs.confirm {
+		for _, filename := range applyInventoryArgs.filename {
+			if filename == stdinPath {
+				return fmt.Errorf("--confirm can't be used when reading the manifests from stdin")
+			}
+		}
+	}
+
+	if applyInventoryArgs.signExisting && applyInventoryArgs.signingSecret == "" {
+		return fmt.Errorf("--sign-existing requires --signing-secret")
+	}
+
+	if _, err := drift.ParsePaths(applyInventoryArgs.ignorePaths); err != nil {
+		return err
 	}
-	name := args[0]
 
-	if applyInventoryArgs.kustomize == "" && len(applyInventoryArgs.filename) == 0 && len(applyInventoryArgs.artifact) == 0 {
-		return fmt.Errorf("-a, -f or -k is required")
+	if applyInventoryArgs.watch || applyInventoryArgs.interval > 0 {
+		if applyInventoryArgs.exitCode {
+			return fmt.Errorf("--exit-code can't be used with --watch or --interval")
+		}
+		return watchApplyInventory(name)
+	}
+
+	return applyInventory(name)
+}
+
+// applyInventory builds, applies and records the inventory with the given name.
+func applyInventory(name string) (err error) {
+	selector, err := labels.Parse(applyInventoryArgs.selector)
+	if err != nil {
+		return fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	propagationPolicy, err := parseCascade(applyInventoryArgs.cascade)
+	if err != nil {
+		return err
+	}
+
+	inventoryMeta, err := parseInventoryMeta(applyInventoryArgs.setMeta)
+	if err != nil {
+		return err
+	}
+
+	printer, err := newChangeSetPrinter(applyInventoryArgs.output, func(entry changeSetEntry) {
+		if (applyInventoryArgs.watch || applyInventoryArgs.interval > 0) && entry.Action == string(ssa.UnchangedAction) {
+			return
+		}
+		if applyInventoryArgs.dryRun {
+			logger.Println(entry.Subject, entry.Action, dryRunLabel())
+		} else {
+			logger.Println(entry.Subject, entry.Action)
+		}
+	})
+	if err != nil {
+		return err
 	}
 
-	identities, err := registry.ParseAgeIdentities(applyInventoryArgs.ageIdentities)
+	// print the changes made before the apply failed
+	applyStarted := false
+	defer func() {
+		if err != nil && applyStarted {
+			var applyErr *applyObjectsError
+			if errors.As(err, &applyErr) {
+				for _, f := range applyErr.failures {
+					printer.Fail(f.subject, f.err)
+				}
+			}
+			logger.Println("apply failed after", printer.Summary())
+			for _, f := range printer.Failures() {
+				logger.Println(f)
+			}
+		}
+	}()
+
+	identities, err := registry.ParseAgeIdentities(append([]string{applyInventoryArgs.ageIdentities}, applyInventoryArgs.decryptIdentity...)...)
 	if err != nil {
 		return fmt.Errorf("faild to read decryption keys: %w", err)
 	}
@@ -113,33 +537,204 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
+	kustomizePath := applyInventoryArgs.kustomize
+	source, revision := applyInventoryArgs.source, applyInventoryArgs.revision
+	gitCommit := ""
+	if applyInventoryArgs.gitURL != "" {
+		tmpDir, err := os.MkdirTemp("", "git")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		logger.Println("cloning", applyInventoryArgs.gitURL)
+		sha, err := fetch.CloneGit(ctx, applyInventoryArgs.gitURL, applyInventoryArgs.gitRef, tmpDir)
+		if err != nil {
+			return fmt.Errorf("cloning %s failed: %w", applyInventoryArgs.gitURL, err)
+		}
+
+		gitCommit = sha
+		kustomizePath = filepath.Join(tmpDir, applyInventoryArgs.gitPath)
+		if source == "" {
+			source = applyInventoryArgs.gitURL
+		}
+		if revision == "" {
+			revision = sha
+			if applyInventoryArgs.gitRef != "" {
+				revision = fmt.Sprintf("%s/%s", applyInventoryArgs.gitRef, sha)
+			}
+		}
+	}
+
+	fetcher, err := newHTTPFetcher(applyInventoryArgs.filename, applyInventoryArgs.httpHeaders, applyInventoryArgs.httpToken, applyInventoryArgs.httpChecksums)
+	if err != nil {
+		return err
+	}
+
+	artifacts, err := lockArtifacts(applyInventoryArgs.artifact, applyInventoryArgs.lockFile, applyInventoryArgs.allowUnlocked)
+	if err != nil {
+		return err
+	}
+
+	if applyInventoryArgs.requireDigest {
+		if err := checkDigests(artifacts); err != nil {
+			return err
+		}
+	}
+
+	if applyInventoryArgs.verify {
+		artifacts, err = verifyArtifacts(ctx, artifacts, cosignVerifyOptions{
+			key:            applyInventoryArgs.verifyKey,
+			certIdentity:   applyInventoryArgs.certIdentity,
+			certOIDCIssuer: applyInventoryArgs.certOIDCIssuer,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	logger.Println("building inventory...")
-	objects, digests, err := buildManifests(ctx, applyInventoryArgs.kustomize, applyInventoryArgs.filename, applyInventoryArgs.artifact, applyInventoryArgs.patch, identities)
+	objects, digests, err := buildManifests(ctx, kustomizePath, applyInventoryArgs.filename, artifacts, applyInventoryArgs.patch, identities, fetcher)
+	if err != nil {
+		return err
+	}
+
+	if applyInventoryArgs.sops {
+		if err := decryptSOPS(ctx, objects); err != nil {
+			return err
+		}
+	} else if err := checkSOPSEncrypted(objects); err != nil {
+		return err
+	}
+
+	objects, err = filterObjects(objects, applyInventoryArgs.include, applyInventoryArgs.exclude)
 	if err != nil {
 		return err
 	}
 
+	if applyInventoryArgs.targetNamespace != "" {
+		if err := setTargetNamespace(objects, applyInventoryArgs.targetNamespace); err != nil {
+			return err
+		}
+	}
+
+	if applyInventoryArgs.namePrefix != "" || applyInventoryArgs.nameSuffix != "" {
+		objects, err = renameObjects(objects, applyInventoryArgs.namePrefix, applyInventoryArgs.nameSuffix)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !selector.Empty() {
+		objects = selectObjects(objects, selector)
+		if len(objects) == 0 {
+			return fmt.Errorf("no objects match the label selector '%s'", selector.String())
+		}
+	}
+
+	fieldOwner := newFieldOwner(applyInventoryArgs.fieldManager)
+	resMgr, err := newManager(fieldOwner)
+	if err != nil {
+		return err
+	}
+
+	if applyInventoryArgs.createNamespace {
+		namespaces, err := missingNamespaces(ctx, resMgr, objects)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, namespaces...)
+	}
+
+	if err := decorateObjects(objects, name, *kubeconfigArgs.Namespace, applyInventoryArgs.labels, applyInventoryArgs.annotations); err != nil {
+		return err
+	}
+
 	newInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
-	newInventory.SetSource(applyInventoryArgs.source, applyInventoryArgs.revision, digests)
+	newInventory.SetSource(source, revision, digests)
+	newInventory.Group = applyInventoryArgs.group
+	newInventory.DependsOn = applyInventoryArgs.dependsOn
+	newInventory.TTL = applyInventoryArgs.inventoryTTL
+	newInventory.DerivedFrom = applyInventoryArgs.derivedFrom
+	newInventory.Provenance = newProvenance(applyInventoryArgs.artifact, digests, gitCommit)
 	if err := newInventory.AddObjects(objects); err != nil {
 		return fmt.Errorf("creating inventory failed, error: %w", err)
 	}
+	if newInventory.Namespaces, err = newInventory.NamespaceScope(); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	if newInventory.DerivedFrom != "" {
+		if err := checkDerivedInventoryName(ctx, resMgr, newInventory); err != nil {
+			return err
+		}
+	}
 	logger.Println(fmt.Sprintf("applying %v manifest(s)...", len(objects)))
 
 	for _, object := range objects {
 		fixReplicasConflict(object, objects)
 	}
 
-	resMgr, err := newManager()
+	resMgr.SetOwnerLabels(objects, name, *kubeconfigArgs.Namespace)
+
+	if applyInventoryArgs.dryRun {
+		if _, _, err := planRollouts(objects, objects); err != nil {
+			return err
+		}
+		if err := dryRunApplyInventory(ctx, resMgr, newInventory, objects, printer); err != nil {
+			return err
+		}
+		return finishApplyInventory(printer)
+	}
+
+	signingKey, err := loadSigningKey(ctx, resMgr.Client(), *kubeconfigArgs.Namespace, applyInventoryArgs.signingSecret)
 	if err != nil {
 		return err
 	}
 
-	resMgr.SetOwnerLabels(objects, name, *kubeconfigArgs.Namespace)
-
 	invStorage := &inventory.Storage{
-		Manager: resMgr,
-		Owner:   inventoryOwner,
+		Manager:                  resMgr,
+		Owner:                    inventoryOwner,
+		Kind:                     rootArgs.inventoryKind,
+		AllowCrossNamespacePrune: applyInventoryArgs.allowCrossNS,
+		SigningKey:               signingKey,
+		SignExisting:             applyInventoryArgs.signExisting,
+	}
+
+	if applyInventoryArgs.lockTimeout > 0 {
+		lock, err := invStorage.AcquireLock(ctx, newInventory, inventory.LockHolder(), applyInventoryArgs.lockTimeout, applyInventoryArgs.createNamespace)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := lock.Release(context.Background()); err != nil {
+				logger.Println(err)
+			}
+		}()
+	}
+
+	if applyInventoryArgs.prune {
+		// fail before applying any changes if the stale objects are outside the namespace scope
+		if _, err := invStorage.GetInventoryStaleObjects(ctx, newInventory); err != nil {
+			return fmt.Errorf("inventory query failed, error: %w", err)
+		}
+	}
+
+	if applyInventoryArgs.confirm {
+		if err := confirmApplyInventory(ctx, resMgr, newInventory, objects); err != nil {
+			return err
+		}
+	}
+
+	if applyInventoryArgs.maxChanges > 0 {
+		if err := checkMaxChanges(ctx, resMgr, newInventory, objects, applyInventoryArgs.maxChanges); err != nil {
+			return err
+		}
+	}
+
+	progress, err := loadApplyProgress(name, *kubeconfigArgs.Namespace, objects, applyInventoryArgs.resume)
+	if err != nil {
+		return err
 	}
 
 	// contains only CRDs and Namespaces
@@ -148,7 +743,7 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 	// contains all objects except for CRDs and Namespaces
 	var stageTwo []*unstructured.Unstructured
 
-	for _, u := range objects {
+	for _, u := range progress.Pending(objects) {
 		if ssa.IsClusterDefinition(u) {
 			stageOne = append(stageOne, u)
 		} else {
@@ -156,8 +751,15 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// the depends-on references are validated before applying any object
+	rollouts, rolloutBatches, err := planRollouts(objects, stageTwo)
+	if err != nil {
+		return err
+	}
+
 	applyOpts := ssa.DefaultApplyOptions()
 	applyOpts.Force = applyInventoryArgs.force
+	applyOpts.Exclusions = reconcileExclusions()
 	applyOpts.Cleanup = ssa.ApplyCleanupOptions{
 		Annotations: []string{
 			corev1.LastAppliedConfigAnnotation,
@@ -179,59 +781,209 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 			},
 		},
 	}
+	if !applyInventoryArgs.serverSide {
+		// the last applied configuration is used to compute the client-side patches
+		applyOpts.Cleanup.Annotations = nil
+	}
+	for _, manager := range applyInventoryArgs.adopt {
+		applyOpts.Cleanup.FieldManagers = append(applyOpts.Cleanup.FieldManagers,
+			ssa.FieldManager{
+				Name:          manager,
+				OperationType: metav1.ManagedFieldsOperationApply,
+			},
+			ssa.FieldManager{
+				Name:          manager,
+				OperationType: metav1.ManagedFieldsOperationUpdate,
+			},
+		)
+	}
+
+	retryOpts := retryOptions{
+		Retries:  applyInventoryArgs.retries,
+		Interval: applyInventoryArgs.retryInterval,
+	}
 
 	waitOpts := ssa.DefaultWaitOptions()
 	waitOpts.Timeout = rootArgs.timeout
 	stageOneChangeSet := &ssa.ChangeSet{}
 
-	if len(stageOne) > 0 {
-		changeSet, err := resMgr.ApplyAll(ctx, stageOne, applyOpts)
+	// actions holds the action taken for each applied object, recorded in the inventory entries
+	actions := make(map[string]string)
+
+	var snap *snapshot.Snapshot
+	if applyInventoryArgs.atomic {
+		snap, err = snapshot.Capture(ctx, resMgr, objects)
+		if err != nil {
+			return fmt.Errorf("capturing the in-cluster state failed, error: %w", err)
+		}
+	}
+
+	// rollback restores the captured state when running in atomic mode
+	rollback := func(applyErr error) error {
+		if snap == nil {
+			return applyErr
+		}
+
+		logger.Println(`✗`, applyErr)
+		logger.Println("rolling back changes...")
+
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+		defer rollbackCancel()
+
+		rollbackMgr, err := newManager(fieldOwner)
+		if err != nil {
+			return err
+		}
+
+		changeSet, err := snap.Restore(rollbackCtx, rollbackMgr, applyOpts)
+		if changeSet != nil {
+			for _, change := range changeSet.Entries {
+				logger.Println(change.String(), "(rollback)")
+			}
+		}
 		if err != nil {
+			return fmt.Errorf("rollback failed after apply error: %v, error: %w", applyErr, err)
+		}
+		if err := progress.Clear(); err != nil {
+			logger.Println("removing apply progress failed, error:", err)
+		}
+		return fmt.Errorf("apply failed and the changes were rolled back, error: %w", applyErr)
+	}
+
+	applyStarted = true
+
+	if applyInventoryArgs.serverSide && !applyInventoryArgs.forceConflicts {
+		if err := checkConflicts(ctx, resMgr, objects, fieldOwner.Field, applyOpts); err != nil {
+			return err
+		}
+	}
+
+	if len(stageOne) > 0 {
+		var changeSet *ssa.ChangeSet
+		err := retryOnError(ctx, retryOpts, func() (err error) {
+			resCtx, resCancel := newResourceContext(ctx)
+			defer resCancel()
+			if !applyInventoryArgs.serverSide {
+				changeSet, err = clientSideApplyAll(resCtx, resMgr, stageOne, applyOpts, fieldOwner.Field)
+				return err
+			}
+			changeSet, err = resMgr.ApplyAll(resCtx, stageOne, applyOpts)
 			return err
+		})
+		if err != nil {
+			return rollback(err)
+		}
+		if err := progress.Record(changeSet); err != nil {
+			return fmt.Errorf("recording apply progress failed, error: %w", err)
 		}
 		for _, change := range changeSet.Entries {
-			logger.Println(change.String())
+			printer.Print(change)
+			actions[change.Subject] = change.Action
 		}
 		stageOneChangeSet = changeSet
 	}
 
-	stageTwoMgr, err := newManager()
+	stageTwoMgr, err := newManager(fieldOwner)
 	if err != nil {
 		return err
 	}
 
 	if len(stageOneChangeSet.Entries) > 0 {
 		if err := stageTwoMgr.WaitForSet(stageOneChangeSet.ToObjMetadataSet(), waitOpts); err != nil {
-			return err
+			return rollback(err)
+		}
+	}
+
+	for i, rollout := range rollouts {
+		if rollout.Name != "" {
+			logger.Println(fmt.Sprintf("applying stage %s...", rollout.Name))
+		}
+
+		for _, batch := range rolloutBatches[i] {
+			changeSet, err := applyObjects(ctx, stageTwoMgr, batch.Objects, applyOpts, retryOpts, applyInventoryArgs.concurrency)
+			if recordErr := progress.Record(changeSet); recordErr != nil {
+				logger.Println("recording apply progress failed, error:", recordErr)
+			}
+			if err != nil {
+				return rollback(err)
+			}
+			for _, change := range changeSet.Entries {
+				printer.Print(change)
+				actions[change.Subject] = change.Action
+			}
+
+			if len(batch.Dependencies) > 0 {
+				logger.Println("waiting for dependencies to become ready...")
+				if err := stageTwoMgr.Wait(batch.Dependencies, waitOpts); err != nil {
+					return rollback(err)
+				}
+			}
+		}
+
+		if i < len(rollouts)-1 {
+			logger.Println("waiting for stage objects to become ready...")
+			if err := stageTwoMgr.Wait(rollout.Objects, waitOpts); err != nil {
+				return rollback(fmt.Errorf("stage %s failed, error: %w", rollout.Name, err))
+			}
 		}
 	}
 
-	sort.Sort(ssa.SortableUnstructureds(stageTwo))
-	for _, object := range stageTwo {
-		change, err := stageTwoMgr.Apply(ctx, object, applyOpts)
+	var staleObjects []*unstructured.Unstructured
+	if applyInventoryArgs.prune {
+		staleObjects, err = invStorage.GetInventoryStaleObjects(ctx, newInventory)
 		if err != nil {
-			return err
+			return fmt.Errorf("inventory query failed, error: %w", err)
+		}
+		var keptObjects []*unstructured.Unstructured
+		staleObjects, keptObjects = allowedStaleObjects(staleObjects)
+		if len(keptObjects) > 0 {
+			if err := newInventory.AddObjects(keptObjects); err != nil {
+				return fmt.Errorf("creating inventory failed, error: %w", err)
+			}
+			if newInventory.Namespaces, err = newInventory.NamespaceScope(); err != nil {
+				return fmt.Errorf("creating inventory failed, error: %w", err)
+			}
 		}
-		logger.Println(change.String())
 	}
 
-	staleObjects, err := invStorage.GetInventoryStaleObjects(ctx, newInventory)
+	changes := printer.Changes() + len(staleObjects)
+	newInventory.Provenance.Checksum = progress.Checksum
+
+	currentRevision, err := recordInventoryRevision(ctx, invStorage, newInventory, progress.Checksum, changes)
 	if err != nil {
 		return fmt.Errorf("inventory query failed, error: %w", err)
 	}
+	currentRevision.StoredManifests = applyInventoryArgs.storeManifests
+	if err := recordEntryMetadata(ctx, resMgr, newInventory, actions); err != nil {
+		return err
+	}
+	newInventory.SetMeta(inventoryMeta)
 
 	err = invStorage.ApplyInventory(ctx, newInventory, applyInventoryArgs.createNamespace)
 	if err != nil {
 		return fmt.Errorf("inventory apply failed, error: %w", err)
 	}
 
-	if applyInventoryArgs.prune && len(staleObjects) > 0 {
-		changeSet, err := stageTwoMgr.DeleteAll(ctx, staleObjects, ssa.DefaultDeleteOptions())
-		if err != nil {
-			return fmt.Errorf("prune failed, error: %w", err)
+	if applyInventoryArgs.storeManifests {
+		if err := storeInventoryManifests(ctx, invStorage, newInventory, currentRevision.Number, objects); err != nil {
+			return err
+		}
+	}
+
+	if err := progress.Clear(); err != nil {
+		return fmt.Errorf("removing apply progress failed, error: %w", err)
+	}
+
+	if applyInventoryArgs.prune && len(staleObjects) > 0 {
+		deleteOpts := ssa.DefaultDeleteOptions()
+		deleteOpts.Exclusions = reconcileExclusions()
+		deleteOpts.PropagationPolicy = propagationPolicy
+		changeSet, err := stageTwoMgr.DeleteAll(ctx, staleObjects, deleteOpts)
+		if err != nil {
+			return fmt.Errorf("prune failed, error: %w", err)
 		}
 		for _, change := range changeSet.Entries {
-			logger.Println(change.String())
+			printer.Print(change)
 		}
 	}
 
@@ -254,9 +1006,708 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 		logger.Println("all resources are ready")
 	}
 
+	return finishApplyInventory(printer)
+}
+
+// signingKeySecretKey is the Secret field that holds the inventory HMAC key.
+const signingKeySecretKey = "key"
+
+// verifyArtifacts verifies the cosign signature of the OCI artifacts and returns their digest URLs.
+func verifyArtifacts(ctx context.Context, artifacts []string, opts cosignVerifyOptions) ([]string, error) {
+	result := make([]string, 0, len(artifacts))
+	for _, ociURL := range artifacts {
+		baseURL, variant := registry.SplitVariant(ociURL)
+		url, err := registry.ResolveURL(ctx, registryOpts, baseURL, "")
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s failed: %w", ociURL, err)
+		}
+
+		digestURL, err := verifyArtifact(ctx, url, opts)
+		if err != nil {
+			return nil, fmt.Errorf("verifying %s failed: %w", ociURL, err)
+		}
+		logger.Println("verified", ociURL, "digest", digestURL)
+		if variant != "" {
+			digestURL += registry.VariantSeparator + variant
+		}
+		result = append(result, registry.URLPrefix+digestURL)
+	}
+	return result, nil
+}
+
+// loadSigningKey returns the inventory HMAC key from the given Secret, or nil if no Secret is specified.
+func loadSigningKey(ctx context.Context, kubeClient client.Client, namespace, name string) ([]byte, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := kubeClient.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, secret); err != nil {
+		return nil, fmt.Errorf("signing key query failed, error: %w", err)
+	}
+
+	key, ok := secret.Data[signingKeySecretKey]
+	if !ok || len(key) == 0 {
+		return nil, fmt.Errorf("signing key not found in Secret %s/%s, the key must be stored in the '%s' field", namespace, name, signingKeySecretKey)
+	}
+	return key, nil
+}
+
+// recordInventoryRevision copies the history and the metadata of the in-cluster inventory
+// and appends a new revision for the objects recorded in the given inventory.
+// The apply metadata of the entries is copied after the revision is recorded, to keep it out of the history.
+func recordInventoryRevision(ctx context.Context, invStorage *inventory.Storage, inv *inventory.Inventory, checksum string, changes int) (*inventory.Revision, error) {
+	existingInventory := inventory.NewInventory(inv.Name, inv.Namespace)
+	if err := invStorage.GetInventory(ctx, existingInventory); err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	inv.History = existingInventory.History
+	inv.Meta = existingInventory.Meta
+	rev := inv.AddRevision(checksum, changes, applyInventoryArgs.historyLimit)
+	inv.CopyEntryMetadata(existingInventory)
+	return rev, nil
+}
+
+// newProvenance returns the origin of the manifests specified with the apply flags.
+func newProvenance(artifacts []string, digests []string, gitCommit string) *inventory.Provenance {
+	provenance := &inventory.Provenance{
+		GitCommit:         gitCommit,
+		KustomizerVersion: VERSION,
+	}
+
+	for i, artifact := range artifacts {
+		if i < len(digests) {
+			provenance.Artifacts = append(provenance.Artifacts, inventory.ArtifactProvenance{
+				URL:    artifact,
+				Digest: digests[i],
+			})
+		}
+	}
+
+	if applyInventoryArgs.gitURL != "" {
+		provenance.GitURL = applyInventoryArgs.gitURL
+		return provenance
+	}
+
+	paths := applyInventoryArgs.filename
+	if applyInventoryArgs.kustomize != "" {
+		paths = append([]string{applyInventoryArgs.kustomize}, paths...)
+	}
+	for _, p := range paths {
+		if p != stdinPath && !strings.HasPrefix(p, "http://") && !strings.HasPrefix(p, "https://") {
+			if abs, err := filepath.Abs(p); err == nil {
+				p = abs
+			}
+		}
+		provenance.Paths = append(provenance.Paths, p)
+	}
+
+	return provenance
+}
+
+// storeInventoryManifests records the applied objects for the given revision
+// and removes the manifests of the revisions dropped from the inventory history.
+func storeInventoryManifests(ctx context.Context, invStorage *inventory.Storage, inv *inventory.Inventory, number int, objects []*unstructured.Unstructured) error {
+	yml, err := ssa.ObjectsToYAML(objects)
+	if err != nil {
+		return err
+	}
+
+	if err := invStorage.StoreManifests(ctx, inv, number, yml); err != nil {
+		return fmt.Errorf("inventory apply failed, error: %w", err)
+	}
+
+	if err := invStorage.PruneManifests(ctx, inv); err != nil {
+		return fmt.Errorf("inventory apply failed, error: %w", err)
+	}
 	return nil
 }
 
+// recordEntryMetadata sets the last action on the inventory entries of the applied objects,
+// for the objects that were created or configured it also records the apply time and the observed generation.
+func recordEntryMetadata(ctx context.Context, resMgr *ssa.ResourceManager, inv *inventory.Inventory, actions map[string]string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i, entry := range inv.Resources {
+		objMetadata, err := object.ParseObjMetadata(entry.ObjectID)
+		if err != nil {
+			return err
+		}
+
+		action, ok := actions[ssa.FmtObjMetadata(objMetadata)]
+		if !ok {
+			continue
+		}
+		inv.Resources[i].Action = action
+
+		changed := action == string(ssa.CreatedAction) || action == string(ssa.ConfiguredAction)
+		if changed {
+			inv.Resources[i].AppliedAt = now
+		}
+		if !changed && entry.Generation > 0 {
+			continue
+		}
+
+		existingObject := &metav1.PartialObjectMetadata{}
+		existingObject.SetGroupVersionKind(objMetadata.GroupKind.WithVersion(entry.ObjectVersion))
+		err = resMgr.Client().Get(ctx, client.ObjectKey{Name: objMetadata.Name, Namespace: objMetadata.Namespace}, existingObject)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("%s query failed, error: %w", ssa.FmtObjMetadata(objMetadata), err)
+		}
+		inv.Resources[i].Generation = existingObject.GetGeneration()
+	}
+	return nil
+}
+
+// finishApplyInventory prints the summary and the collected change set entries,
+// then returns an exit code 2 error if there were changes and --exit-code is set.
+func finishApplyInventory(printer *changeSetPrinter) error {
+	logger.Println("summary:", printer.Summary())
+
+	if err := printer.Flush(); err != nil {
+		return err
+	}
+
+	if applyInventoryArgs.exitCode && printer.HasChanges() {
+		return &exitCodeError{code: 2, reason: "changes applied"}
+	}
+
+	return nil
+}
+
+// dryRunApplyInventory performs a server-side dry-run apply for each object and prints the resulting change set.
+// Custom resources whose definitions are not yet present on the cluster can't be validated,
+// these objects are reported as created if their CRD is part of the same inventory.
+// When pruning is enabled, the stale objects that would be deleted are reported without deleting them.
+func dryRunApplyInventory(ctx context.Context, resMgr *ssa.ResourceManager, inv *inventory.Inventory,
+	objects []*unstructured.Unstructured, printer *changeSetPrinter) error {
+	pendingKinds := make(map[schema.GroupKind]bool)
+	sort.Sort(ssa.SortableUnstructureds(objects))
+	for _, object := range objects {
+		if pendingKinds[object.GroupVersionKind().GroupKind()] {
+			printer.Print(ssa.ChangeSetEntry{
+				Subject: ssa.FmtUnstructured(object),
+				Action:  string(ssa.CreatedAction),
+			})
+			continue
+		}
+
+		resCtx, resCancel := newResourceContext(ctx)
+		change, err := diffObject(resCtx, resMgr, object)
+		resCancel()
+		if err != nil {
+			return err
+		}
+		printer.Print(*change)
+
+		if object.GetKind() == "CustomResourceDefinition" && change.Action == string(ssa.CreatedAction) {
+			group, _, _ := unstructured.NestedString(object.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(object.Object, "spec", "names", "kind")
+			pendingKinds[schema.GroupKind{Group: group, Kind: kind}] = true
+		}
+	}
+
+	if applyInventoryArgs.prune {
+		signingKey, err := loadSigningKey(ctx, resMgr.Client(), inv.Namespace, applyInventoryArgs.signingSecret)
+		if err != nil {
+			return err
+		}
+
+		invStorage := &inventory.Storage{
+			Manager:                  resMgr,
+			Owner:                    inventoryOwner,
+			Kind:                     rootArgs.inventoryKind,
+			AllowCrossNamespacePrune: applyInventoryArgs.allowCrossNS,
+			SigningKey:               signingKey,
+			SignExisting:             applyInventoryArgs.signExisting,
+		}
+
+		staleObjects, err := invStorage.GetInventoryStaleObjects(ctx, inv)
+		if err != nil {
+			return fmt.Errorf("inventory query failed, error: %w", err)
+		}
+
+		staleObjects, _ = allowedStaleObjects(staleObjects)
+		sort.Sort(sort.Reverse(ssa.SortableUnstructureds(staleObjects)))
+		for _, object := range staleObjects {
+			printer.Print(ssa.ChangeSetEntry{
+				Subject: ssa.FmtUnstructured(object),
+				Action:  string(ssa.DeletedAction),
+			})
+		}
+	}
+
+	return nil
+}
+
+// dryRunLabel returns the suffix of the changes computed with a dry-run.
+func dryRunLabel() string {
+	if !applyInventoryArgs.serverSide {
+		return "(client dry run)"
+	}
+	return "(server dry run)"
+}
+
+// confirmApplyInventory prints the changes computed with a server-side dry-run apply
+// and the prune candidates, then asks the user to confirm the apply.
+func confirmApplyInventory(ctx context.Context, resMgr *ssa.ResourceManager, inv *inventory.Inventory, objects []*unstructured.Unstructured) error {
+	preview, err := newChangeSetPrinter("", func(entry changeSetEntry) {
+		logger.Println(entry.Subject, entry.Action, dryRunLabel())
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := dryRunApplyInventory(ctx, resMgr, inv, objects, preview); err != nil {
+		return err
+	}
+
+	confirmed, err := askForConfirmation("Do you want to apply these changes?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("apply aborted")
+	}
+
+	return nil
+}
+
+// checkMaxChanges performs a server-side dry-run apply and returns an error
+// if the number of objects to be changed or pruned exceeds the limit.
+func checkMaxChanges(ctx context.Context, resMgr *ssa.ResourceManager, inv *inventory.Inventory,
+	objects []*unstructured.Unstructured, limit int) error {
+	preview, err := newChangeSetPrinter("", func(entry changeSetEntry) {})
+	if err != nil {
+		return err
+	}
+
+	if err := dryRunApplyInventory(ctx, resMgr, inv, objects, preview); err != nil {
+		return err
+	}
+
+	if changes := preview.Changes(); changes > limit {
+		return fmt.Errorf("apply aborted, %d objects would be changed or pruned which exceeds the limit of %d set with --max-changes",
+			changes, limit)
+	}
+
+	return nil
+}
+
+// applyObjects applies the given objects using a pool of workers, retrying the failed applies,
+// the returned change set entries are in the same order as the objects.
+// When an apply fails, the returned change set contains the objects applied before the failure.
+func applyObjects(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	opts ssa.ApplyOptions, retryOpts retryOptions, concurrency int) (*ssa.ChangeSet, error) {
+	changes := make([]*ssa.ChangeSetEntry, len(objects))
+
+	g, gCtx := errgroup.WithContext(ctx)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	g.SetLimit(concurrency)
+	failures := make([]*objectFailure, len(objects))
+	for i, object := range objects {
+		i, object := i, object
+		g.Go(func() error {
+			var change *ssa.ChangeSetEntry
+			err := retryOnError(gCtx, retryOpts, func() (err error) {
+				resCtx, resCancel := newResourceContext(gCtx)
+				defer resCancel()
+				change, err = applyObject(resCtx, resMgr, object, opts)
+				return err
+			})
+			if err != nil {
+				// the objects interrupted by the failure of another object are not reported as failed
+				if gCtx.Err() == nil || !errors.Is(err, context.Canceled) {
+					failures[i] = &objectFailure{subject: ssa.FmtUnstructured(object), err: err}
+				}
+				return err
+			}
+			changes[i] = change
+			return nil
+		})
+	}
+
+	err := g.Wait()
+	if err != nil {
+		applyErr := &applyObjectsError{err: err}
+		for _, f := range failures {
+			if f != nil {
+				applyErr.failures = append(applyErr.failures, *f)
+			}
+		}
+		err = applyErr
+	}
+
+	changeSet := ssa.NewChangeSet()
+	for _, change := range changes {
+		if change != nil {
+			changeSet.Add(*change)
+		}
+	}
+	return changeSet, err
+}
+
+// objectFailure holds the apply error of an object.
+type objectFailure struct {
+	subject string
+	err     error
+}
+
+// applyObjectsError is returned when applying a batch of objects fails,
+// it holds the first error and the list of the objects that failed to apply.
+type applyObjectsError struct {
+	err      error
+	failures []objectFailure
+}
+
+func (e *applyObjectsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *applyObjectsError) Unwrap() error {
+	return e.err
+}
+
+// applyObject applies the given object with server-side apply,
+// or with a client-side patch when server-side apply is disabled.
+// The objects that drifted only in fields not owned by kustomizer or in the fields excluded
+// with --ignore-paths are not applied, the same way they are reported by the dry-run and diff.
+func applyObject(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured,
+	opts ssa.ApplyOptions) (*ssa.ChangeSetEntry, error) {
+	fieldManager := newFieldOwner(applyInventoryArgs.fieldManager).Field
+	if !applyInventoryArgs.serverSide {
+		// the drift is computed from the three-way merge patch, an empty patch is not applied
+		return clientSideApply(ctx, resMgr, object, opts, fieldManager)
+	}
+
+	change, err := diffObject(ctx, resMgr, object)
+	switch {
+	case err != nil:
+		// the dry-run errors e.g. immutable fields changes are handled by the apply
+		return resMgr.Apply(ctx, object, opts)
+	case needsCleanup(ctx, resMgr, object, opts.Cleanup):
+		// the annotations and field managers are removed by the apply
+		return resMgr.Apply(ctx, object, opts)
+	case change.Action == string(ssa.UnchangedAction):
+		return change, nil
+	}
+
+	// the dry-run result is reused, the object is applied without a second dry-run
+	appliedObject := object.DeepCopy()
+	if err := resMgr.Client().Patch(ctx, appliedObject, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager)); err != nil {
+		return nil, fmt.Errorf("%s apply failed, error: %w", ssa.FmtUnstructured(appliedObject), err)
+	}
+	return change, nil
+}
+
+// needsCleanup returns true if the in-cluster object has annotations or field managers that are removed at apply time.
+func needsCleanup(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured, cleanup ssa.ApplyCleanupOptions) bool {
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	if err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+		return false
+	}
+
+	for _, annotation := range cleanup.Annotations {
+		if _, ok := existingObject.GetAnnotations()[annotation]; ok {
+			return true
+		}
+	}
+
+	// the managers are matched the same way as in the ssa cleanup, by name prefix and operation
+	for _, entry := range existingObject.GetManagedFields() {
+		if entry.Subresource != "" {
+			continue
+		}
+		for _, manager := range cleanup.FieldManagers {
+			if entry.Operation == manager.OperationType && isCleanupManager(entry.Manager, []ssa.FieldManager{manager}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// diffObject performs a server-side dry-run apply of the given object,
+// the objects that drifted only in fields not owned by kustomizer or in the fields
+// excluded with --ignore-paths are reported as unchanged.
+// When server-side apply is disabled, the change is computed from the client-side three-way merge patch.
+func diffObject(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured) (*ssa.ChangeSetEntry, error) {
+	if !applyInventoryArgs.serverSide {
+		return clientSideDiff(ctx, resMgr, object, newFieldOwner(applyInventoryArgs.fieldManager).Field)
+	}
+
+	change, liveObject, mergedObject, err := resMgr.Diff(ctx, object, ssa.DiffOptions{Exclusions: reconcileExclusions()})
+	if err != nil {
+		return nil, err
+	}
+
+	if change.Action == string(ssa.ConfiguredAction) {
+		paths, err := drift.ParsePaths(applyInventoryArgs.ignorePaths)
+		if err != nil {
+			return nil, err
+		}
+		drifted, err := hasOwnedFieldsDrifted(ctx, resMgr, object, liveObject, mergedObject,
+			newFieldOwner(applyInventoryArgs.fieldManager).Field, paths)
+		if err != nil {
+			return nil, err
+		}
+		if !drifted {
+			change.Action = string(ssa.UnchangedAction)
+		}
+	}
+
+	return change, nil
+}
+
+// hasOwnedFieldsDrifted returns true if the fields set in the desired object or owned by the given field manager
+// have drifted, the changes made by other controllers e.g. defaulting, mutating webhooks or HPA are ignored.
+// Secrets are compared in full, as the API server moves the stringData values into the data field.
+func hasOwnedFieldsDrifted(ctx context.Context, resMgr *ssa.ResourceManager, object, liveObject, mergedObject *unstructured.Unstructured,
+	manager string, ignorePaths []drift.Path) (bool, error) {
+	if object.GetKind() == "Secret" {
+		return drift.HasDrifted(liveObject, mergedObject, ignorePaths), nil
+	}
+
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	if err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+		return false, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	fields, err := drift.OwnedFields(existingObject, object, manager)
+	if err != nil {
+		return false, err
+	}
+
+	return drift.HasDrifted(drift.Prune(liveObject, fields), drift.Prune(mergedObject, fields), ignorePaths), nil
+}
+
+// checkConflicts performs a server-side dry-run apply without forcing the ownership of the given objects,
+// and returns an error listing the conflicting field managers and fields.
+// The excluded objects and the conflicts with the field managers removed at apply time are ignored.
+func checkConflicts(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	fieldManager string, opts ssa.ApplyOptions) error {
+	var conflicts []string
+	for _, object := range objects {
+		existingObject := object.DeepCopy()
+		_ = resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject)
+		if ssa.AnyInMetadata(existingObject, opts.Exclusions) {
+			continue
+		}
+
+		dryRunObject := object.DeepCopy()
+		err := resMgr.Client().Patch(ctx, dryRunObject, client.Apply, client.DryRunAll, client.FieldOwner(fieldManager))
+		if err == nil || !apierrors.IsConflict(err) {
+			// other errors are reported by the apply
+			continue
+		}
+
+		var status apierrors.APIStatus
+		if !errors.As(err, &status) || status.Status().Details == nil {
+			conflicts = append(conflicts, fmt.Sprintf("%s %v", ssa.FmtUnstructured(object), err))
+			continue
+		}
+
+		for _, cause := range status.Status().Details.Causes {
+			if cause.Type != metav1.CauseTypeFieldManagerConflict {
+				continue
+			}
+
+			match := conflictManagerRegexp.FindStringSubmatch(cause.Message)
+			if len(match) == 2 && isCleanupManager(match[1], opts.Cleanup.FieldManagers) {
+				continue
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%s %s %s", ssa.FmtUnstructured(object), cause.Field, cause.Message))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("apply conflicts detected, use --force-conflicts to take ownership of the fields:\n%s",
+			strings.Join(conflicts, "\n"))
+	}
+
+	return nil
+}
+
+var conflictManagerRegexp = regexp.MustCompile(`conflict with "([^"]+)"`)
+
+func isCleanupManager(name string, cleanup []ssa.FieldManager) bool {
+	for _, manager := range cleanup {
+		if strings.HasPrefix(name, manager.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedStaleObjects splits the stale objects into the ones with kinds found in the prune allowlist
+// and the ones that must be kept in the inventory, when the allowlist is empty all objects are allowed.
+func allowedStaleObjects(objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured) {
+	if len(applyInventoryArgs.pruneAllowlist) == 0 {
+		return objects, nil
+	}
+
+	var allowed, kept []*unstructured.Unstructured
+	for _, object := range objects {
+		found := false
+		for _, kind := range applyInventoryArgs.pruneAllowlist {
+			if strings.EqualFold(kind, object.GetKind()) {
+				found = true
+				break
+			}
+		}
+		if found {
+			allowed = append(allowed, object)
+		} else {
+			logger.Println(ssa.FmtUnstructured(object), "kept in inventory (not in prune allowlist)")
+			kept = append(kept, object)
+		}
+	}
+	return allowed, kept
+}
+
+// rolloutStage is a group of objects that have the same stage label value.
+type rolloutStage struct {
+	Name    string
+	Objects []*unstructured.Unstructured
+
+	// rank orders the unlabeled stage first, then the integer stages and then the named stages.
+	rank   int
+	number int
+}
+
+// stageRank returns the rank and the numeric value of a stage label value.
+func stageRank(name string) (int, int) {
+	if name == "" {
+		return 0, 0
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		return 1, n
+	}
+	return 2, 0
+}
+
+// groupByStage groups the objects by the value of the given label. The objects without the label
+// are placed in the first group, followed by the groups with integer values in numeric order and
+// then by the groups with other values in lexical order.
+func groupByStage(objects []*unstructured.Unstructured, label string) []rolloutStage {
+	if label == "" {
+		return []rolloutStage{{Objects: objects}}
+	}
+
+	index := make(map[string]int)
+	var stages []rolloutStage
+	for _, object := range objects {
+		name := object.GetLabels()[label]
+		i, ok := index[name]
+		if !ok {
+			i = len(stages)
+			index[name] = i
+			rank, number := stageRank(name)
+			stages = append(stages, rolloutStage{Name: name, rank: rank, number: number})
+		}
+		stages[i].Objects = append(stages[i].Objects, object)
+	}
+
+	sort.SliceStable(stages, func(i, j int) bool {
+		a, b := stages[i], stages[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.number != b.number {
+			return a.number < b.number
+		}
+		return a.Name < b.Name
+	})
+
+	return stages
+}
+
+// planRollouts groups the pending objects in stages and orders the objects of each stage in batches
+// using their depends-on references. The references must target objects of the same stage or objects
+// applied before the stage: the CRDs and Namespaces, the objects of the previous stages and the objects
+// applied before a failure, otherwise an error is returned as the ordering can't be honored.
+func planRollouts(objects, pending []*unstructured.Unstructured) ([]rolloutStage, [][]dependency.Batch, error) {
+	isPending := make(map[*unstructured.Unstructured]bool, len(pending))
+	var stageObjects []*unstructured.Unstructured
+	for _, object := range pending {
+		if !ssa.IsClusterDefinition(object) {
+			isPending[object] = true
+			stageObjects = append(stageObjects, object)
+		}
+	}
+
+	var applied []*unstructured.Unstructured
+	for _, object := range objects {
+		if !isPending[object] {
+			applied = append(applied, object)
+		}
+	}
+
+	sort.Sort(ssa.SortableUnstructureds(stageObjects))
+	rollouts := groupByStage(stageObjects, applyInventoryArgs.stageLabel)
+	rolloutBatches := make([][]dependency.Batch, len(rollouts))
+	for i, rollout := range rollouts {
+		if err := dependency.Validate(rollout.Objects, applied); err != nil {
+			if rollout.Name != "" {
+				return nil, nil, fmt.Errorf("stage %s: %w", rollout.Name, err)
+			}
+			return nil, nil, err
+		}
+
+		batches, err := dependency.Sort(rollout.Objects)
+		if err != nil {
+			return nil, nil, err
+		}
+		rolloutBatches[i] = batches
+		applied = append(applied, rollout.Objects...)
+	}
+
+	return rollouts, rolloutBatches, nil
+}
+
+// missingNamespaces returns the namespaces referenced by the given objects
+// that are neither part of the objects set nor present on the cluster.
+func missingNamespaces(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	found := make(map[string]bool)
+	for _, object := range objects {
+		if object.GetKind() == "Namespace" && object.GroupVersionKind().Group == "" {
+			found[object.GetName()] = true
+		}
+	}
+
+	var namespaces []*unstructured.Unstructured
+	for _, object := range objects {
+		name := object.GetNamespace()
+		if name == "" || found[name] {
+			continue
+		}
+		found[name] = true
+
+		ns := &unstructured.Unstructured{}
+		ns.SetAPIVersion("v1")
+		ns.SetKind("Namespace")
+		ns.SetName(name)
+
+		err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(ns), ns.DeepCopy())
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(ns), err)
+		}
+		namespaces = append(namespaces, ns)
+	}
+
+	return namespaces, nil
+}
+
 // fixReplicasConflict removes the replicas field from the given workload if it's managed by an HPA
 func fixReplicasConflict(object *unstructured.Unstructured, objects []*unstructured.Unstructured) {
 	for _, hpa := range objects {
@@ -272,7 +1723,31 @@ func fixReplicasConflict(object *unstructured.Unstructured, objects []*unstructu
 	}
 }
 
-func newManager() (*ssa.ResourceManager, error) {
+// newResourceContext returns a context that expires after the resource timeout, if set.
+func newResourceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if applyInventoryArgs.resourceTimeout > 0 {
+		return context.WithTimeout(ctx, applyInventoryArgs.resourceTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// reconcileExclusions returns the metadata that excludes objects from apply, prune and drift detection.
+func reconcileExclusions() map[string]string {
+	return map[string]string{
+		inventory.ReconcileAnnotation: inventory.ReconcileDisabledValue,
+	}
+}
+
+// newFieldOwner returns the inventory owner with the field manager name set to the given value, if not empty.
+func newFieldOwner(fieldManager string) ssa.Owner {
+	owner := inventoryOwner
+	if fieldManager != "" {
+		owner.Field = fieldManager
+	}
+	return owner
+}
+
+func newManager(owner ssa.Owner) (*ssa.ResourceManager, error) {
 	kubeClient, err := newKubeClient(kubeconfigArgs)
 	if err != nil {
 		return nil, fmt.Errorf("client init failed: %w", err)
@@ -283,5 +1758,97 @@ func newManager() (*ssa.ResourceManager, error) {
 		return nil, fmt.Errorf("status poller init failed: %w", err)
 	}
 
-	return ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner), nil
+	return ssa.NewResourceManager(kubeClient, statusPoller, owner), nil
+}
+
+func selectObjects(objects []*unstructured.Unstructured, selector labels.Selector) []*unstructured.Unstructured {
+	var result []*unstructured.Unstructured
+	for _, object := range objects {
+		if selector.Matches(labels.Set(object.GetLabels())) {
+			result = append(result, object)
+		}
+	}
+	return result
+}
+
+// decorateObjects sets the given labels and annotations in the format 'key=value' on all objects,
+// together with the inventory and checksum labels.
+func decorateObjects(objects []*unstructured.Unstructured, name, namespace string, labelPairs, annotationPairs []string) error {
+	commonLabels, err := parseKeyValuePairs(labelPairs)
+	if err != nil {
+		return fmt.Errorf("invalid label: %w", err)
+	}
+	for key, value := range commonLabels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key '%s': %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid label value '%s': %s", value, strings.Join(errs, "; "))
+		}
+	}
+	commonLabels[inventory.InventoryLabel] = inventory.LabelValue(name, namespace)
+
+	commonAnnotations, err := parseKeyValuePairs(annotationPairs)
+	if err != nil {
+		return fmt.Errorf("invalid annotation: %w", err)
+	}
+
+	for _, object := range objects {
+		objectLabels := object.GetLabels()
+		if objectLabels == nil {
+			objectLabels = make(map[string]string)
+		}
+		for key, value := range commonLabels {
+			objectLabels[key] = value
+		}
+		object.SetLabels(objectLabels)
+
+		if len(commonAnnotations) > 0 {
+			objectAnnotations := object.GetAnnotations()
+			if objectAnnotations == nil {
+				objectAnnotations = make(map[string]string)
+			}
+			for key, value := range commonAnnotations {
+				objectAnnotations[key] = value
+			}
+			object.SetAnnotations(objectAnnotations)
+		}
+
+		checksum, err := inventory.ObjectChecksum(object)
+		if err != nil {
+			return fmt.Errorf("%s checksum failed: %w", ssa.FmtUnstructured(object), err)
+		}
+		objectLabels[inventory.ChecksumLabel] = checksum
+		object.SetLabels(objectLabels)
+	}
+
+	return nil
+}
+
+// parseInventoryMeta returns the inventory metadata from the given pairs in the format 'key=value',
+// the keys must be valid DNS labels as they are stored in the inventory annotations.
+func parseInventoryMeta(pairs []string) (map[string]string, error) {
+	meta, err := parseKeyValuePairs(pairs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	for key := range meta {
+		if errs := validation.IsDNS1123Label(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid metadata key '%s': %s", key, strings.Join(errs, "; "))
+		}
+	}
+	return meta, nil
+}
+
+// parseKeyValuePairs returns a map from a list of pairs in the format 'key=value'.
+func parseKeyValuePairs(pairs []string) (map[string]string, error) {
+	result := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("'%s' must be in the format 'key=value'", pair)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result, nil
 }
@@ -18,13 +18,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	. "github.com/onsi/gomega"
@@ -56,6 +64,58 @@ func TestApply(t *testing.T) {
 		g.Expect(output).To(MatchRegexp(id))
 	})
 
+	t.Run("dry-run does not mutate objects", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-dry", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --dry-run",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("created \\(server dry run\\)"))
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id + "-dry",
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("dry-run reports prune candidates", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-dry", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --dry-run --prune",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s deleted \\(server dry run\\)", id)))
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
 	t.Run("labels objects", func(t *testing.T) {
 		configMap = &corev1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -122,6 +182,52 @@ func TestApply(t *testing.T) {
 		g.Expect(output).To(MatchRegexp("waiting"))
 	})
 
+	t.Run("applies objects concurrently", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-2", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --concurrency 4",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s-2 created", id, id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%s/%s-2 created", id, id)))
+	})
+
+	t.Run("returns exit code 2 on changes", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-3", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --exit-code",
+			id,
+			dir,
+			id,
+		))
+
+		t.Logf("\n%s", output)
+		var exitErr *exitCodeError
+		g.Expect(errors.As(err, &exitErr)).To(BeTrue())
+		g.Expect(exitErr.code).To(Equal(2))
+		g.Expect(output).To(ContainSubstring("summary:"))
+
+		// the inventory lock must be released when exiting with a code
+		output, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --exit-code",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
 	t.Run("recreates immutable objects", func(t *testing.T) {
 		dir, err := makeTestDir(id, testManifests(id, id, true))
 		g.Expect(err).NotTo(HaveOccurred())
@@ -144,6 +250,7 @@ func TestApply(t *testing.T) {
 		))
 		g.Expect(err).To(HaveOccurred())
 		g.Expect(err.Error()).To(MatchRegexp("immutable"))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%s/%s failed", id, id)))
 
 		output, err = executeCommand(fmt.Sprintf(
 			"apply inv test-%s -k %s -n %s --force",
@@ -181,6 +288,21 @@ func TestApplyArtifact(t *testing.T) {
 		g.Expect(output).To(MatchRegexp(id))
 	})
 
+	t.Run("rejects unsigned artifact", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -n %s -a %s --verify",
+			id,
+			id,
+			artifact,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+
+		configMap := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
 	t.Run("applies artifact", func(t *testing.T) {
 		output, err := executeCommand(fmt.Sprintf(
 			"apply inv %s -n %s -a %s",
@@ -220,6 +342,58 @@ func TestApplyArtifact(t *testing.T) {
 	})
 }
 
+func TestApplySourceArtifact(t *testing.T) {
+	g := NewWithT(t)
+	id := randStringRunes(5)
+	artifact := fmt.Sprintf("oci://%s/%s:v1.0.0", registryHost, id)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("pushes kustomize sources", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --mode source",
+			artifact,
+			dir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("pulls kustomize sources", func(t *testing.T) {
+		outDir := path.Join(tmpDir, id+"-source")
+		_, err := executeCommand(fmt.Sprintf(
+			"pull artifact %s -o %s",
+			artifact,
+			outDir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = os.Stat(path.Join(outDir, "kustomization.yaml"))
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("builds and applies sources", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -n %s -a %s",
+			id,
+			id,
+			artifact,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+}
+
 func TestApplyEncryptedArtifact(t *testing.T) {
 	g := NewWithT(t)
 	id := randStringRunes(5)
@@ -274,4 +448,1597 @@ func TestApplyEncryptedArtifact(t *testing.T) {
 		g.Expect(configMap.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/name", id))
 		g.Expect(configMap.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/namespace", id))
 	})
+
+	t.Run("pushes artifact encrypted for a recipient key", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact oci://%s/%s:v2.0.0 -k %s --encrypt-recipient %s",
+			registryHost,
+			id,
+			dir,
+			testAgeKeys[1].Body,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("decrypts artifact with identity", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"pull artifact oci://%s/%s:v2.0.0 --decrypt-identity %s",
+			registryHost,
+			id,
+			path.Join(ageDir, "id.txt"),
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(id))
+	})
+}
+
+func TestApplyGit(t *testing.T) {
+	g := NewWithT(t)
+	id := "git-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+		{"tag", "v1.0.0"},
+	} {
+		gitCmd := exec.Command("git", args...)
+		gitCmd.Dir = dir
+		out, err := gitCmd.CombinedOutput()
+		g.Expect(err).NotTo(HaveOccurred(), string(out))
+	}
+
+	t.Run("applies objects from git", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -n %s --git-url file://%s --git-ref v1.0.0 --git-path ./",
+			id,
+			id,
+			dir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("created"))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("sets git source in inventory", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("Revision: v1.0.0/"))
+	})
+}
+
+func TestApplyDependsOn(t *testing.T) {
+	g := NewWithT(t)
+	id := "deps-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "configs.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: "%[1]s"
+  annotations:
+    kustomizer.dev/depends-on: "%[1]s/base"
+data:
+  key: "app"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: base
+  namespace: "%[1]s"
+data:
+  key: "base"
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies dependencies first", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("(?s)ConfigMap/%[1]s/base created.*waiting for dependencies.*ConfigMap/%[1]s/app created", id)))
+	})
+
+	t.Run("rejects unknown dependencies", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s --exclude name=base",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("%[1]s/base (required by ConfigMap/%[1]s/app)", id)))
+	})
+
+	t.Run("applies dependencies first when the objects are moved and renamed", func(t *testing.T) {
+		target := id + "-target"
+		err := createNamespace(target)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s --target-namespace %s --name-prefix p-",
+			target,
+			dir,
+			id,
+			target,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("(?s)ConfigMap/%[1]s/p-base created.*waiting for dependencies.*ConfigMap/%[1]s/p-app created", target)))
+	})
+
+	t.Run("rejects dependencies on later stages", func(t *testing.T) {
+		stagesDir, err := makeTestDir(id+"-stages", []TestFile{
+			{
+				Name: "configs.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: "%[1]s"
+  labels:
+    rollout-stage: "1"
+  annotations:
+    kustomizer.dev/depends-on: "%[1]s/base"
+data:
+  key: "app"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: base
+  namespace: "%[1]s"
+  labels:
+    rollout-stage: "2"
+data:
+  key: "base"
+`, id),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s-stages -f %s -n %s --stage-label rollout-stage",
+			id,
+			stagesDir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("stage 1: depends-on references not found in the applied objects: %[1]s/base", id)))
+	})
+}
+
+func TestApplySelector(t *testing.T) {
+	g := NewWithT(t)
+	id := "selector-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "configs.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: frontend
+  namespace: "%[1]s"
+  labels:
+    app: frontend
+data:
+  key: "frontend"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: backend
+  namespace: "%[1]s"
+  labels:
+    app: backend
+data:
+  key: "backend"
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies matching objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s -l app=frontend",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/frontend created", id)))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
+
+func TestApplyConfirm(t *testing.T) {
+	g := NewWithT(t)
+	id := "confirm-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id,
+			Namespace: id,
+		},
+	}
+
+	t.Run("aborts when not confirmed", func(t *testing.T) {
+		rootCmd.SetIn(strings.NewReader("n\n"))
+		defer rootCmd.SetIn(os.Stdin)
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --confirm",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("created \\(server dry run\\)"))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("applies when confirmed", func(t *testing.T) {
+		rootCmd.SetIn(strings.NewReader("y\n"))
+		defer rootCmd.SetIn(os.Stdin)
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --confirm",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+}
+
+func TestApplyAtomic(t *testing.T) {
+	g := NewWithT(t)
+	id := "atomic-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "good",
+			Namespace: id,
+		},
+	}
+
+	t.Run("creates objects", func(t *testing.T) {
+		dir, err := makeTestDir(id, []TestFile{
+			{
+				Name: "configs.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: good
+  namespace: "%[1]s"
+data:
+  key: "v1"
+`, id),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("rolls back on failure", func(t *testing.T) {
+		dir, err := makeTestDir(id, []TestFile{
+			{
+				Name: "configs.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: good
+  namespace: "%[1]s"
+data:
+  key: "v2"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: bad
+  namespace: "%[1]s"
+  annotations:
+    kustomizer.dev/depends-on: "%[1]s/good"
+data:
+  key:
+    invalid: "value"
+`, id),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s --atomic",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("rolling back"))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKeyWithValue("key", "v1"))
+	})
+}
+
+func TestApplyCreateNamespace(t *testing.T) {
+	g := NewWithT(t)
+	id := "create-ns-" + randStringRunes(5)
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates namespaces", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --create-namespace",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Namespace/%s created", id)))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Namespace/%s", id)))
+	})
+}
+
+func TestApplyFieldManager(t *testing.T) {
+	g := NewWithT(t)
+	id := "manager-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("sets field manager", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --field-manager team-a",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		var managers []string
+		for _, entry := range configMap.GetManagedFields() {
+			managers = append(managers, entry.Manager)
+		}
+		g.Expect(managers).To(ContainElement("team-a"))
+	})
+}
+
+func TestApplyForceConflicts(t *testing.T) {
+	g := NewWithT(t)
+	id := "conflicts-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id,
+			Namespace: id,
+		},
+		Data: map[string]string{
+			"key": "other",
+		},
+	}
+	err = envTestClient.Create(context.Background(), configMap, client.FieldOwner("other-manager"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("fails on conflicts", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(err.Error()).To(ContainSubstring("other-manager"))
+	})
+
+	t.Run("takes ownership of conflicting fields", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --force-conflicts",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKeyWithValue("key", "test"))
+	})
+}
+
+func TestApplyReconcileDisabled(t *testing.T) {
+	g := NewWithT(t)
+	id := "disabled-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id,
+			Namespace: id,
+		},
+	}
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("skips disabled objects", func(t *testing.T) {
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		configMap.SetAnnotations(map[string]string{"kustomizer.dev/reconcile": "disabled"})
+		configMap.Data["key"] = "frozen"
+		err = envTestClient.Update(context.Background(), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).NotTo(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s drifted", id, id)))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --force-conflicts",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKeyWithValue("key", "frozen"))
+	})
+}
+
+func TestApplyAdopt(t *testing.T) {
+	g := NewWithT(t)
+	id := "adopt-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id,
+			Namespace: id,
+		},
+		Data: map[string]string{
+			"key": "helm",
+		},
+	}
+	err = envTestClient.Create(context.Background(), configMap, client.FieldOwner("helm"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("adopts objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --adopt helm",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKeyWithValue("key", "test"))
+
+		for _, entry := range configMap.GetManagedFields() {
+			g.Expect(entry.Manager).NotTo(Equal("helm"))
+		}
+	})
+
+	t.Run("adopts objects from managers matched by prefix", func(t *testing.T) {
+		err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		// a field not set in the manifests, the owned fields don't drift
+		labels := configMap.GetLabels()
+		labels["app.kubernetes.io/managed-by"] = "Helm"
+		configMap.SetLabels(labels)
+		err = envTestClient.Update(context.Background(), configMap, client.FieldOwner("helm-controller"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --adopt helm",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		for _, entry := range configMap.GetManagedFields() {
+			g.Expect(entry.Manager).NotTo(Equal("helm-controller"))
+		}
+	})
+}
+
+func TestApplyPruneAllowlist(t *testing.T) {
+	g := NewWithT(t)
+	id := "allowlist-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("prunes only allowed kinds", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-1", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --prune-allowlist ConfigMap",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s deleted", id)))
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(secret), secret)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("prunes kept objects with a wider allowlist", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-1", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --prune-allowlist ConfigMap,Secret",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%[1]s/%[1]s deleted", id)))
+	})
+}
+
+func TestApplyStages(t *testing.T) {
+	g := NewWithT(t)
+	id := "stages-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "configs.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: canary
+  namespace: "%[1]s"
+  labels:
+    rollout-stage: "2"
+data:
+  key: "canary"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: primary
+  namespace: "%[1]s"
+  labels:
+    rollout-stage: "10"
+data:
+  key: "primary"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: common
+  namespace: "%[1]s"
+data:
+  key: "common"
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies objects in stage order", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s --stage-label rollout-stage",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(
+			"(?s)ConfigMap/%[1]s/common created.*ConfigMap/%[1]s/canary created.*ConfigMap/%[1]s/primary created", id)))
+	})
+}
+
+func TestApplyClientSide(t *testing.T) {
+	g := NewWithT(t)
+	id := "client-side-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --server-side=false",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s created", id)))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.GetAnnotations()).To(HaveKey(corev1.LastAppliedConfigAnnotation))
+	})
+
+	t.Run("skips unchanged objects", func(t *testing.T) {
+		configMap := &corev1.ConfigMap{}
+		err := envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		resourceVersion := configMap.GetResourceVersion()
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --server-side=false",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s unchanged", id)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.GetResourceVersion()).To(Equal(resourceVersion))
+		g.Expect(configMap.GetAnnotations()).To(HaveKey(corev1.LastAppliedConfigAnnotation))
+	})
+
+	t.Run("dry-runs without server-side apply", func(t *testing.T) {
+		changedDir, err := makeTestDir(id+"-changed", testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --server-side=false --dry-run",
+			id,
+			changedDir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s unchanged", id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%[1]s/%[1]s configured", id)))
+	})
+
+	deployment := func(image string) []TestFile {
+		return []TestFile{
+			{
+				Name: "deployment.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: "%[1]s"
+  namespace: "%[1]s"
+spec:
+  selector:
+    matchLabels:
+      app: "%[1]s"
+  template:
+    metadata:
+      labels:
+        app: "%[1]s"
+    spec:
+      containers:
+        - name: app
+          image: "%[2]s"
+`, id, image),
+			},
+		}
+	}
+
+	t.Run("merges the deployment containers", func(t *testing.T) {
+		deployDir, err := makeTestDir(id+"-deploy", deployment("nginx:1.22"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s-deploy -f %s -n %s --server-side=false",
+			id,
+			deployDir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		// add a container outside of kustomizer e.g. a sidecar injected by an admission webhook
+		deploy := &appsv1.Deployment{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, deploy)
+		g.Expect(err).NotTo(HaveOccurred())
+		deploy.Spec.Template.Spec.Containers = append(deploy.Spec.Template.Spec.Containers,
+			corev1.Container{Name: "sidecar", Image: "busybox:1.35"})
+		err = envTestClient.Update(context.Background(), deploy)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		deployDir, err = makeTestDir(id+"-deploy", deployment("nginx:1.23"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s-deploy -f %s -n %s --server-side=false",
+			id,
+			deployDir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Deployment/%[1]s/%[1]s configured", id)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, deploy)
+		g.Expect(err).NotTo(HaveOccurred())
+		containers := deploy.Spec.Template.Spec.Containers
+		g.Expect(containers).To(HaveLen(2))
+		g.Expect(containers[0].Image).To(Equal("nginx:1.23"))
+		g.Expect(containers[1].Name).To(Equal("sidecar"))
+	})
+}
+
+func TestApplyResume(t *testing.T) {
+	g := NewWithT(t)
+	id := "resume-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "configs.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "%[1]s"
+  namespace: "%[1]s"
+data:
+  key: "value"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: "%[1]s"
+  namespace: "%[1]s-missing"
+stringData:
+  key: "value"
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("fails to apply objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("resumes from the failed object", func(t *testing.T) {
+		err := createNamespace(id + "-missing")
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s --resume",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s skipped", id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%[1]s-missing/%[1]s created", id)))
+	})
+}
+
+func TestApplyCommonMetadata(t *testing.T) {
+	g := NewWithT(t)
+	id := "metadata-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("sets labels and annotations", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --label team=dev --annotation owner=dev@example.com",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.GetLabels()).To(HaveKeyWithValue("team", "dev"))
+		g.Expect(configMap.GetLabels()).To(HaveKeyWithValue("kustomizer.dev/inventory", fmt.Sprintf("%s_%s", id, id)))
+		g.Expect(configMap.GetLabels()).To(HaveKey("kustomizer.dev/checksum"))
+		g.Expect(configMap.GetAnnotations()).To(HaveKeyWithValue("owner", "dev@example.com"))
+	})
+
+	t.Run("fails for invalid labels", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --label team",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestApplySummary(t *testing.T) {
+	g := NewWithT(t)
+	id := "summary-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("prints created objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("summary: 3 created, 0 configured, 0 unchanged, 0 deleted"))
+	})
+
+	t.Run("prints unchanged objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("summary: 0 created, 0 configured, 3 unchanged, 0 deleted"))
+	})
+}
+
+func TestApplyMaxChanges(t *testing.T) {
+	g := NewWithT(t)
+	id := "max-changes-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("aborts when the limit is exceeded", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --max-changes 1",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("exceeds the limit"))
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("applies when within the limit", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --max-changes 3",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+}
+
+func TestApplySecretInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "secret-inv-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("stores the inventory in a secret", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --inventory-kind secret",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(secret), secret)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(secret.Data["resources"])).To(ContainSubstring(id))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("lists the secret inventories", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"get inventories -n %s --inventory-kind secret",
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(id))
+	})
+}
+
+func TestApplyCustomResourceInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "cr-inv-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("stores the inventory in a custom resource", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --inventory-kind inventory",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		inv := &unstructured.Unstructured{}
+		inv.SetAPIVersion("kustomizer.dev/v1")
+		inv.SetKind("Inventory")
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, inv)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		resources, _, _ := unstructured.NestedSlice(inv.Object, "spec", "resources")
+		g.Expect(resources).To(HaveLen(3))
+
+		conditions, _, _ := unstructured.NestedSlice(inv.Object, "status", "conditions")
+		g.Expect(conditions).To(HaveLen(1))
+	})
+
+	t.Run("lists the custom resource inventories", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"get inventories -n %s --inventory-kind inventory",
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(id))
+	})
+}
+
+func TestApplyInventoryLock(t *testing.T) {
+	g := NewWithT(t)
+	id := "lock-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	holder := "ci-job"
+	acquired := metav1.NewMicroTime(time.Now())
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("inv-%s-lock", id),
+			Namespace: id,
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity: &holder,
+			AcquireTime:    &acquired,
+			RenewTime:      &acquired,
+		},
+	}
+	err = envTestClient.Create(context.Background(), lease)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("fails when the inventory is locked", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --lock-timeout 10m",
+			id,
+			dir,
+			id,
+		))
+
+		t.Logf("\n%s", output)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("is locked by ci-job"))
+	})
+
+	t.Run("takes over a stale lock", func(t *testing.T) {
+		time.Sleep(2 * time.Second)
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --lock-timeout 1s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(lease), lease)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("applies to a namespace created by the manifests", func(t *testing.T) {
+		ns := id + "-new"
+		nsDir, err := makeTestDir(ns, []TestFile{
+			{
+				Name: "manifests.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: "%[1]s"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "%[1]s"
+  namespace: "%[1]s"
+data:
+  key: "test"
+`, ns),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s --lock-timeout 10m",
+			ns,
+			nsDir,
+			ns,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s created", ns)))
+	})
+}
+
+func TestApplyDerivedInventoryName(t *testing.T) {
+	g := NewWithT(t)
+	id := "derived-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("derives the name from the overlay path", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv -k %s -n %s",
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
+	})
+
+	t.Run("reapplies from the same path", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv -k %s -n %s",
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("rejects name collisions", func(t *testing.T) {
+		otherDir, err := makeTestDir(path.Join("other", id), testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv -k %s -n %s",
+			otherDir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("is already used by"))
+	})
+}
+
+func TestApplyPruneNamespaceScope(t *testing.T) {
+	g := NewWithT(t)
+	id := "scope-" + randStringRunes(5)
+	other := id + "-other"
+
+	for _, ns := range []string{id, other} {
+		err := createNamespace(ns)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	otherDir, err := makeTestDir(other, testManifests(id, other, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects in another namespace", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			otherDir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("refuses to prune outside the scope", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("outside the namespace scope"))
+
+		clusterCM := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, clusterCM)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("prunes with allow cross namespace", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --allow-cross-namespace-prune",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s deleted", other, id)))
+	})
+}
+
+func TestApplySignedInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "signed-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "signing-key",
+			Namespace: id,
+		},
+		StringData: map[string]string{
+			"key": randStringRunes(32),
+		},
+	}
+	err = envTestClient.Create(context.Background(), secret)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("signs the inventory", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --signing-secret %s",
+			id,
+			dir,
+			id,
+			secret.Name,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		cm := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: "inv-" + id, Namespace: id}, cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(cm.GetAnnotations()).To(HaveKey(inventoryOwner.Group + "/signature"))
+	})
+
+	t.Run("refuses to prune a modified inventory", func(t *testing.T) {
+		cm := &corev1.ConfigMap{}
+		err := envTestClient.Get(context.Background(), client.ObjectKey{Name: "inv-" + id, Namespace: id}, cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		cm.Data["resources"] = "[]"
+		err = envTestClient.Update(context.Background(), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --signing-secret %s",
+			id,
+			dir,
+			id,
+			secret.Name,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("signature verification failed"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --signing-secret %s --sign-existing",
+			id,
+			dir,
+			id,
+			secret.Name,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("signature verification failed"))
+	})
+
+	t.Run("signs an existing unsigned inventory", func(t *testing.T) {
+		name := id + "-existing"
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			name,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --signing-secret %s",
+			name,
+			dir,
+			id,
+			secret.Name,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("is not signed"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --signing-secret %s --sign-existing",
+			name,
+			dir,
+			id,
+			secret.Name,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cm := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: "inv-" + name, Namespace: id}, cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(cm.GetAnnotations()).To(HaveKey(inventoryOwner.Group + "/signature"))
+	})
+
+	t.Run("signs the inventory again without pruning", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --signing-secret %s",
+			id,
+			dir,
+			id,
+			secret.Name,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --signing-secret %s",
+			id,
+			dir,
+			id,
+			secret.Name,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+}
+
+func TestApplySOPSEncrypted(t *testing.T) {
+	g := NewWithT(t)
+	id := "sops-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "secret.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: "%[1]s"
+  namespace: "%[1]s"
+stringData:
+  key: ENC[AES256_GCM,data:aGVsbG8=,iv:aGVsbG8=,tag:aGVsbG8=,type:str]
+sops:
+  age:
+  - recipient: age1g8vcnjz2ck2kdz6wesjtv0wfrx9tyavkqx5k9dj2ltk6xmt6432s8ds2te
+  version: 3.7.3
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("rejects encrypted objects without --sops", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("SOPS encrypted objects found"))
+
+		secret := &corev1.Secret{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, secret)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
 }
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type spdxDocument struct {
+	SPDXVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SPDXID            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	Packages          []spdxPackage    `json:"packages"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string `json:"name"`
+	SPDXID           string `json:"SPDXID"`
+	VersionInfo      string `json:"versionInfo,omitempty"`
+	DownloadLocation string `json:"downloadLocation"`
+	FilesAnalyzed    bool   `json:"filesAnalyzed"`
+}
+
+// newSBOM returns an SPDX document that lists the container images referenced by the objects of the artifact.
+func newSBOM(digest string, objects []*unstructured.Unstructured) ([]byte, error) {
+	images := make(map[string]bool)
+	for _, object := range objects {
+		for _, image := range getContainerImages(object) {
+			images[image] = true
+		}
+	}
+	var refs []string
+	for image := range images {
+		refs = append(refs, image)
+	}
+	sort.Strings(refs)
+
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              digest,
+		DocumentNamespace: fmt.Sprintf("https://kustomizer.dev/spdx/%s", strings.ReplaceAll(digest, "@", "/")),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{fmt.Sprintf("Tool: kustomizer-v%s", VERSION)},
+		},
+		Packages: []spdxPackage{},
+	}
+	for i, ref := range refs {
+		name, version := splitImageRef(ref)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             name,
+			SPDXID:           fmt.Sprintf("SPDXRef-Image-%d", i),
+			VersionInfo:      version,
+			DownloadLocation: ref,
+		})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// splitImageRef splits the container image reference into the repository and the tag or digest.
+func splitImageRef(ref string) (string, string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []inTotoSubject `json:"subject"`
+	Predicate     buildPredicate  `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type buildPredicate struct {
+	Builder    map[string]string `json:"builder"`
+	BuildType  string            `json:"buildType"`
+	Invocation map[string]any    `json:"invocation"`
+	Materials  []buildMaterial   `json:"materials,omitempty"`
+}
+
+type buildMaterial struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// newBuildStatement returns an in-toto statement with a SLSA provenance predicate
+// that records the build inputs of the artifact.
+func newBuildStatement(digest string, kustomizePath string, filePaths, patchPaths []string, source, revision string) ([]byte, error) {
+	name, hash := splitImageRef(digest)
+	alg, hex, _ := strings.Cut(hash, ":")
+
+	statement := inTotoStatement{
+		Type:          "https://in-toto.io/Statement/v0.1",
+		PredicateType: "https://slsa.dev/provenance/v0.2",
+		Subject: []inTotoSubject{
+			{Name: name, Digest: map[string]string{alg: hex}},
+		},
+		Predicate: buildPredicate{
+			Builder:   map[string]string{"id": fmt.Sprintf("kustomizer/v%s", VERSION)},
+			BuildType: "https://kustomizer.dev/push/v1",
+			Invocation: map[string]any{
+				"parameters": map[string]any{
+					"kustomize": kustomizePath,
+					"filename":  filePaths,
+					"patch":     patchPaths,
+				},
+			},
+		},
+	}
+
+	if source != "" {
+		material := buildMaterial{URI: source}
+		// the revision is in the format '<branch|tag>/<commit-sha>'
+		if revision != "" {
+			material.Digest = map[string]string{"sha1": revision[strings.LastIndex(revision, "/")+1:]}
+		}
+		statement.Predicate.Materials = append(statement.Predicate.Materials, material)
+	}
+
+	return json.MarshalIndent(statement, "", "  ")
+}
@@ -20,7 +20,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -32,15 +34,24 @@ import (
 	"filippo.io/age"
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/kustomize/api/krusty"
 	kustypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/yaml"
 
+	"github.com/stefanprodan/kustomizer/pkg/dependency"
+	"github.com/stefanprodan/kustomizer/pkg/fetch"
+	"github.com/stefanprodan/kustomizer/pkg/filter"
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
 
+// stdinPath is the filename value used to read manifests from stdin.
+const stdinPath = "-"
+
 var buildInventoryCmd = &cobra.Command{
 	Use:     "inventory",
 	Aliases: []string{"inv"},
@@ -58,34 +69,69 @@ var buildInventoryCmd = &cobra.Command{
 
   # Build the inventory from a local overlay and print the resulting multi-doc YAML
   kustomizer build inventory my-app -n apps -k ./overlays/prod
+
+  # Build the inventory from a local overlay and print only the Deployments with names starting with 'web'
+  kustomizer build inventory my-app -n apps -k ./overlays/prod --include kind=Deployment,name=web*
+
+  # Build the inventory from a local overlay and add a prefix to the names of all objects
+  kustomizer build inventory my-app -n apps -k ./overlays/prod --name-prefix dev-
+
+  # Build the inventory from manifests read from stdin
+  helm template my-app ./charts/my-app | kustomizer build inventory my-app -n apps -f -
 `,
 	RunE: runBuildInventoryCmd,
 }
 
 type buildInventoryFlags struct {
-	artifact      []string
-	filename      []string
-	kustomize     string
-	patch         []string
-	output        string
-	ageIdentities string
+	artifact        []string
+	filename        []string
+	kustomize       string
+	patch           []string
+	output          string
+	ageIdentities   string
+	httpHeaders     []string
+	httpToken       string
+	httpChecksums   []string
+	include         []string
+	exclude         []string
+	targetNamespace string
+	namePrefix      string
+	nameSuffix      string
 }
 
 var buildInventoryArgs buildInventoryFlags
 
 func init() {
 	buildInventoryCmd.Flags().StringSliceVarP(&buildInventoryArgs.filename, "filename", "f", nil,
-		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively.")
+		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively. Use '-' to read from stdin or an HTTP(S) URL to download the manifests, the downloads are limited to 50MiB.")
 	buildInventoryCmd.Flags().StringVarP(&buildInventoryArgs.kustomize, "kustomize", "k", "",
 		"Path to a directory that contains a kustomization.yaml.")
 	buildInventoryCmd.Flags().StringSliceVarP(&buildInventoryArgs.artifact, "artifact", "a", nil,
-		"OCI artifact URL in the format 'oci://registry/org/repo:tag' e.g. 'oci://docker.io/stefanprodan/app-deploy:v1.0.0'.")
+		"OCI artifact URL in the format 'oci://registry/org/repo:tag' e.g. 'oci://docker.io/stefanprodan/app-deploy:v1.0.0', "+
+			"the tag can be a semantic version constraint e.g. 'oci://docker.io/stefanprodan/app-deploy:^1.0'.")
 	buildInventoryCmd.Flags().StringSliceVarP(&buildInventoryArgs.patch, "patch", "p", nil,
 		"Path to a kustomization file that contains a list of patches.")
 	buildInventoryCmd.Flags().StringVarP(&buildInventoryArgs.output, "output", "o", "yaml",
 		"Write manifests to stdout in YAML or JSON format.")
 	buildInventoryCmd.Flags().StringVar(&buildInventoryArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	buildInventoryCmd.Flags().StringArrayVar(&buildInventoryArgs.httpHeaders, "http-header", nil,
+		"HTTP header in the format 'key: value' added to the requests for manifests hosted at HTTP(S) URLs.")
+	buildInventoryCmd.Flags().StringVar(&buildInventoryArgs.httpToken, "http-token", "",
+		"Bearer token used to authenticate the requests for manifests hosted at HTTP(S) URLs.")
+	buildInventoryCmd.Flags().StringSliceVar(&buildInventoryArgs.httpChecksums, "http-checksum", nil,
+		"SHA-256 checksum of the manifests hosted at HTTP(S) URLs, specified in the same order as the URLs.")
+	buildInventoryCmd.Flags().StringArrayVar(&buildInventoryArgs.include, "include", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', only the matching objects are processed. The values can contain shell patterns e.g. 'name=web*'.")
+	buildInventoryCmd.Flags().StringArrayVar(&buildInventoryArgs.exclude, "exclude", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', the matching objects are skipped. The values can contain shell patterns e.g. 'kind=Secret'.")
+	buildInventoryCmd.Flags().StringVar(&buildInventoryArgs.targetNamespace, "target-namespace", "",
+		"Set or override the namespace of all the namespaced objects, cluster-scoped objects are left untouched. "+
+			"The scope of each kind is looked up on the cluster, when the cluster is unreachable only the built-in kinds and the kinds defined by the CRDs found in the manifests are supported.")
+	buildInventoryCmd.Flags().StringVar(&buildInventoryArgs.namePrefix, "name-prefix", "",
+		"Prefix added to the names of all objects, the references to the renamed objects are updated accordingly.")
+	buildInventoryCmd.Flags().StringVar(&buildInventoryArgs.nameSuffix, "name-suffix", "",
+		"Suffix added to the names of all objects, the references to the renamed objects are updated accordingly.")
 
 	buildCmd.AddCommand(buildInventoryCmd)
 }
@@ -103,11 +149,34 @@ func runBuildInventoryCmd(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	objects, _, err := buildManifests(ctx, buildInventoryArgs.kustomize, buildInventoryArgs.filename, buildInventoryArgs.artifact, buildInventoryArgs.patch, identities)
+	fetcher, err := newHTTPFetcher(buildInventoryArgs.filename, buildInventoryArgs.httpHeaders, buildInventoryArgs.httpToken, buildInventoryArgs.httpChecksums)
 	if err != nil {
 		return err
 	}
 
+	objects, _, err := buildManifests(ctx, buildInventoryArgs.kustomize, buildInventoryArgs.filename, buildInventoryArgs.artifact, buildInventoryArgs.patch, identities, fetcher)
+	if err != nil {
+		return err
+	}
+
+	objects, err = filterObjects(objects, buildInventoryArgs.include, buildInventoryArgs.exclude)
+	if err != nil {
+		return err
+	}
+
+	if buildInventoryArgs.targetNamespace != "" {
+		if err := setTargetNamespace(objects, buildInventoryArgs.targetNamespace); err != nil {
+			return err
+		}
+	}
+
+	if buildInventoryArgs.namePrefix != "" || buildInventoryArgs.nameSuffix != "" {
+		objects, err = renameObjects(objects, buildInventoryArgs.namePrefix, buildInventoryArgs.nameSuffix)
+		if err != nil {
+			return err
+		}
+	}
+
 	sort.Sort(ssa.SortableUnstructureds(objects))
 
 	switch buildInventoryArgs.output {
@@ -130,7 +199,7 @@ func runBuildInventoryCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func buildManifests(ctx context.Context, kustomizePath string, filePaths []string, artifacts []string, patchPaths []string, identities []age.Identity) ([]*unstructured.Unstructured, []string, error) {
+func buildManifests(ctx context.Context, kustomizePath string, filePaths []string, artifacts []string, patchPaths []string, identities []age.Identity, fetcher fetch.Fetcher) ([]*unstructured.Unstructured, []string, error) {
 	objects := make([]*unstructured.Unstructured, 0)
 	digests := []string{}
 	if kustomizePath != "" {
@@ -147,7 +216,45 @@ func buildManifests(ctx context.Context, kustomizePath string, filePaths []strin
 	}
 
 	if len(filePaths) > 0 {
-		manifests, err := scanForManifests(filePaths)
+		stdinCount := 0
+		for _, filePath := range filePaths {
+			if filePath == stdinPath {
+				stdinCount++
+			}
+		}
+		if stdinCount > 1 {
+			return nil, nil, fmt.Errorf("'-f %s' can be specified only once, stdin can't be read more than once", stdinPath)
+		}
+
+		var paths []string
+		for _, filePath := range filePaths {
+			if fetch.IsURL(filePath) {
+				if fetcher == nil {
+					fetcher = &fetch.HTTPFetcher{}
+				}
+				data, err := fetcher.Fetch(ctx, filePath)
+				if err != nil {
+					return nil, nil, fmt.Errorf("downloading %s failed: %w", filePath, err)
+				}
+				objs, err := readManifests(bytes.NewReader(data))
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: %w", filePath, err)
+				}
+				objects = append(objects, objs...)
+				continue
+			}
+			if filePath == stdinPath {
+				objs, err := readManifests(bufio.NewReader(rootCmd.InOrStdin()))
+				if err != nil {
+					return nil, nil, fmt.Errorf("stdin: %w", err)
+				}
+				objects = append(objects, objs...)
+				continue
+			}
+			paths = append(paths, filePath)
+		}
+
+		manifests, err := scanForManifests(paths)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -157,38 +264,34 @@ func buildManifests(ctx context.Context, kustomizePath string, filePaths []strin
 				return nil, nil, err
 			}
 
-			objs, err := ssa.ReadObjects(bufio.NewReader(ms))
+			objs, err := readManifests(bufio.NewReader(ms))
 			ms.Close()
 			if err != nil {
 				return nil, nil, fmt.Errorf("%s: %w", manifest, err)
 			}
-
-			for _, obj := range objs {
-				if ssa.IsKubernetesObject(obj) && !ssa.IsKustomization(obj) {
-					objects = append(objects, obj)
-				}
-			}
+			objects = append(objects, objs...)
 		}
 	}
 
 	if len(artifacts) > 0 {
 		for _, ociURL := range artifacts {
-			url, err := registry.ParseURL(ociURL)
+			baseURL, variant := registry.SplitVariant(ociURL)
+			url, err := registry.ResolveURL(ctx, registryOpts, baseURL, "")
 			if err != nil {
 				return nil, nil, fmt.Errorf("parsing %s failed: %w", ociURL, err)
 			}
 
-			yml, meta, err := registry.Pull(ctx, url, identities)
+			objs, meta, err := pullArtifactObjects(ctx, url, variant, identities)
 			if err != nil {
 				return nil, nil, fmt.Errorf("pulling %s failed: %w", ociURL, err)
 			}
 
-			digests = append(digests, meta.Digest)
-
-			objs, err := ssa.ReadObjects(strings.NewReader(yml))
-			if err != nil {
-				return nil, nil, fmt.Errorf("extracting manifests from %s failed: %w", ociURL, err)
+			// record the variant along with the digest so that the same layer is pulled on rollback
+			digest := meta.Digest
+			if variant != "" {
+				digest += registry.VariantSeparator + variant
 			}
+			digests = append(digests, digest)
 			objects = append(objects, objs...)
 		}
 	}
@@ -211,6 +314,242 @@ func buildManifests(ctx context.Context, kustomizePath string, filePaths []strin
 	return objects, digests, nil
 }
 
+// pullArtifactObjects downloads the artifact and returns its Kubernetes objects, when a variant
+// is specified only the layer with that name is pulled. The artifacts that contain kustomize sources are built locally.
+func pullArtifactObjects(ctx context.Context, url string, variant string, identities []age.Identity) ([]*unstructured.Unstructured, *registry.Metadata, error) {
+	var yml string
+	var meta *registry.Metadata
+	var err error
+	if variant != "" {
+		var contents []registry.Content
+		contents, meta, err = registry.PullLayers(ctx, registryOpts, url, identities, variant)
+		yml = registry.JoinContents(contents)
+	} else {
+		yml, meta, err = registry.Pull(ctx, registryOpts, url, identities)
+	}
+	if errors.Is(err, registry.ErrSourceArtifact) {
+		tmpDir, err := os.MkdirTemp("", "source")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		meta, err = registry.PullSource(ctx, registryOpts, url, tmpDir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data, err := buildKustomization(tmpDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("building sources failed: %w", err)
+		}
+
+		objects, err := ssa.ReadObjects(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("extracting manifests failed: %w", err)
+		}
+		return objects, meta, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects, err := ssa.ReadObjects(strings.NewReader(yml))
+	if err != nil {
+		return nil, nil, fmt.Errorf("extracting manifests failed: %w", err)
+	}
+	return objects, meta, nil
+}
+
+// newHTTPFetcher returns a fetcher configured with the given headers, token and checksums,
+// the checksums are matched in order to the HTTP(S) URLs found in the file paths.
+func newHTTPFetcher(filePaths []string, headers []string, token string, checksums []string) (*fetch.HTTPFetcher, error) {
+	fetcher := &fetch.HTTPFetcher{
+		Headers:   make(map[string]string),
+		Token:     token,
+		Checksums: make(map[string]string),
+	}
+
+	for _, header := range headers {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid header '%s', must be in the format 'key: value'", header)
+		}
+		fetcher.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	if len(checksums) > 0 {
+		var urls []string
+		for _, filePath := range filePaths {
+			if fetch.IsURL(filePath) {
+				urls = append(urls, filePath)
+			}
+		}
+		if len(urls) != len(checksums) {
+			return nil, fmt.Errorf("the number of checksums (%d) must match the number of HTTP(S) URLs (%d)", len(checksums), len(urls))
+		}
+		for i, url := range urls {
+			fetcher.Checksums[url] = checksums[i]
+		}
+	}
+
+	return fetcher, nil
+}
+
+// filterObjects returns the objects that match the include filters and don't match the exclude filters.
+func filterObjects(objects []*unstructured.Unstructured, includes []string, excludes []string) ([]*unstructured.Unstructured, error) {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return objects, nil
+	}
+
+	includeFilters, err := filter.ParseAll(includes)
+	if err != nil {
+		return nil, err
+	}
+
+	excludeFilters, err := filter.ParseAll(excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.Objects(objects, includeFilters, excludeFilters), nil
+}
+
+// builtinKinds maps the built-in Kubernetes kinds to their scope, true for namespaced kinds.
+// It's used to resolve the scope of the objects when the cluster is unreachable.
+var builtinKinds = map[schema.GroupKind]bool{
+	{Group: "", Kind: "ConfigMap"}:                                                    true,
+	{Group: "", Kind: "Endpoints"}:                                                    true,
+	{Group: "", Kind: "Event"}:                                                        true,
+	{Group: "", Kind: "LimitRange"}:                                                   true,
+	{Group: "", Kind: "PersistentVolumeClaim"}:                                        true,
+	{Group: "", Kind: "Pod"}:                                                          true,
+	{Group: "", Kind: "PodTemplate"}:                                                  true,
+	{Group: "", Kind: "ReplicationController"}:                                        true,
+	{Group: "", Kind: "ResourceQuota"}:                                                true,
+	{Group: "", Kind: "Secret"}:                                                       true,
+	{Group: "", Kind: "Service"}:                                                      true,
+	{Group: "", Kind: "ServiceAccount"}:                                               true,
+	{Group: "", Kind: "Namespace"}:                                                    false,
+	{Group: "", Kind: "Node"}:                                                         false,
+	{Group: "", Kind: "PersistentVolume"}:                                             false,
+	{Group: "apps", Kind: "ControllerRevision"}:                                       true,
+	{Group: "apps", Kind: "DaemonSet"}:                                                true,
+	{Group: "apps", Kind: "Deployment"}:                                               true,
+	{Group: "apps", Kind: "ReplicaSet"}:                                               true,
+	{Group: "apps", Kind: "StatefulSet"}:                                              true,
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}:                           true,
+	{Group: "batch", Kind: "CronJob"}:                                                 true,
+	{Group: "batch", Kind: "Job"}:                                                     true,
+	{Group: "coordination.k8s.io", Kind: "Lease"}:                                     true,
+	{Group: "discovery.k8s.io", Kind: "EndpointSlice"}:                                true,
+	{Group: "events.k8s.io", Kind: "Event"}:                                           true,
+	{Group: "networking.k8s.io", Kind: "Ingress"}:                                     true,
+	{Group: "networking.k8s.io", Kind: "NetworkPolicy"}:                               true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                                false,
+	{Group: "policy", Kind: "PodDisruptionBudget"}:                                    true,
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:                                true,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:                         true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                         false,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                  false,
+	{Group: "storage.k8s.io", Kind: "CSIStorageCapacity"}:                             true,
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                      false,
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                        false,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                   false,
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                               false,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:     false,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:   false,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        false,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: false,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                 false,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                             false,
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                 false,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                       false,
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:       false,
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                      false,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                               false,
+}
+
+// setTargetNamespace sets the given namespace on all the namespaced objects,
+// the scope of each kind is resolved using the cluster API discovery or,
+// for custom resources, from the CRDs found in the given objects.
+// The depends-on references to the moved objects are updated accordingly.
+// When the cluster is unreachable, the scope of the built-in kinds is taken from builtinKinds.
+func setTargetNamespace(objects []*unstructured.Unstructured, namespace string) error {
+	mapper, err := newRESTMapper(kubeconfigArgs)
+	if err != nil {
+		logger.Println("discovery client init failed, using the built-in kinds scope, error:", err)
+		mapper = nil
+	}
+
+	crdScopes := make(map[schema.GroupKind]string)
+	for _, object := range objects {
+		if object.GetKind() == "CustomResourceDefinition" {
+			group, _, _ := unstructured.NestedString(object.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(object.Object, "spec", "names", "kind")
+			scope, _, _ := unstructured.NestedString(object.Object, "spec", "scope")
+			crdScopes[schema.GroupKind{Group: group, Kind: kind}] = scope
+		}
+	}
+
+	moved := make(map[dependency.ObjectRef]dependency.ObjectRef, len(objects))
+	for _, object := range objects {
+		gvk := object.GroupVersionKind()
+		var namespaced bool
+		var mapping *meta.RESTMapping
+		err := errors.New("discovery client unavailable")
+		if mapper != nil {
+			mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+		switch {
+		case err == nil:
+			namespaced = mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		case meta.IsNoMatchError(err):
+			scope, ok := crdScopes[gvk.GroupKind()]
+			if !ok {
+				return fmt.Errorf("%s scope not found, error: %w", ssa.FmtUnstructured(object), err)
+			}
+			namespaced = scope == string(apiextensionsv1.NamespaceScoped)
+		default:
+			if scope, ok := crdScopes[gvk.GroupKind()]; ok {
+				namespaced = scope == string(apiextensionsv1.NamespaceScoped)
+				break
+			}
+			builtin, ok := builtinKinds[gvk.GroupKind()]
+			if !ok {
+				return fmt.Errorf("%s scope lookup failed, error: %w", ssa.FmtUnstructured(object), err)
+			}
+			namespaced = builtin
+		}
+
+		prev := dependency.RefOf(object)
+		if namespaced {
+			object.SetNamespace(namespace)
+		}
+		moved[prev] = dependency.RefOf(object)
+	}
+	dependency.Rewrite(objects, moved)
+
+	return nil
+}
+
+// readManifests decodes the multi-doc YAML from the given reader
+// and returns the Kubernetes objects, ignoring Kustomizations.
+func readManifests(r io.Reader) ([]*unstructured.Unstructured, error) {
+	objs, err := ssa.ReadObjects(r)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0)
+	for _, obj := range objs {
+		if ssa.IsKubernetesObject(obj) && !ssa.IsKustomization(obj) {
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
 func scanForManifests(paths []string) ([]string, error) {
 	var manifests []string
 
@@ -378,3 +717,80 @@ func applyPatches(kFilePath string, objects []*unstructured.Unstructured) ([]byt
 
 	return resources, nil
 }
+
+// renameObjects adds the prefix and suffix to the names of the given objects using the kustomize
+// name transformers, the references to the renamed objects e.g. Service targets, ConfigMap
+// and Secret references in containers and volumes, and the depends-on references are updated accordingly.
+func renameObjects(objects []*unstructured.Unstructured, prefix, suffix string) ([]*unstructured.Unstructured, error) {
+	kustomizeBuildMutex.Lock()
+	defer kustomizeBuildMutex.Unlock()
+
+	fs := filesys.MakeFsInMemory()
+	kustomization := kustypes.Kustomization{}
+	kustomization.APIVersion = kustypes.KustomizationVersion
+	kustomization.Kind = kustypes.KustomizationKind
+
+	const input = "resources.yaml"
+	kustomization.Resources = append(kustomization.Resources, input)
+	yml, err := ssa.ObjectsToYAML(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.WriteFile(input, []byte(yml)); err != nil {
+		return nil, err
+	}
+
+	kustomization.NamePrefix = prefix
+	kustomization.NameSuffix = suffix
+
+	d, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.WriteFile("kustomization.yaml", d); err != nil {
+		return nil, err
+	}
+
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	m, err := k.Run(fs, ".")
+	if err != nil {
+		return nil, fmt.Errorf("renaming objects failed, error: %w", err)
+	}
+
+	resources, err := m.AsYaml()
+	if err != nil {
+		return nil, err
+	}
+
+	renamed, err := ssa.ReadObjects(bytes.NewReader(resources))
+	if err != nil {
+		return nil, err
+	}
+
+	// kustomize reorders the objects, the renamed objects are matched by kind, namespace and name
+	moved := make(map[dependency.ObjectRef]dependency.ObjectRef, len(objects))
+	for _, object := range objects {
+		prev := dependency.RefOf(object)
+		for _, candidate := range []string{prefix + prev.Name + suffix, prev.Name} {
+			ref := dependency.ObjectRef{Kind: prev.Kind, Namespace: prev.Namespace, Name: candidate}
+			if containsRef(renamed, ref) {
+				moved[prev] = ref
+				break
+			}
+		}
+	}
+	dependency.Rewrite(renamed, moved)
+
+	return renamed, nil
+}
+
+func containsRef(objects []*unstructured.Unstructured, ref dependency.ObjectRef) bool {
+	for _, object := range objects {
+		if dependency.RefOf(object) == ref {
+			return true
+		}
+	}
+	return false
+}
@@ -17,7 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -67,6 +73,115 @@ patches:
 		g.Expect(output).To(MatchRegexp(id))
 	})
 
+	t.Run("builds filtered objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"build inv %s -f %s -n %s -o yaml --include name=%s* --exclude kind=Secret",
+			id,
+			dir,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("kind: ConfigMap"))
+		g.Expect(output).NotTo(MatchRegexp("kind: Secret"))
+	})
+
+	t.Run("builds objects in target namespace", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"build inv %s -k %s -n %s -o yaml --target-namespace %s-target",
+			id,
+			dir,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("namespace: %s-target", id)))
+		g.Expect(output).NotTo(MatchRegexp(fmt.Sprintf("namespace: %s\n", id)))
+	})
+
+	t.Run("builds objects with name prefix and suffix", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"build inv %s -k %s -n %s -o yaml --name-prefix dev- --name-suffix -v2",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("name: dev-%s-v2", id)))
+	})
+
+	t.Run("builds objects from stdin", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		rootCmd.SetIn(strings.NewReader(string(data)))
+		defer rootCmd.SetIn(os.Stdin)
+
+		output, err := executeCommand(fmt.Sprintf(
+			"build inv %s -f - -n %s -o yaml",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("kind: ConfigMap"))
+		g.Expect(output).NotTo(MatchRegexp("kind: Secret"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"build inv %s -f - -f - -n %s -o yaml",
+			id,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("can be specified only once"))
+	})
+
+	t.Run("builds objects from URL", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+id {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write(data)
+		}))
+		defer server.Close()
+
+		output, err := executeCommand(fmt.Sprintf(
+			"build inv %s -f %s -n %s -o yaml --http-token %s --http-checksum %x",
+			id,
+			server.URL+"/config.yaml",
+			id,
+			id,
+			sha256.Sum256(data),
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("kind: ConfigMap"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"build inv %s -f %s -n %s -o yaml --http-token %s --http-checksum %x",
+			id,
+			server.URL+"/config.yaml",
+			id,
+			id,
+			sha256.Sum256([]byte(id)),
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(MatchRegexp("checksum mismatch"))
+	})
+
 	t.Run("patch objects", func(t *testing.T) {
 		output, err := executeCommand(fmt.Sprintf(
 			"build inv %s -f %s -n %s -p %s -o yaml",
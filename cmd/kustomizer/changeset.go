@@ -0,0 +1,172 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"gomodules.xyz/jsonpatch/v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stefanprodan/kustomizer/pkg/drift"
+)
+
+// unknownAction is the action reported for the objects that can't be read due to insufficient permissions.
+const unknownAction = "unknown"
+
+// changeSetEntry is the machine-readable representation of a change set entry.
+type changeSetEntry struct {
+	// Subject represents the object ID in the format 'kind/namespace/name'.
+	Subject string `json:"subject"`
+
+	// Action is the action taken (or that would be taken) for this object.
+	Action string `json:"action"`
+
+	// Diff holds the unified diff between the in-cluster and the desired state.
+	Diff string `json:"diff,omitempty"`
+
+	// Patch holds the JSON patch operations that transform the in-cluster state into the desired state.
+	Patch []jsonpatch.Operation `json:"patch,omitempty"`
+
+	// Paths holds the list of changed fields.
+	Paths []string `json:"paths,omitempty"`
+
+	// Changes holds the in-cluster and the desired values of the changed fields.
+	Changes []drift.FieldChange `json:"changes,omitempty"`
+}
+
+// changeSetPrinter writes the change set entries in a human-readable format
+// or collects them for printing as a JSON or YAML list, or as a Markdown or HTML report.
+type changeSetPrinter struct {
+	output  string
+	text    func(entry changeSetEntry)
+	entries []changeSetEntry
+	counts  map[string]int
+	failed  []string
+}
+
+func newChangeSetPrinter(output string, text func(entry changeSetEntry)) (*changeSetPrinter, error) {
+	switch output {
+	case "", "text", "json", "yaml", "markdown", "html":
+	default:
+		return nil, fmt.Errorf("unsupported output, can be text, json, yaml, markdown or html")
+	}
+
+	return &changeSetPrinter{
+		output:  output,
+		text:    text,
+		entries: []changeSetEntry{},
+		counts:  make(map[string]int),
+	}, nil
+}
+
+// Print writes the given entry.
+func (p *changeSetPrinter) Print(entry ssa.ChangeSetEntry) {
+	p.PrintDiff(entry, "")
+}
+
+// PrintDiff writes the given entry and its diff.
+func (p *changeSetPrinter) PrintDiff(entry ssa.ChangeSetEntry, diff string) {
+	p.PrintEntry(changeSetEntry{
+		Subject: entry.Subject,
+		Action:  entry.Action,
+		Diff:    diff,
+	})
+}
+
+// PrintEntry writes the given entry with its diff, JSON patch or changed fields.
+func (p *changeSetPrinter) PrintEntry(e changeSetEntry) {
+	p.counts[e.Action]++
+
+	if p.isStructured() {
+		p.entries = append(p.entries, e)
+		return
+	}
+	p.text(e)
+}
+
+// Fail records an object that failed to apply.
+func (p *changeSetPrinter) Fail(subject string, err error) {
+	p.failed = append(p.failed, fmt.Sprintf("%s failed: %v", subject, err))
+}
+
+// Failures returns the objects that failed to apply along with their errors.
+func (p *changeSetPrinter) Failures() []string {
+	return p.failed
+}
+
+// Summary returns the number of printed entries for each action and the number of failed objects.
+func (p *changeSetPrinter) Summary() string {
+	summary := fmt.Sprintf("%d created, %d configured, %d unchanged, %d deleted",
+		p.counts[string(ssa.CreatedAction)],
+		p.counts[string(ssa.ConfiguredAction)],
+		p.counts[string(ssa.UnchangedAction)],
+		p.counts[string(ssa.DeletedAction)])
+	if p.counts[unknownAction] > 0 {
+		summary += fmt.Sprintf(", %d unknown", p.counts[unknownAction])
+	}
+	if len(p.failed) > 0 {
+		summary += fmt.Sprintf(", %d failed", len(p.failed))
+	}
+	return summary
+}
+
+// Changes returns the number of printed entries that were created, configured or deleted.
+func (p *changeSetPrinter) Changes() int {
+	return p.counts[string(ssa.CreatedAction)] +
+		p.counts[string(ssa.ConfiguredAction)] +
+		p.counts[string(ssa.DeletedAction)]
+}
+
+// HasUnknown returns true if the state of any of the printed entries is unknown due to insufficient permissions.
+func (p *changeSetPrinter) HasUnknown() bool {
+	return p.counts[unknownAction] > 0
+}
+
+// HasChanges returns true if any of the printed entries was created, configured or deleted.
+func (p *changeSetPrinter) HasChanges() bool {
+	return p.Changes() > 0
+}
+
+// Flush writes the collected entries to stdout when the output is JSON, YAML, Markdown or HTML.
+func (p *changeSetPrinter) Flush() error {
+	switch p.output {
+	case "json":
+		data, err := json.MarshalIndent(p.entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		rootCmd.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(p.entries)
+		if err != nil {
+			return err
+		}
+		rootCmd.Print(string(data))
+	case "markdown":
+		return writeMarkdownReport(rootCmd.OutOrStdout(), p.entries, p.counts)
+	case "html":
+		return writeHTMLReport(rootCmd.OutOrStdout(), p.entries, p.counts)
+	}
+	return nil
+}
+
+func (p *changeSetPrinter) isStructured() bool {
+	return p.output != "" && p.output != "text"
+}
@@ -19,8 +19,10 @@ package main
 
 import (
 	"fmt"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/rest"
@@ -33,6 +35,7 @@ func newScheme() *apiruntime.Scheme {
 	scheme := apiruntime.NewScheme()
 	_ = apiextensionsv1.AddToScheme(scheme)
 	_ = corev1.AddToScheme(scheme)
+	_ = coordinationv1.AddToScheme(scheme)
 	return scheme
 }
 
@@ -70,6 +73,15 @@ func newKubeStatusPoller(rcg genericclioptions.RESTClientGetter) (*polling.Statu
 	return polling.NewStatusPoller(c, restMapper, polling.Options{}), nil
 }
 
+func newRESTMapper(rcg genericclioptions.RESTClientGetter) (meta.RESTMapper, error) {
+	kubeConfig, err := newKubeConfig(rcg)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiutil.NewDynamicRESTMapper(kubeConfig)
+}
+
 func newKubeConfig(rcg genericclioptions.RESTClientGetter) (*rest.Config, error) {
 	cfg, err := rcg.ToRESTConfig()
 	if err != nil {
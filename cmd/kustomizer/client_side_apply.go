@@ -0,0 +1,234 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/mergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clientSideChange holds the outcome of comparing an object with its in-cluster state
+// using the last applied configuration annotation.
+type clientSideChange struct {
+	action         ssa.Action
+	appliedObject  *unstructured.Unstructured
+	existingObject *unstructured.Unstructured
+	patchType      types.PatchType
+	patch          []byte
+}
+
+// newClientSideChange computes the three-way merge patch of the given object, the same way 'kubectl apply' does.
+// The objects not found on the cluster are reported as created, while the excluded objects
+// and the objects with an empty patch are reported as unchanged.
+func newClientSideChange(ctx context.Context, kubeClient client.Client, obj *unstructured.Unstructured,
+	exclusions map[string]string) (*clientSideChange, error) {
+	appliedObject, err := withLastAppliedConfiguration(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(obj.GroupVersionKind())
+	err = kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existingObject)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(obj), err)
+		}
+		return &clientSideChange{action: ssa.CreatedAction, appliedObject: appliedObject}, nil
+	}
+
+	if ssa.AnyInMetadata(existingObject, exclusions) {
+		return &clientSideChange{action: ssa.UnchangedAction}, nil
+	}
+
+	original := []byte(existingObject.GetAnnotations()[corev1.LastAppliedConfigAnnotation])
+	modified, err := appliedObject.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	current, err := existingObject.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	patchType, patch, err := threeWayMergePatch(obj.GroupVersionKind(), original, modified, current)
+	if err != nil {
+		return nil, fmt.Errorf("%s patch failed, error: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	if string(patch) == "{}" {
+		return &clientSideChange{action: ssa.UnchangedAction}, nil
+	}
+
+	return &clientSideChange{
+		action:         ssa.ConfiguredAction,
+		appliedObject:  appliedObject,
+		existingObject: existingObject,
+		patchType:      patchType,
+		patch:          patch,
+	}, nil
+}
+
+// clientSideDiff returns the change that a client-side apply would make, the created
+// and patched objects are validated with a dry-run that doesn't require server-side apply.
+func clientSideDiff(ctx context.Context, resMgr *ssa.ResourceManager, obj *unstructured.Unstructured,
+	fieldManager string) (*ssa.ChangeSetEntry, error) {
+	kubeClient := resMgr.Client()
+	change, err := newClientSideChange(ctx, kubeClient, obj, reconcileExclusions())
+	if err != nil {
+		return nil, err
+	}
+
+	switch change.action {
+	case ssa.CreatedAction:
+		err = kubeClient.Create(ctx, change.appliedObject, client.DryRunAll, client.FieldOwner(fieldManager))
+	case ssa.ConfiguredAction:
+		err = kubeClient.Patch(ctx, change.existingObject, client.RawPatch(change.patchType, change.patch),
+			client.DryRunAll, client.FieldOwner(fieldManager))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s dry-run failed, error: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	return newChangeSetEntry(obj, change.action), nil
+}
+
+// clientSideApply creates the given object or patches the in-cluster object with a three-way merge patch
+// computed from the last applied configuration annotation, the same way 'kubectl apply' does.
+// The excluded objects are skipped, and when force is enabled, the objects that contain
+// immutable fields changes are recreated.
+func clientSideApply(ctx context.Context, resMgr *ssa.ResourceManager, obj *unstructured.Unstructured,
+	opts ssa.ApplyOptions, fieldManager string) (*ssa.ChangeSetEntry, error) {
+	kubeClient := resMgr.Client()
+
+	change, err := newClientSideChange(ctx, kubeClient, obj, opts.Exclusions)
+	if err != nil {
+		return nil, err
+	}
+
+	switch change.action {
+	case ssa.CreatedAction:
+		if err := kubeClient.Create(ctx, change.appliedObject, client.FieldOwner(fieldManager)); err != nil {
+			return nil, fmt.Errorf("%s apply failed, error: %w", ssa.FmtUnstructured(obj), err)
+		}
+		return newChangeSetEntry(obj, ssa.CreatedAction), nil
+	case ssa.UnchangedAction:
+		// do not patch objects that have not drifted to avoid bumping the resource version
+		return newChangeSetEntry(obj, ssa.UnchangedAction), nil
+	}
+
+	existingObject := change.existingObject
+	err = kubeClient.Patch(ctx, existingObject, client.RawPatch(change.patchType, change.patch), client.FieldOwner(fieldManager))
+	if err != nil {
+		if opts.Force && ssa.IsImmutableError(err) {
+			if err := kubeClient.Delete(ctx, existingObject); err != nil {
+				return nil, fmt.Errorf("%s immutable field detected, failed to delete object, error: %w",
+					ssa.FmtUnstructured(obj), err)
+			}
+			return clientSideApply(ctx, resMgr, obj, opts, fieldManager)
+		}
+		return nil, fmt.Errorf("%s apply failed, error: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	return newChangeSetEntry(obj, ssa.ConfiguredAction), nil
+}
+
+// threeWayMergePatch returns a strategic merge patch for the built-in kinds, computed with the patch strategy
+// and merge keys of their typed schema, and a JSON merge patch for the custom resources.
+func threeWayMergePatch(gvk schema.GroupVersionKind, original, modified, current []byte) (types.PatchType, []byte, error) {
+	preconditions := []mergepatch.PreconditionFunc{
+		mergepatch.RequireKeyUnchanged("apiVersion"),
+		mergepatch.RequireKeyUnchanged("kind"),
+		mergepatch.RequireMetadataKeyUnchanged("name"),
+	}
+
+	versionedObject, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		if !runtime.IsNotRegisteredError(err) {
+			return "", nil, err
+		}
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current, preconditions...)
+		return types.MergePatchType, patch, err
+	}
+
+	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(versionedObject)
+	if err != nil {
+		return "", nil, err
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookupPatchMeta, true, preconditions...)
+	return types.StrategicMergePatchType, patch, err
+}
+
+// clientSideApplyAll applies the given objects one by one in the order of their kinds.
+func clientSideApplyAll(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	opts ssa.ApplyOptions, fieldManager string) (*ssa.ChangeSet, error) {
+	sort.Sort(ssa.SortableUnstructureds(objects))
+	changeSet := ssa.NewChangeSet()
+	for _, obj := range objects {
+		change, err := clientSideApply(ctx, resMgr, obj, opts, fieldManager)
+		if err != nil {
+			return nil, err
+		}
+		changeSet.Add(*change)
+	}
+	return changeSet, nil
+}
+
+// withLastAppliedConfiguration returns a copy of the given object
+// annotated with its JSON representation.
+func withLastAppliedConfiguration(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	o := obj.DeepCopy()
+	annotations := o.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	o.SetAnnotations(annotations)
+
+	data, err := o.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("%s serialization failed, error: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[corev1.LastAppliedConfigAnnotation] = string(data)
+	o.SetAnnotations(annotations)
+	return o, nil
+}
+
+func newChangeSetEntry(obj *unstructured.Unstructured, action ssa.Action) *ssa.ChangeSetEntry {
+	return &ssa.ChangeSetEntry{
+		ObjMetadata:  object.UnstructuredToObjMetadata(obj),
+		GroupVersion: obj.GroupVersionKind().Version,
+		Subject:      ssa.FmtUnstructured(obj),
+		Action:       string(action),
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var copyArtifactCmd = &cobra.Command{
+	Use:   "artifact",
+	Short: "Copy promotes an OCI artifact to another repository or container registry.",
+	Long: `The copy command copies the artifact by digest from the source to the destination without rebuilding it,
+the digest of the copy is the same as the source digest, so the copy can be verified with the source signatures.
+This command uses the credentials from '~/.docker/config.json', or the credentials specified with '--creds' or '--registry-token',
+which are sent only to the destination registry, unless another registry is set with '--creds-registry'.`,
+	Example: `  kustomizer copy artifact <source oci url> <destination oci url>
+
+  # Promote an artifact from GitHub Container Registry to the production registry
+  kustomizer copy artifact oci://ghcr.io/user/repo:v1.0.0 oci://internal.example.com/mirror/repo:v1.0.0
+
+  # Promote the newest artifact in the 1.x range along with its signatures, attestations and SBOM
+  kustomizer copy artifact oci://ghcr.io/user/repo:^1.0 oci://internal.example.com/mirror/repo:stable --with-referrers
+`,
+	RunE: runCopyArtifactCmd,
+}
+
+type copyArtifactFlags struct {
+	withReferrers bool
+}
+
+var copyArtifactArgs copyArtifactFlags
+
+func init() {
+	copyArtifactCmd.Flags().BoolVar(&copyArtifactArgs.withReferrers, "with-referrers", false,
+		"Copy the cosign signatures and attestations, and the SBOM and attestations attached with 'kustomizer push artifact --sbom --attest'.")
+
+	copyCmd.AddCommand(copyArtifactCmd)
+}
+
+func runCopyArtifactCmd(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("you must specify the source and destination e.g. 'oci://docker.io/user/repo:tag oci://ghcr.io/user/repo:tag'")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	dst, err := registry.ParseURL(args[1])
+	if err != nil {
+		return err
+	}
+
+	// the explicit credentials are sent only to the destination registry
+	opts := registryOpts.ScopedTo(dst)
+
+	src, err := registry.ResolveURL(ctx, opts, args[0], "")
+	if err != nil {
+		return err
+	}
+
+	logger.Println("copying", src, "to", dst)
+	digest, referrers, err := registry.Copy(ctx, opts, src, dst, copyArtifactArgs.withReferrers)
+	if err != nil {
+		return fmt.Errorf("copying artifact failed: %w", err)
+	}
+
+	for _, referrer := range referrers {
+		logger.Println("copied", referrer)
+	}
+	logger.Println("copied digest", digest)
+
+	return nil
+}
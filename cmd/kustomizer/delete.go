@@ -17,14 +17,105 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
 )
 
 var deleteCmd = &cobra.Command{
 	Use:   "delete",
-	Short: "Delete inventories and their content.",
+	Short: "Delete inventories and their content, or artifacts stored in container registries.",
+	Example: `  # Delete an inventory and its content, same as 'kustomizer delete inventory my-app -n apps'
+  kustomizer delete -i my-app -n apps
+
+  # Delete all the inventories of a group across all namespaces, the dependents are deleted before their dependencies
+  kustomizer delete --group platform --all-namespaces
+`,
+	RunE: runDeleteCmd,
+}
+
+type deleteFlags struct {
+	inventory     string
+	group         string
+	allNamespaces bool
 }
 
+var deleteArgs deleteFlags
+
 func init() {
+	deleteCmd.Flags().StringVarP(&deleteArgs.inventory, "inventory", "i", "",
+		"The name of the inventory to delete along with its content.")
+	deleteCmd.Flags().StringVar(&deleteArgs.group, "group", "",
+		"The name of the group of inventories to delete in reverse dependency order.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.allNamespaces, "all-namespaces", false,
+		"Delete the inventories of the group across all namespaces.")
+
 	rootCmd.AddCommand(deleteCmd)
 }
+
+func runDeleteCmd(cmd *cobra.Command, args []string) error {
+	if deleteArgs.group != "" {
+		if deleteArgs.inventory != "" {
+			return fmt.Errorf("--inventory and --group are mutually exclusive")
+		}
+		return deleteGroup(deleteArgs.group)
+	}
+	if deleteArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory or use 'delete inventory <name>'")
+	}
+	return deleteInventoryCmdRun(cmd, []string{deleteArgs.inventory})
+}
+
+// deleteGroup removes the inventories of the given group, starting with the ones that no other inventory depends on.
+func deleteGroup(group string) error {
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	ns := *kubeconfigArgs.Namespace
+	if deleteArgs.allNamespaces {
+		ns = ""
+	}
+	inventories, err := invStorage.ListInventories(ctx, ns)
+	if err != nil {
+		return err
+	}
+
+	inventories, err = inventory.SortByDependencies(inventory.FilterByGroup(inventories, group))
+	if err != nil {
+		return err
+	}
+	if len(inventories) == 0 {
+		return fmt.Errorf("no inventories found in group %s", group)
+	}
+
+	for i := len(inventories) - 1; i >= 0; i-- {
+		logger.Println(fmt.Sprintf("deleting inventory %s from group %s", inventories[i].ID(), group))
+		if err := deleteInventory(inventories[i].Name, inventories[i].Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
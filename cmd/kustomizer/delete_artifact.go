@@ -0,0 +1,107 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var deleteArtifactCmd = &cobra.Command{
+	Use:   "artifact",
+	Short: "Delete removes OCI artifacts from the container registry.",
+	Long: `The delete artifact command removes the tags of the specified artifacts using the registry delete API.
+With '--digest', the artifacts manifests are deleted along with all the tags that point to them.
+Note that some registries don't allow the deletion of tags and require '--digest'.
+This command uses the credentials from '~/.docker/config.json', or the credentials specified with '--creds' or '--registry-token'.`,
+	Example: `  kustomizer delete artifact <oci url>...
+
+  # Remove a tag, the artifact can still be pulled by digest or by its other tags
+  kustomizer delete artifact oci://docker.io/user/repo:pr-123
+
+  # Delete the artifact referenced by the tag along with all its tags, without asking for confirmation
+  kustomizer delete artifact oci://docker.io/user/repo:v1.0.0 --digest --force
+
+  # Delete an artifact by digest
+  kustomizer delete artifact oci://docker.io/user/repo@sha256:<digest>
+
+  # Delete all the PR preview artifacts
+  kustomizer list artifacts oci://docker.io/user/repo | awk '$1 ~ /^pr-/ {print "oci://"$4}' | xargs kustomizer delete artifact --force
+`,
+	RunE: runDeleteArtifactCmd,
+}
+
+type deleteArtifactFlags struct {
+	digest bool
+	force  bool
+}
+
+var deleteArtifactArgs deleteArtifactFlags
+
+func init() {
+	deleteArtifactCmd.Flags().BoolVar(&deleteArtifactArgs.digest, "digest", false,
+		"Delete the artifact manifest referenced by the tag, along with all the tags that point to it.")
+	deleteArtifactCmd.Flags().BoolVar(&deleteArtifactArgs.force, "force", false,
+		"Delete the artifacts without asking for confirmation.")
+
+	deleteCmd.AddCommand(deleteArtifactCmd)
+}
+
+func runDeleteArtifactCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify at least an artifact e.g. 'oci://docker.io/user/repo:tag'")
+	}
+
+	var urls []string
+	for _, arg := range args {
+		url, err := registry.ParseURL(arg)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, url)
+	}
+
+	if !deleteArtifactArgs.force {
+		for _, url := range urls {
+			rootCmd.Println(url)
+		}
+		confirmed, err := askForConfirmation(fmt.Sprintf("Do you want to delete %d artifact(s)?", len(urls)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("delete aborted")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	for _, url := range urls {
+		ref, err := registry.Delete(ctx, registryOpts, url, deleteArtifactArgs.digest)
+		if err != nil {
+			return fmt.Errorf("deleting %s failed: %w", url, err)
+		}
+		logger.Println("deleted", ref)
+	}
+
+	return nil
+}
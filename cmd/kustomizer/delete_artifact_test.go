@@ -0,0 +1,101 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDeleteArtifact(t *testing.T) {
+	g := NewWithT(t)
+	id := randStringRunes(5)
+	artifact := fmt.Sprintf("oci://%s/%s:v1.0.0", registryHost, id)
+	artifactPR := fmt.Sprintf("oci://%s/%s:pr-1", registryHost, id)
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("push artifact", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s",
+			artifact,
+			dir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		_, err = executeCommand(fmt.Sprintf(
+			"tag artifact %s pr-1",
+			artifact,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("aborts without confirmation", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"delete artifact %s",
+			artifactPR,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("delete aborted"))
+	})
+
+	t.Run("deletes tag", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"delete artifact %s --force",
+			artifactPR,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s",
+			artifactPR,
+		))
+		g.Expect(err).To(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s",
+			artifact,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("deletes digest", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"delete artifact %s --digest --force",
+			artifact,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(ContainSubstring("@sha256:"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s",
+			artifact,
+		))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
@@ -19,13 +19,13 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
 	"sort"
 
 	"github.com/stefanprodan/kustomizer/pkg/inventory"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var deleteInventoryCmd = &cobra.Command{
@@ -36,18 +36,30 @@ var deleteInventoryCmd = &cobra.Command{
 
   # Delete an inventory and its content
   kustomizer delete inv my-app -n apps
+
+  # Delete an inventory and print the change set as YAML
+  kustomizer delete inv my-app -n apps -o yaml
+
+  # Delete an inventory and leave the dependents of its objects (e.g. the Pods of a ReplicaSet) in place
+  kustomizer delete inv my-app -n apps --cascade orphan
 `,
 	RunE: deleteInventoryCmdRun,
 }
 
 type deleteInventoryFlags struct {
-	wait bool
+	wait    bool
+	output  string
+	cascade string
 }
 
 var deleteInventoryArgs deleteInventoryFlags
 
 func init() {
 	deleteInventoryCmd.Flags().BoolVar(&deleteInventoryArgs.wait, "wait", true, "Wait for the deleted Kubernetes objects to be terminated.")
+	deleteInventoryCmd.Flags().StringVarP(&deleteInventoryArgs.output, "output", "o", "",
+		"Write the change set to stdout in JSON or YAML format, or as a Markdown or HTML report.")
+	deleteInventoryCmd.Flags().StringVar(&deleteInventoryArgs.cascade, "cascade", "background",
+		"The deletion propagation policy for the dependents of the deleted objects, can be background, foreground or orphan.")
 
 	deleteCmd.AddCommand(deleteInventoryCmd)
 }
@@ -56,7 +68,23 @@ func deleteInventoryCmdRun(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("you must specify an inventory name")
 	}
-	name := args[0]
+	return deleteInventory(args[0], *kubeconfigArgs.Namespace)
+}
+
+// deleteInventory removes the objects of the given inventory and then the inventory storage.
+func deleteInventory(name, namespace string) error {
+	printer, err := newChangeSetPrinter(deleteInventoryArgs.output, func(entry changeSetEntry) {
+		logger.Println(entry.Subject, entry.Action)
+	})
+	if err != nil {
+		return err
+	}
+
+	deleteOpts := ssa.DefaultDeleteOptions()
+	deleteOpts.PropagationPolicy, err = parseCascade(deleteInventoryArgs.cascade)
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
@@ -78,9 +106,10 @@ func deleteInventoryCmdRun(cmd *cobra.Command, args []string) error {
 	invStorage := &inventory.Storage{
 		Manager: resMgr,
 		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
 	}
 
-	inv := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	inv := inventory.NewInventory(name, namespace)
 	if err := invStorage.GetInventory(ctx, inv); err != nil {
 		return err
 	}
@@ -94,25 +123,24 @@ func deleteInventoryCmdRun(cmd *cobra.Command, args []string) error {
 	hasErrors := false
 	sort.Sort(sort.Reverse(ssa.SortableUnstructureds(objects)))
 	for _, object := range objects {
-		change, err := resMgr.Delete(ctx, object, ssa.DefaultDeleteOptions())
+		change, err := resMgr.Delete(ctx, object, deleteOpts)
 		if err != nil {
 			logger.Println(`✗`, err)
 			hasErrors = true
 			continue
 		}
-		logger.Println(change.String())
+		printer.Print(*change)
 	}
 
 	if hasErrors {
-		os.Exit(1)
-	}
-
-	if err := invStorage.DeleteInventory(ctx, inv); err != nil {
-		return err
+		if err := printer.Flush(); err != nil {
+			return err
+		}
+		return &exitCodeError{code: 1, reason: "deleting objects failed"}
 	}
 
-	logger.Println(fmt.Sprintf("ConfigMap/%s/%s deleted", *kubeconfigArgs.Namespace, name))
-
+	// remove the inventory only after its objects are terminated,
+	// so that a failed wait can be retried with the same command
 	if deleteInventoryArgs.wait {
 		waitOpts := ssa.DefaultWaitOptions()
 		waitOpts.Timeout = rootArgs.timeout
@@ -124,5 +152,25 @@ func deleteInventoryCmdRun(cmd *cobra.Command, args []string) error {
 		logger.Println("all resources have been deleted")
 	}
 
-	return nil
+	if err := invStorage.DeleteInventory(ctx, inv); err != nil {
+		return err
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s deleted", namespace, name))
+
+	return printer.Flush()
+}
+
+// parseCascade returns the deletion propagation policy for the given cascade value.
+func parseCascade(cascade string) (metav1.DeletionPropagation, error) {
+	switch cascade {
+	case "", "background":
+		return metav1.DeletePropagationBackground, nil
+	case "foreground":
+		return metav1.DeletePropagationForeground, nil
+	case "orphan":
+		return metav1.DeletePropagationOrphan, nil
+	default:
+		return "", fmt.Errorf("unsupported cascade value '%s', can be background, foreground or orphan", cascade)
+	}
 }
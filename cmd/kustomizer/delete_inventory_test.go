@@ -44,7 +44,7 @@ func TestDelete(t *testing.T) {
 
 	t.Run("creates objects", func(t *testing.T) {
 		output, err := executeCommand(fmt.Sprintf(
-			"apply inv  %s -k %s --namespace %s",
+			"apply inv  %s -k %s --namespace %s --store-manifests",
 			inventory,
 			dir,
 			id,
@@ -95,5 +95,135 @@ func TestDelete(t *testing.T) {
 
 		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
 		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		manifests := &corev1.SecretList{}
+		err = envTestClient.List(context.Background(), manifests, client.InNamespace(id), client.MatchingLabels{
+			"app.kubernetes.io/name":      inventory,
+			"app.kubernetes.io/component": "manifests",
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(manifests.Items).To(BeEmpty())
+	})
+}
+
+func TestDeleteByInventoryFlag(t *testing.T) {
+	g := NewWithT(t)
+	id := "del-flag-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s --namespace %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("deletes objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -i %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("inventory %[1]s/%[1]s deleted", id)))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
+
+func TestDeleteGroup(t *testing.T) {
+	g := NewWithT(t)
+	id := "del-group-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates inventories", func(t *testing.T) {
+		for _, name := range []string{"base-" + id, "app-" + id} {
+			dir, err := makeTestDir(name, testManifests(name, id, false))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			dependsOn := ""
+			if name == "app-"+id {
+				dependsOn = "--depends-on base-" + id
+			}
+
+			_, err = executeCommand(fmt.Sprintf(
+				"apply inv %s -k %s --namespace %s --group platform %s",
+				name,
+				dir,
+				id,
+				dependsOn,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	t.Run("fails for circular dependencies", func(t *testing.T) {
+		dir, err := makeTestDir("base-"+id, testManifests("base-"+id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv base-%s -k %s --namespace %s --group platform --depends-on app-%s",
+			id,
+			dir,
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf("delete --group platform -n %s", id))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("circular dependency"))
+	})
+
+	t.Run("deletes the group in reverse dependency order", func(t *testing.T) {
+		dir, err := makeTestDir("base-"+id, testManifests("base-"+id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv base-%s -k %s --namespace %s --group platform",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf("delete --group platform -n %s", id))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`(?s)inventory %[1]s/app-%[1]s deleted.*inventory %[1]s/base-%[1]s deleted`, id)))
+
+		output, err = executeCommand(fmt.Sprintf("get inventories -n %s --group platform", id))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).NotTo(MatchRegexp(id))
 	})
 }
@@ -19,33 +19,40 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
+	"sort"
 
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
-	"sigs.k8s.io/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
 
 var diffArtifactCmd = &cobra.Command{
 	Use:   "artifact",
-	Short: "Diff compares the two artifacts and prints the differences between the Kubernetes resources to stdout.",
+	Short: "Diff compares the two artifacts and prints the Kubernetes resources added, changed or removed to stdout.",
 	Example: `  kustomizer diff artifact <oci url1> <oci url2>
 
   # Diff artifact by tag
   kustomizer diff artifact oci://registry/org/repo:v1 oci://registry/org/repo:v2
 
+  # Diff the staging and production variants of a bundle artifact
+  kustomizer diff artifact oci://registry/org/repo:v1#staging oci://registry/org/repo:v1#production
+
   # Diff artifact by digest
   kustomizer diff artifact oci://registry/org/repo@sha245:<digest-1> oci://registry/org/repo@sha245:<digest-2>
+
+  # Diff artifact by tag and write the change set as a Markdown report for the release notes
+  kustomizer diff artifact oci://registry/org/repo:v1.0.0 oci://registry/org/repo:v1.1.0 -o markdown > changes.md
 `,
 	RunE: runDiffArtifactCmd,
 }
 
 type diffArtifactFlags struct {
 	ageIdentities string
+	output        string
+	diffContext   int
+	noColor       bool
 }
 
 var diffArtifactArgs diffArtifactFlags
@@ -53,6 +60,12 @@ var diffArtifactArgs diffArtifactFlags
 func init() {
 	diffArtifactCmd.Flags().StringVar(&diffArtifactArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	diffArtifactCmd.Flags().StringVarP(&diffArtifactArgs.output, "output", "o", "",
+		"Write the change set to stdout in JSON or YAML format, or as a Markdown or HTML report.")
+	diffArtifactCmd.Flags().IntVar(&diffArtifactArgs.diffContext, "diff-context", 3,
+		"The number of unchanged lines printed around the changed lines in the unified diff.")
+	diffArtifactCmd.Flags().BoolVar(&diffArtifactArgs.noColor, "no-color", false,
+		"Print the unified diff without colors, the colors are also disabled when the NO_COLOR environment variable is set.")
 
 	diffCmd.AddCommand(diffArtifactCmd)
 }
@@ -67,40 +80,39 @@ func runDiffArtifactCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("faild to read decryption keys: %w", err)
 	}
 
-	tmpDir, err := os.MkdirTemp("", *kubeconfigArgs.Namespace)
-	if err != nil {
-		return err
-	}
-	defer os.RemoveAll(tmpDir)
-
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	files := []string{}
-	for i, ociURL := range args {
-		url, err := registry.ParseURL(ociURL)
+	var artifacts [][]*unstructured.Unstructured
+	for _, ociURL := range args {
+		baseURL, variant := registry.SplitVariant(ociURL)
+		url, err := registry.ResolveURL(ctx, registryOpts, baseURL, "")
 		if err != nil {
 			return err
 		}
 
-		data, _, err := registry.Pull(ctx, url, identities)
+		objects, _, err := pullArtifactObjects(ctx, url, variant, identities)
 		if err != nil {
 			return fmt.Errorf("pulling %s failed: %w", url, err)
 		}
-		res, _ := yaml.Marshal(data)
-		resPath := filepath.Join(tmpDir, fmt.Sprintf("%d.yaml", i))
-		if err := os.WriteFile(resPath, res, 0644); err != nil {
-			return err
-		}
-		files = append(files, resPath)
+		sort.Sort(ssa.SortableUnstructureds(objects))
+		artifacts = append(artifacts, objects)
 	}
 
-	out, _ := exec.Command("diff", "-N", "-u", files[0], files[1]).Output()
-	for i, line := range strings.Split(string(out), "\n") {
-		if i > 1 && len(line) > 0 {
-			rootCmd.Println(line)
+	printer, err := newChangeSetPrinter(diffArtifactArgs.output, func(entry changeSetEntry) {
+		rootCmd.Println(`►`, entry.Subject, entry.Action)
+		if entry.Diff != "" {
+			rootCmd.Println(entry.Diff)
 		}
+	})
+	if err != nil {
+		return err
+	}
+
+	color := !printer.isStructured() && useColor(diffArtifactArgs.noColor)
+	if err := printObjectsDiff(printer, artifacts[0], artifacts[1], diffArtifactArgs.diffContext, color); err != nil {
+		return err
 	}
 
-	return nil
+	return printer.Flush()
 }
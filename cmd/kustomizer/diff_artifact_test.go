@@ -64,4 +64,18 @@ func TestDiffArtifact(t *testing.T) {
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp("immutable"))
 	})
+
+	t.Run("diff artifact objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"diff artifact %s %s --no-color",
+			artifact1,
+			artifact2,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%[1]s/%[1]s configured", id)))
+		g.Expect(output).To(MatchRegexp(`\+immutable: true`))
+		g.Expect(output).NotTo(MatchRegexp("ConfigMap"))
+	})
 }
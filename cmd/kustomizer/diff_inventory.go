@@ -18,17 +18,26 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"gomodules.xyz/jsonpatch/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
+	"github.com/stefanprodan/kustomizer/pkg/drift"
 	"github.com/stefanprodan/kustomizer/pkg/inventory"
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
@@ -50,24 +59,71 @@ var diffInventoryCmd = &cobra.Command{
 
   # Build the inventory from a local overlay and print the YAML diff
   kustomizer diff inventory my-app -n apps -k ./overlays/prod
+
+  # Build the inventory from a local overlay and print the YAML diff without colors and with 10 lines of context
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --no-color --diff-context 10
+
+  # Build the inventory from a local overlay and print the change set as JSON
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod -o json
+
+  # Build the inventory from a local overlay and print the diff with dyff
+  KUSTOMIZER_EXTERNAL_DIFF="dyff between --omit-header" kustomizer diff inventory my-app -n apps -k ./overlays/prod
+
+  # Build the inventory from a local overlay and print the changes as JSON patch operations
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --diff-format json-patch
+
+  # Build the inventory from a local overlay and write the change set as a Markdown report for a pull request comment
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod -o markdown > report.md
+
+  # Build the inventory from a local overlay and print only the paths of the changed fields
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --diff-format summary
+
+  # Build the inventory from a local overlay and ignore the drift of the replicas field
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --ignore-paths /spec/replicas
+
+  # Build the inventory from a local overlay and diff 20 objects in parallel
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --concurrency 20
+
+  # Build the inventory from a local overlay and fail if the cluster state has drifted,
+  # the command exits with code 1 on drift and with code 2 if the diff failed
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --exit-code
 `,
 	RunE: runDiffInventoryCmd,
 }
 
 type diffInventoryFlags struct {
-	artifact      []string
-	filename      []string
-	kustomize     string
-	patch         []string
-	prune         bool
-	ageIdentities string
+	artifact        []string
+	filename        []string
+	kustomize       string
+	patch           []string
+	prune           bool
+	ageIdentities   string
+	httpHeaders     []string
+	httpToken       string
+	httpChecksums   []string
+	output          string
+	include         []string
+	exclude         []string
+	targetNamespace string
+	fieldManager    string
+	labels          []string
+	annotations     []string
+	namePrefix      string
+	nameSuffix      string
+	exitCode        bool
+	diffFormat      string
+	diffContext     int
+	noColor         bool
+	ignorePaths     []string
+	concurrency     int
+	allowCrossNS    bool
 }
 
 var diffInventoryArgs diffInventoryFlags
 
 func init() {
 	diffInventoryCmd.Flags().StringSliceVarP(&diffInventoryArgs.filename, "filename", "f", nil,
-		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively.")
+		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively. Use '-' to read from stdin or an HTTP(S) URL to download the manifests, the downloads are limited to 50MiB.")
 	diffInventoryCmd.Flags().StringVarP(&diffInventoryArgs.kustomize, "kustomize", "k", "",
 		"Path to a directory that contains a kustomization.yaml.")
 	diffInventoryCmd.Flags().StringSliceVarP(&diffInventoryArgs.artifact, "artifact", "a", nil,
@@ -77,11 +133,60 @@ func init() {
 	diffInventoryCmd.Flags().BoolVar(&diffInventoryArgs.prune, "prune", false, "Delete stale objects from the cluster.")
 	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	diffInventoryCmd.Flags().StringArrayVar(&diffInventoryArgs.httpHeaders, "http-header", nil,
+		"HTTP header in the format 'key: value' added to the requests for manifests hosted at HTTP(S) URLs.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.httpToken, "http-token", "",
+		"Bearer token used to authenticate the requests for manifests hosted at HTTP(S) URLs.")
+	diffInventoryCmd.Flags().StringSliceVar(&diffInventoryArgs.httpChecksums, "http-checksum", nil,
+		"SHA-256 checksum of the manifests hosted at HTTP(S) URLs, specified in the same order as the URLs.")
+	diffInventoryCmd.Flags().StringVarP(&diffInventoryArgs.output, "output", "o", "",
+		"Write the change set to stdout in JSON or YAML format, or as a Markdown or HTML report.")
+	diffInventoryCmd.Flags().StringArrayVar(&diffInventoryArgs.include, "include", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', only the matching objects are processed. The values can contain shell patterns e.g. 'name=web*'.")
+	diffInventoryCmd.Flags().StringArrayVar(&diffInventoryArgs.exclude, "exclude", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', the matching objects are skipped. The values can contain shell patterns e.g. 'kind=Secret'.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.targetNamespace, "target-namespace", "",
+		"Set or override the namespace of all the namespaced objects, cluster-scoped objects are left untouched.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.fieldManager, "field-manager", "",
+		"The name of the manager used to track field ownership, defaults to the field manager set in the config file.")
+	diffInventoryCmd.Flags().StringArrayVar(&diffInventoryArgs.labels, "label", nil,
+		"Label in the format 'key=value' to set on all objects, can be specified multiple times.")
+	diffInventoryCmd.Flags().StringArrayVar(&diffInventoryArgs.annotations, "annotation", nil,
+		"Annotation in the format 'key=value' to set on all objects, can be specified multiple times.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.namePrefix, "name-prefix", "",
+		"Prefix added to the names of all objects, the references to the renamed objects are updated accordingly.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.nameSuffix, "name-suffix", "",
+		"Suffix added to the names of all objects, the references to the renamed objects are updated accordingly.")
+	diffInventoryCmd.Flags().BoolVar(&diffInventoryArgs.exitCode, "exit-code", false,
+		"Exit with code 1 when objects would be created, configured or deleted, with code 2 when the diff failed, and with code 0 when the cluster is in sync.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.diffFormat, "diff-format", "unified",
+		"The format of the object diffs, can be unified, json-patch (RFC 6902 operations) or summary (the list of changed fields).")
+	diffInventoryCmd.Flags().IntVar(&diffInventoryArgs.diffContext, "diff-context", 3,
+		"The number of unchanged lines printed around the changed lines in the unified diff.")
+	diffInventoryCmd.Flags().BoolVar(&diffInventoryArgs.noColor, "no-color", false,
+		"Print the unified diff without colors, the colors are also disabled when the NO_COLOR environment variable is set.")
+	diffInventoryCmd.Flags().StringSliceVar(&diffInventoryArgs.ignorePaths, "ignore-paths", nil,
+		"List of JSON pointers e.g. '/spec/replicas' to fields excluded from drift detection and from the diff.")
+	diffInventoryCmd.Flags().IntVar(&diffInventoryArgs.concurrency, "concurrency", 4,
+		"The number of objects diffed in parallel, the results are printed in the objects apply order.")
+	diffInventoryCmd.Flags().BoolVar(&diffInventoryArgs.allowCrossNS, "allow-cross-namespace-prune", false,
+		"Allow the stale objects outside the namespaces of the built objects to be listed for deletion.")
 
 	diffCmd.AddCommand(diffInventoryCmd)
 }
 
 func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
+	err := diffInventory(cmd, args)
+
+	// with --exit-code the failures must be distinguishable from the drift
+	var exitErr *exitCodeError
+	if err != nil && diffInventoryArgs.exitCode && !errors.As(err, &exitErr) {
+		return &exitCodeError{code: 2, reason: "diff failed", err: err}
+	}
+	return err
+}
+
+func diffInventory(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("you must specify an inventory name")
 	}
@@ -91,6 +196,20 @@ func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("-a, -f or -k is required")
 	}
 
+	ignorePaths, err := drift.ParsePaths(diffInventoryArgs.ignorePaths)
+	if err != nil {
+		return err
+	}
+
+	diffFormat := diffInventoryArgs.diffFormat
+	switch diffFormat {
+	case "":
+		diffFormat = "unified"
+	case "unified", "json-patch", "summary":
+	default:
+		return fmt.Errorf("unsupported diff format, can be unified, json-patch or summary")
+	}
+
 	identities, err := registry.ParseAgeIdentities(diffInventoryArgs.ageIdentities)
 	if err != nil {
 		return fmt.Errorf("faild to read decryption keys: %w", err)
@@ -99,11 +218,38 @@ func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	objects, _, err := buildManifests(ctx, diffInventoryArgs.kustomize, diffInventoryArgs.filename, diffInventoryArgs.artifact, diffInventoryArgs.patch, identities)
+	fetcher, err := newHTTPFetcher(diffInventoryArgs.filename, diffInventoryArgs.httpHeaders, diffInventoryArgs.httpToken, diffInventoryArgs.httpChecksums)
+	if err != nil {
+		return err
+	}
+
+	objects, _, err := buildManifests(ctx, diffInventoryArgs.kustomize, diffInventoryArgs.filename, diffInventoryArgs.artifact, diffInventoryArgs.patch, identities, fetcher)
 	if err != nil {
 		return err
 	}
 
+	objects, err = filterObjects(objects, diffInventoryArgs.include, diffInventoryArgs.exclude)
+	if err != nil {
+		return err
+	}
+
+	if diffInventoryArgs.targetNamespace != "" {
+		if err := setTargetNamespace(objects, diffInventoryArgs.targetNamespace); err != nil {
+			return err
+		}
+	}
+
+	if diffInventoryArgs.namePrefix != "" || diffInventoryArgs.nameSuffix != "" {
+		objects, err = renameObjects(objects, diffInventoryArgs.namePrefix, diffInventoryArgs.nameSuffix)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := decorateObjects(objects, name, *kubeconfigArgs.Namespace, diffInventoryArgs.labels, diffInventoryArgs.annotations); err != nil {
+		return err
+	}
+
 	sort.Sort(ssa.SortableUnstructureds(objects))
 
 	newInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
@@ -121,75 +267,307 @@ func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("status poller init failed: %w", err)
 	}
 
-	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, newFieldOwner(diffInventoryArgs.fieldManager))
 
 	invStorage := &inventory.Storage{
-		Manager: resMgr,
-		Owner:   inventoryOwner,
+		Manager:                  resMgr,
+		Owner:                    inventoryOwner,
+		Kind:                     rootArgs.inventoryKind,
+		AllowCrossNamespacePrune: diffInventoryArgs.allowCrossNS,
 	}
 
 	resMgr.SetOwnerLabels(objects, name, *kubeconfigArgs.Namespace)
 
-	if _, err := exec.LookPath("diff"); err != nil {
-		return fmt.Errorf("diff binary not found in PATH, error: %w", err)
-	}
-
-	tmpDir, err := os.MkdirTemp("", *kubeconfigArgs.Namespace)
+	printer, err := newChangeSetPrinter(diffInventoryArgs.output, func(entry changeSetEntry) {
+		switch entry.Action {
+		case string(ssa.ConfiguredAction):
+			rootCmd.Println(`►`, entry.Subject, "drifted")
+			if entry.Diff != "" {
+				rootCmd.Println(entry.Diff)
+			}
+			if len(entry.Patch) > 0 {
+				data, _ := json.MarshalIndent(entry.Patch, "", "  ")
+				rootCmd.Println(string(data))
+			}
+			for _, path := range entry.Paths {
+				rootCmd.Println("  ", path)
+			}
+		case unknownAction:
+			rootCmd.Println(`►`, entry.Subject, "unknown (insufficient permissions)")
+		default:
+			rootCmd.Println(`►`, entry.Subject, entry.Action)
+		}
+	})
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmpDir)
 
-	invalid := false
-	for _, object := range objects {
-		change, liveObject, mergedObject, err := resMgr.Diff(ctx, object, ssa.DefaultDiffOptions())
+	var externalDiff []string
+	if !printer.isStructured() && diffFormat == "unified" {
+		externalDiff = strings.Fields(os.Getenv(externalDiffEnv))
+	}
+
+	var liveDir, mergedDir string
+	if len(externalDiff) > 0 {
+		tmpDir, err := os.MkdirTemp("", *kubeconfigArgs.Namespace)
 		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		liveDir = filepath.Join(tmpDir, "live")
+		mergedDir = filepath.Join(tmpDir, "merged")
+		for _, dir := range []string{liveDir, mergedDir} {
+			if err := os.Mkdir(dir, os.ModePerm); err != nil {
+				return err
+			}
+		}
+	}
+
+	color := !printer.isStructured() && useColor(diffInventoryArgs.noColor)
+
+	invalid := 0
+	manager := newFieldOwner(diffInventoryArgs.fieldManager).Field
+	for _, result := range diffObjects(ctx, resMgr, objects, manager, ignorePaths, diffInventoryArgs.concurrency) {
+		change, liveObject, mergedObject := result.change, result.liveObject, result.mergedObject
+		if err := result.err; err != nil {
 			logger.Println(`✗`, err)
-			invalid = true
+			invalid++
+			continue
+		}
+
+		if change.Action == string(ssa.CreatedAction) || change.Action == unknownAction {
+			printer.Print(*change)
+			continue
+		}
+
+		if change.Action != string(ssa.ConfiguredAction) {
 			continue
 		}
 
-		if change.Action == string(ssa.CreatedAction) {
-			rootCmd.Println(`►`, change.Subject, "created")
+		if len(ignorePaths) > 0 {
+			liveObject = drift.Remove(liveObject, ignorePaths)
+			mergedObject = drift.Remove(mergedObject, ignorePaths)
 		}
+		liveObject, mergedObject = maskFields(liveObject, mergedObject)
 
-		if change.Action == string(ssa.ConfiguredAction) {
-			rootCmd.Println(`►`, change.Subject, "drifted")
+		entry := changeSetEntry{
+			Subject: change.Subject,
+			Action:  change.Action,
+		}
+		if printer.isStructured() {
+			entry.Changes = drift.Changes(liveObject, mergedObject)
+		}
 
+		switch {
+		case diffFormat != "unified":
+			patch, err := newJSONPatch(liveObject, mergedObject)
+			if err != nil {
+				return err
+			}
+			if diffFormat == "summary" {
+				entry.Paths = changedPaths(patch)
+			} else {
+				entry.Patch = patch
+			}
+		case len(externalDiff) > 0:
+			fileName := strings.ReplaceAll(change.Subject, "/", "_") + ".yaml"
 			liveYAML, _ := yaml.Marshal(liveObject)
-			liveFile := filepath.Join(tmpDir, "live.yaml")
-			if err := os.WriteFile(liveFile, liveYAML, 0644); err != nil {
+			if err := os.WriteFile(filepath.Join(liveDir, fileName), liveYAML, 0644); err != nil {
 				return err
 			}
-
 			mergedYAML, _ := yaml.Marshal(mergedObject)
-			mergedFile := filepath.Join(tmpDir, "merged.yaml")
-			if err := os.WriteFile(mergedFile, mergedYAML, 0644); err != nil {
+			if err := os.WriteFile(filepath.Join(mergedDir, fileName), mergedYAML, 0644); err != nil {
 				return err
 			}
-
-			out, _ := exec.Command("diff", "-N", "-u", liveFile, mergedFile).Output()
-			for i, line := range strings.Split(string(out), "\n") {
-				if i > 1 && len(line) > 0 {
-					rootCmd.Println(line)
-				}
+		default:
+			liveYAML, _ := yaml.Marshal(liveObject)
+			mergedYAML, _ := yaml.Marshal(mergedObject)
+			diff, err := unifiedDiff(string(liveYAML), string(mergedYAML), diffInventoryArgs.diffContext, color)
+			if err != nil {
+				return err
 			}
+			entry.Diff = diff
 		}
+		printer.PrintEntry(entry)
 	}
 
-	if !invalid {
+	if invalid == 0 {
 		staleObjects, err := invStorage.GetInventoryStaleObjects(ctx, newInventory)
 		if err != nil {
 			return fmt.Errorf("inventory query failed, error: %w", err)
 		}
 
 		for _, object := range staleObjects {
-			rootCmd.Println(`►`, fmt.Sprintf("%s deleted", ssa.FmtUnstructured(object)))
+			printer.Print(ssa.ChangeSetEntry{
+				Subject: ssa.FmtUnstructured(object),
+				Action:  string(ssa.DeletedAction),
+			})
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		return err
+	}
+
+	if len(externalDiff) > 0 {
+		if err := runExternalDiff(externalDiff, liveDir, mergedDir); err != nil {
+			return err
 		}
 	}
 
-	if invalid {
-		os.Exit(1)
+	if invalid > 0 {
+		return fmt.Errorf("diff failed for %d object(s)", invalid)
+	}
+	if diffInventoryArgs.exitCode && (printer.HasChanges() || printer.HasUnknown()) {
+		return &exitCodeError{code: 1, reason: "changes detected"}
 	}
 	return nil
 }
+
+// diffResult holds the outcome of the server-side dry-run diff of an object.
+type diffResult struct {
+	change       *ssa.ChangeSetEntry
+	liveObject   *unstructured.Unstructured
+	mergedObject *unstructured.Unstructured
+	err          error
+}
+
+// diffObjects runs the server-side dry-run diffs using a pool of workers,
+// the returned results are in the same order as the objects.
+// The objects that drifted only in fields not owned by the given field manager or in the ignored paths are reported as unchanged.
+func diffObjects(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	manager string, ignorePaths []drift.Path, concurrency int) []diffResult {
+	results := make([]diffResult, len(objects))
+
+	var g errgroup.Group
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	g.SetLimit(concurrency)
+	for i, object := range objects {
+		i, object := i, object
+		g.Go(func() error {
+			change, liveObject, mergedObject, err := resMgr.Diff(ctx, object, ssa.DiffOptions{Exclusions: reconcileExclusions()})
+			if err == nil && change.Action == string(ssa.ConfiguredAction) {
+				var drifted bool
+				drifted, err = hasOwnedFieldsDrifted(ctx, resMgr, object, liveObject, mergedObject, manager, ignorePaths)
+				if err == nil && !drifted {
+					change.Action = string(ssa.UnchangedAction)
+				}
+			}
+			if err != nil && isReadForbidden(ctx, resMgr, object) {
+				change = &ssa.ChangeSetEntry{Subject: ssa.FmtUnstructured(object), Action: unknownAction}
+				liveObject, mergedObject, err = nil, nil, nil
+			}
+			results[i] = diffResult{
+				change:       change,
+				liveObject:   liveObject,
+				mergedObject: mergedObject,
+				err:          err,
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return results
+}
+
+// isReadForbidden returns true if the in-cluster object can't be read due to insufficient permissions.
+func isReadForbidden(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured) bool {
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject)
+	return apierrors.IsForbidden(err)
+}
+
+// externalDiffEnv is the environment variable that holds the command used to compare
+// the in-cluster objects with the dry-run results e.g. 'dyff between' or 'meld'.
+const externalDiffEnv = "KUSTOMIZER_EXTERNAL_DIFF"
+
+// runExternalDiff runs the given diff command with the live and merged directories as arguments.
+// Exit code 1 is expected when the directories differ, higher exit codes are treated as errors.
+func runExternalDiff(command []string, liveDir, mergedDir string) error {
+	args := append(command[1:], liveDir, mergedDir)
+	cmd := exec.Command(command[0], args...)
+	cmd.Stdout = rootCmd.OutOrStdout()
+	cmd.Stderr = rootCmd.ErrOrStderr()
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil
+		}
+		return fmt.Errorf("%s failed, error: %w", externalDiffEnv, err)
+	}
+	return nil
+}
+
+// newJSONPatch returns the JSON patch operations that transform the live object into the merged one.
+// The operations are returned in the order they must be applied, as the array indices of the
+// later operations depend on the earlier ones. The server-side generated fields are ignored.
+func newJSONPatch(liveObject, mergedObject *unstructured.Unstructured) ([]jsonpatch.Operation, error) {
+	liveJSON, err := withoutServerFields(liveObject).MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	mergedJSON, err := withoutServerFields(mergedObject).MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := jsonpatch.CreatePatch(liveJSON, mergedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s JSON patch failed, error: %w", ssa.FmtUnstructured(liveObject), err)
+	}
+
+	return patch, nil
+}
+
+// withoutServerFields returns a copy of the given object without the metadata fields set by the API server.
+func withoutServerFields(object *unstructured.Unstructured) *unstructured.Unstructured {
+	o := object.DeepCopy()
+	o.SetResourceVersion("")
+	o.SetGeneration(0)
+	o.SetUID("")
+	o.SetManagedFields(nil)
+	unstructured.RemoveNestedField(o.Object, "metadata", "creationTimestamp")
+	return o
+}
+
+// changedPaths returns the sorted list of fields changed by the given JSON patch
+// in the format 'spec.template.spec.containers[0].image'.
+func changedPaths(patch []jsonpatch.Operation) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, op := range patch {
+		var sb strings.Builder
+		for _, token := range strings.Split(strings.TrimPrefix(op.Path, "/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch {
+			case isIndex(token):
+				sb.WriteString("[" + token + "]")
+			case strings.ContainsAny(token, "./"):
+				sb.WriteString("['" + token + "']")
+			default:
+				if sb.Len() > 0 {
+					sb.WriteString(".")
+				}
+				sb.WriteString(token)
+			}
+		}
+
+		if path := sb.String(); !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func isIndex(token string) bool {
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
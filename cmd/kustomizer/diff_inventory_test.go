@@ -17,10 +17,15 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func TestDiffInventory(t *testing.T) {
@@ -74,6 +79,22 @@ func TestDiffInventory(t *testing.T) {
 		g.Expect(output).To(MatchRegexp("created"))
 		g.Expect(output).To(MatchRegexp("deleted"))
 	})
+	t.Run("generates parallel diff in apply order", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-1", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --concurrency 10",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?s)ConfigMap/.*Secret/.*CronJob/`))
+	})
+
 	t.Run("generates YAML diff", func(t *testing.T) {
 		dir, err := makeTestDir(id, testManifests(id, id, true))
 		g.Expect(err).NotTo(HaveOccurred())
@@ -89,4 +110,215 @@ func TestDiffInventory(t *testing.T) {
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp("immutable"))
 	})
+
+	t.Run("generates external diff", func(t *testing.T) {
+		t.Setenv("KUSTOMIZER_EXTERNAL_DIFF", "diff -r -u")
+
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("live/Secret_"))
+		g.Expect(output).To(MatchRegexp("immutable"))
+	})
+
+	t.Run("generates YAML diff without colors", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --no-color --diff-context 0",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("(?m)^\\+immutable: true$"))
+		g.Expect(output).NotTo(ContainSubstring("\033["))
+	})
+
+	t.Run("ignores drift in paths", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --ignore-paths /immutable,/data",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).NotTo(MatchRegexp("Secret"))
+	})
+
+	t.Run("generates JSON patch", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --diff-format json-patch",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`"op": "replace"`))
+		g.Expect(output).To(MatchRegexp(`"path": "/immutable"`))
+		g.Expect(output).NotTo(MatchRegexp(`"path": "/metadata/(resourceVersion|generation|uid|creationTimestamp|managedFields)`))
+	})
+
+	t.Run("generates diff summary", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --diff-format summary",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?m)^\s+immutable$`))
+	})
+
+	t.Run("generates Markdown report", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s -o markdown",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`\| configured \| 1 \|`))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("<details><summary><code>Secret/%[1]s/%[1]s</code> configured</summary>", id)))
+		g.Expect(output).To(MatchRegexp("```diff"))
+	})
+
+	t.Run("generates JSON change set", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s -o json",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`"action": "configured"`))
+		g.Expect(output).To(MatchRegexp(`"diff":`))
+		g.Expect(output).To(MatchRegexp(`"path": "/immutable"`))
+		g.Expect(output).To(MatchRegexp(`"new": true`))
+	})
+
+	t.Run("reports drift only for owned fields", func(t *testing.T) {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		configMap.Data["other"] = "test"
+		err = envTestClient.Update(context.Background(), configMap, client.FieldOwner("other-controller"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).NotTo(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s drifted", id)))
+
+		configMap.Data["key"] = "changed"
+		err = envTestClient.Update(context.Background(), configMap, client.FieldOwner("other-controller"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err = executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --no-color",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s drifted", id)))
+		g.Expect(output).To(MatchRegexp(`\+  key: test`))
+	})
+
+	t.Run("exits with code 1 on drift", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --exit-code",
+			id,
+			dir,
+			id,
+		))
+
+		var exitErr *exitCodeError
+		g.Expect(errors.As(err, &exitErr)).To(BeTrue())
+		g.Expect(exitErr.code).To(Equal(1))
+	})
+
+	t.Run("exits with code 2 when the diff fails", func(t *testing.T) {
+		dir, err := makeTestDir(id+"-invalid", []TestFile{
+			{
+				Name: "invalid.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: Invalid_Name
+  namespace: "%[1]s"
+data:
+  key: "value"
+`, id),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"diff inv %s -f %s -n %s --exit-code",
+			id,
+			dir,
+			id,
+		))
+
+		var exitErr *exitCodeError
+		g.Expect(errors.As(err, &exitErr)).To(BeTrue())
+		g.Expect(exitErr.code).To(Equal(2))
+		g.Expect(err.Error()).To(ContainSubstring("diff failed for 1 object(s)"))
+	})
 }
@@ -0,0 +1,242 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var diffLocalCmd = &cobra.Command{
+	Use:   "local",
+	Short: "Diff builds two sets of Kubernetes manifests and prints the differences between them to stdout, without connecting to a cluster.",
+	Example: `  kustomizer diff local --from <path|oci url> --to <path|oci url>
+
+  # Diff two local kustomize overlays
+  kustomizer diff local --from ./overlays/staging --to ./overlays/prod
+
+  # Diff two versions of an OCI artifact
+  kustomizer diff local --from oci://registry/org/repo:v1 --to oci://registry/org/repo:v2
+
+  # Diff the manifests from an OCI artifact with a local overlay and print the change set as JSON
+  kustomizer diff local --from oci://registry/org/repo:latest --to ./overlays/prod -o json
+`,
+	RunE: runDiffLocalCmd,
+}
+
+type diffLocalFlags struct {
+	from          string
+	to            string
+	ageIdentities string
+	output        string
+	diffContext   int
+	noColor       bool
+}
+
+var diffLocalArgs diffLocalFlags
+
+func init() {
+	diffLocalCmd.Flags().StringVar(&diffLocalArgs.from, "from", "",
+		"The source of the current manifests, can be a directory that contains a kustomization.yaml, a path to Kubernetes manifest(s) or an OCI artifact URL.")
+	diffLocalCmd.Flags().StringVar(&diffLocalArgs.to, "to", "",
+		"The source of the desired manifests, can be a directory that contains a kustomization.yaml, a path to Kubernetes manifest(s) or an OCI artifact URL.")
+	diffLocalCmd.Flags().StringVar(&diffLocalArgs.ageIdentities, "age-identities", "",
+		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	diffLocalCmd.Flags().StringVarP(&diffLocalArgs.output, "output", "o", "",
+		"Write the change set to stdout in JSON or YAML format, or as a Markdown or HTML report.")
+	diffLocalCmd.Flags().IntVar(&diffLocalArgs.diffContext, "diff-context", 3,
+		"The number of unchanged lines printed around the changed lines in the unified diff.")
+	diffLocalCmd.Flags().BoolVar(&diffLocalArgs.noColor, "no-color", false,
+		"Print the unified diff without colors, the colors are also disabled when the NO_COLOR environment variable is set.")
+
+	diffCmd.AddCommand(diffLocalCmd)
+}
+
+func runDiffLocalCmd(cmd *cobra.Command, args []string) error {
+	if diffLocalArgs.from == "" || diffLocalArgs.to == "" {
+		return fmt.Errorf("--from and --to are required")
+	}
+
+	identities, err := registry.ParseAgeIdentities(diffLocalArgs.ageIdentities)
+	if err != nil {
+		return fmt.Errorf("faild to read decryption keys: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	fromObjects, err := buildLocalManifests(ctx, diffLocalArgs.from, identities)
+	if err != nil {
+		return err
+	}
+
+	toObjects, err := buildLocalManifests(ctx, diffLocalArgs.to, identities)
+	if err != nil {
+		return err
+	}
+
+	printer, err := newChangeSetPrinter(diffLocalArgs.output, func(entry changeSetEntry) {
+		rootCmd.Println(`►`, entry.Subject, entry.Action)
+		if entry.Diff != "" {
+			rootCmd.Println(entry.Diff)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	color := !printer.isStructured() && useColor(diffLocalArgs.noColor)
+	if err := printObjectsDiff(printer, fromObjects, toObjects, diffLocalArgs.diffContext, color); err != nil {
+		return err
+	}
+
+	return printer.Flush()
+}
+
+// printObjectsDiff prints the objects added, changed or removed between the current and the desired set,
+// for the changed objects the unified diff is printed with the Secrets values masked.
+func printObjectsDiff(printer *changeSetPrinter, currentObjects, desiredObjects []*unstructured.Unstructured,
+	diffContext int, color bool) error {
+	current := make(map[string]*unstructured.Unstructured, len(currentObjects))
+	for _, object := range currentObjects {
+		current[ssa.FmtUnstructured(object)] = object
+	}
+
+	desired := make(map[string]bool, len(desiredObjects))
+	for _, object := range desiredObjects {
+		id := ssa.FmtUnstructured(object)
+		desired[id] = true
+
+		currentObject, ok := current[id]
+		if !ok {
+			printer.Print(ssa.ChangeSetEntry{Subject: id, Action: string(ssa.CreatedAction)})
+			continue
+		}
+
+		currentYAML, desiredYAML, err := maskedYAML(currentObject, object)
+		if err != nil {
+			return err
+		}
+
+		diff, err := unifiedDiff(currentYAML, desiredYAML, diffContext, color)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			continue
+		}
+		printer.PrintDiff(ssa.ChangeSetEntry{Subject: id, Action: string(ssa.ConfiguredAction)}, diff)
+	}
+
+	for _, object := range currentObjects {
+		id := ssa.FmtUnstructured(object)
+		if !desired[id] {
+			printer.Print(ssa.ChangeSetEntry{Subject: id, Action: string(ssa.DeletedAction)})
+		}
+	}
+
+	return nil
+}
+
+// buildLocalManifests builds the manifests from an OCI artifact URL, a kustomize overlay or a path to manifests.
+func buildLocalManifests(ctx context.Context, source string, identities []age.Identity) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	var err error
+	switch {
+	case strings.HasPrefix(source, "oci://"):
+		objects, _, err = buildManifests(ctx, "", nil, []string{source}, nil, identities, nil)
+	case isKustomization(source):
+		objects, _, err = buildManifests(ctx, source, nil, nil, nil, identities, nil)
+	default:
+		objects, _, err = buildManifests(ctx, "", []string{source}, nil, nil, identities, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(ssa.SortableUnstructureds(objects))
+	return objects, nil
+}
+
+func isKustomization(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "kustomization.yaml"))
+	return err == nil
+}
+
+// maskedYAML returns the YAML representation of the given objects,
+// the Secrets data values and the fields matched by the config masking rules are masked
+// while preserving the information about which values changed.
+func maskedYAML(currentObject, desiredObject *unstructured.Unstructured) (string, string, error) {
+	current, desired := maskFields(currentObject.DeepCopy(), desiredObject.DeepCopy())
+	if desired.GetKind() == "Secret" {
+		for _, field := range []string{"data", "stringData"} {
+			currentData, _, _ := unstructured.NestedStringMap(current.Object, field)
+			desiredData, _, _ := unstructured.NestedStringMap(desired.Object, field)
+			maskSecretData(currentData, desiredData)
+			if currentData != nil {
+				_ = unstructured.SetNestedStringMap(current.Object, currentData, field)
+			}
+			if desiredData != nil {
+				_ = unstructured.SetNestedStringMap(desired.Object, desiredData, field)
+			}
+		}
+	}
+
+	currentYAML, err := yaml.Marshal(current.Object)
+	if err != nil {
+		return "", "", err
+	}
+	desiredYAML, err := yaml.Marshal(desired.Object)
+	if err != nil {
+		return "", "", err
+	}
+	return string(currentYAML), string(desiredYAML), nil
+}
+
+func maskSecretData(current, desired map[string]string) {
+	const mask = "*****"
+	for key, value := range current {
+		desiredValue, ok := desired[key]
+		switch {
+		case !ok:
+			current[key] = mask
+		case desiredValue != value:
+			current[key] = mask + " (before)"
+			desired[key] = mask + " (after)"
+		default:
+			current[key] = mask
+			desired[key] = mask
+		}
+	}
+	for key := range desired {
+		if _, ok := current[key]; !ok {
+			desired[key] = mask
+		}
+	}
+}
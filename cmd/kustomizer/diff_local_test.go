@@ -0,0 +1,84 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/stefanprodan/kustomizer/pkg/config"
+)
+
+func TestDiffLocal(t *testing.T) {
+	g := NewWithT(t)
+	id := randStringRunes(5)
+
+	dir1, err := makeTestDir(id+"1", testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir2, err := makeTestDir(id+"2", testManifests(id, id, true))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir3, err := makeTestDir(id+"3", testManifests(id+"-1", id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("diffs overlays", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"diff local --from %s --to %s --no-color",
+			dir1,
+			dir2,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%[1]s/%[1]s configured", id)))
+		g.Expect(output).To(MatchRegexp(`\+immutable: true`))
+		g.Expect(output).To(MatchRegexp(`\*\*\*\*\* \(after\)`))
+		g.Expect(output).NotTo(MatchRegexp("ConfigMap"))
+	})
+
+	t.Run("masks fields matched by config rules", func(t *testing.T) {
+		cfg.Masking = []config.MaskingRule{{Kind: "Sec*", Paths: []string{"/immutable"}}}
+		defer func() { cfg.Masking = nil }()
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff local --from %s --to %s --no-color",
+			dir1,
+			dir2,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`\+immutable: '\*\*\*\*\* \(after\)'`))
+		g.Expect(output).NotTo(MatchRegexp(`immutable: true`))
+	})
+
+	t.Run("diffs renamed objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"diff local --from %s --to %s",
+			dir1,
+			dir3,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s-1 created", id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%[1]s/%[1]s deleted", id)))
+	})
+}
@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/olekukonko/tablewriter"
@@ -39,12 +41,21 @@ var getInventories = &cobra.Command{
 
   # Get all inventories in the specified namespace
   kustomizer get inventories -n apps
+
+  # Get the inventories of a group across all namespaces along with the readiness of their objects
+  kustomizer get inventories --group platform --all-namespaces --status
+
+  # Get all inventories across all namespaces in JSON format
+  kustomizer get inventories --all-namespaces -o json
 `,
 	RunE: runGetInventoriesCmd,
 }
 
 type getInventoriesFlags struct {
 	allNamespaces bool
+	group         string
+	status        bool
+	output        string
 }
 
 var getInventoriesArgs getInventoriesFlags
@@ -52,6 +63,12 @@ var getInventoriesArgs getInventoriesFlags
 func init() {
 	getInventories.Flags().BoolVar(&getInventoriesArgs.allNamespaces, "all-namespaces", false,
 		"list the requested object(s) across all namespaces.")
+	getInventories.Flags().StringVar(&getInventoriesArgs.group, "group", "",
+		"List only the inventories that belong to the given group.")
+	getInventories.Flags().BoolVar(&getInventoriesArgs.status, "status", false,
+		"Print the number of objects that are ready for each inventory.")
+	getInventories.Flags().StringVarP(&getInventoriesArgs.output, "output", "o", "",
+		"Print the inventories in JSON or YAML format.")
 
 	getCmd.AddCommand(getInventories)
 }
@@ -62,6 +79,10 @@ func runGetInventoriesCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("you must specify an inventory namespace")
 	}
 
+	if err := validateOutput(getInventoriesArgs.output); err != nil {
+		return err
+	}
+
 	kubeClient, err := newKubeClient(kubeconfigArgs)
 	if err != nil {
 		return fmt.Errorf("client init failed: %w", err)
@@ -77,6 +98,7 @@ func runGetInventoriesCmd(cmd *cobra.Command, args []string) error {
 	invStorage := &inventory.Storage{
 		Manager: resMgr,
 		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
@@ -91,26 +113,92 @@ func runGetInventoriesCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if getInventoriesArgs.group != "" {
+		inventories = inventory.FilterByGroup(inventories, getInventoriesArgs.group)
+	}
+
+	if isStructuredOutput(getInventoriesArgs.output) {
+		outputs := make([]inventoryOutput, 0, len(inventories))
+		for _, inv := range inventories {
+			out := newInventoryOutput(inv)
+			if getInventoriesArgs.status {
+				ready, err := countReadyObjects(ctx, resMgr, inv)
+				if err != nil {
+					return err
+				}
+				out.Ready = &ready
+			}
+			outputs = append(outputs, out)
+		}
+		return printOutput(getInventoriesArgs.output, outputs)
+	}
+
 	var rows [][]string
 	for _, inv := range inventories {
 		row := []string{}
 		if getInventoriesArgs.allNamespaces {
-			row = []string{inv.Name, inv.Namespace, fmt.Sprintf("%v", len(inv.Resources)), inv.Source, inv.Revision, inv.LastAppliedAt}
+			row = []string{inv.Name, inv.Namespace, fmt.Sprintf("%v", len(inv.Resources)), inv.Source, inv.Revision, inv.LastAppliedAt, formatInventoryMeta(inv.Meta)}
 		} else {
-			row = []string{inv.Name, fmt.Sprintf("%v", len(inv.Resources)), inv.Source, inv.Revision, inv.LastAppliedAt}
+			row = []string{inv.Name, fmt.Sprintf("%v", len(inv.Resources)), inv.Source, inv.Revision, inv.LastAppliedAt, formatInventoryMeta(inv.Meta)}
+		}
+		if getInventoriesArgs.status {
+			ready, err := countReadyObjects(ctx, resMgr, inv)
+			if err != nil {
+				return err
+			}
+			row = append(row, fmt.Sprintf("%d/%d", ready, len(inv.Resources)))
 		}
 		rows = append(rows, row)
 	}
 
+	header := []string{"name", "entries", "source", "revision", "last applied", "meta"}
 	if getInventoriesArgs.allNamespaces {
-		printTable(rootCmd.OutOrStdout(), []string{"name", "namespace", "entries", "source", "revision", "last applied"}, rows)
-	} else {
-		printTable(rootCmd.OutOrStdout(), []string{"name", "entries", "source", "revision", "last applied"}, rows)
+		header = []string{"name", "namespace", "entries", "source", "revision", "last applied", "meta"}
+	}
+	if getInventoriesArgs.status {
+		header = append(header, "ready")
 	}
+	printTable(rootCmd.OutOrStdout(), header, rows)
 
 	return nil
 }
 
+// countReadyObjects returns the number of objects in the given inventory that are ready.
+func countReadyObjects(ctx context.Context, resMgr *ssa.ResourceManager, inv *inventory.Inventory) (int, error) {
+	objects, err := inv.ListObjects()
+	if err != nil {
+		return 0, err
+	}
+
+	ready := 0
+	for _, object := range objects {
+		if objectStatus(ctx, resMgr, object) == "ready" {
+			ready++
+		}
+	}
+	return ready, nil
+}
+
+// formatInventoryMeta returns the inventory metadata as a comma separated list of 'key=value' pairs.
+func formatInventoryMeta(meta map[string]string) string {
+	return strings.Join(inventoryMetaPairs(meta), ",")
+}
+
+// inventoryMetaPairs returns the inventory metadata in the format 'key=value' sorted by key.
+func inventoryMetaPairs(meta map[string]string) []string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, meta[k]))
+	}
+	return pairs
+}
+
 func printTable(writer io.Writer, header []string, rows [][]string) {
 	table := tablewriter.NewWriter(writer)
 	table.SetHeader(header)
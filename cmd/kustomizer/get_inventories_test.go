@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -80,4 +81,64 @@ func TestGetInventories(t *testing.T) {
 		g.Expect(output).To(MatchRegexp(fmt.Sprintf("1-%s", id)))
 		g.Expect(output).To(MatchRegexp(fmt.Sprintf("2-%s", id)))
 	})
+
+	t.Run("gets inventories in JSON format", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"get inventories --namespace %s -o json",
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		var inventories []inventoryOutput
+		err = json.Unmarshal([]byte(output), &inventories)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(inventories).To(HaveLen(2))
+		g.Expect(inventories[0].Namespace).To(Equal(id))
+		g.Expect(inventories[0].Source).To(Equal(source))
+	})
+}
+
+func TestGetInventoriesGroup(t *testing.T) {
+	g := NewWithT(t)
+	id := "group-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates inventories", func(t *testing.T) {
+		for _, name := range []string{"1-" + id, "2-" + id, "3-" + id} {
+			dir, err := makeTestDir(name, testManifests(name, id, false))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			group := "platform"
+			if name == "3-"+id {
+				group = "apps"
+			}
+
+			_, err = executeCommand(fmt.Sprintf(
+				"apply inv %s -k %s --namespace %s --group %s",
+				name,
+				dir,
+				id,
+				group,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	t.Run("gets the inventories of a group with their status", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"get inventories --namespace %s --group platform --status",
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("1-%s", id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("2-%s", id)))
+		g.Expect(output).NotTo(MatchRegexp(fmt.Sprintf("3-%s", id)))
+		g.Expect(output).To(MatchRegexp(`2/2`))
+	})
 }
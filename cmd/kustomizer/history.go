@@ -0,0 +1,127 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "History prints a table with the revisions recorded in the given inventory.",
+	Example: `  kustomizer history -i <name> -n <namespace>
+
+  # List the revisions of an inventory with their timestamps and change counts
+  kustomizer history -i my-app -n apps
+
+  # List the revisions of an inventory in JSON format
+  kustomizer history -i my-app -n apps -o json
+`,
+	RunE: runHistoryCmd,
+}
+
+type historyFlags struct {
+	inventory string
+	output    string
+}
+
+var historyArgs historyFlags
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyArgs.inventory, "inventory", "i", "",
+		"The name of the inventory.")
+	historyCmd.Flags().StringVarP(&historyArgs.output, "output", "o", "",
+		"Print the revisions in JSON or YAML format.")
+
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistoryCmd(cmd *cobra.Command, args []string) error {
+	if historyArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+
+	if err := validateOutput(historyArgs.output); err != nil {
+		return err
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(historyArgs.inventory, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	if isStructuredOutput(historyArgs.output) {
+		outputs := make([]revisionOutput, 0, len(i.History))
+		for _, rev := range i.History {
+			outputs = append(outputs, newRevisionOutput(rev))
+		}
+		return printOutput(historyArgs.output, outputs)
+	}
+
+	if len(i.History) == 0 {
+		rootCmd.Println(fmt.Sprintf("no revisions recorded for inventory %s/%s", i.Namespace, i.Name))
+		return nil
+	}
+
+	var rows [][]string
+	for _, rev := range i.History {
+		checksum := rev.Checksum
+		if len(checksum) > 12 {
+			checksum = checksum[:12]
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(rev.Number),
+			rev.AppliedAt,
+			rev.Source,
+			rev.Revision,
+			strconv.Itoa(len(rev.Resources)),
+			strconv.Itoa(rev.Changes),
+			checksum,
+		})
+	}
+
+	printTable(rootCmd.OutOrStdout(), []string{"revision", "applied at", "source", "source revision", "entries", "changes", "checksum"}, rows)
+	return nil
+}
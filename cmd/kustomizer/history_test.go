@@ -0,0 +1,82 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestHistory(t *testing.T) {
+	g := NewWithT(t)
+	id := "history-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("records a revision for each apply", func(t *testing.T) {
+		for _, revision := range []string{"v1.0.0", "v2.0.0", "v3.0.0"} {
+			output, err := executeCommand(fmt.Sprintf(
+				"apply inv %s -k %s --namespace %s --revision %s --history-limit 2",
+				id,
+				dir,
+				id,
+				revision,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("lists the revisions within the history limit", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"history -i %s --namespace %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).NotTo(MatchRegexp("v1.0.0"))
+		g.Expect(output).To(MatchRegexp("v2.0.0"))
+		g.Expect(output).To(MatchRegexp("v3.0.0"))
+	})
+
+	t.Run("lists the revisions in JSON format", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"history -i %s --namespace %s -o json",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		var revisions []revisionOutput
+		err = json.Unmarshal([]byte(output), &revisions)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(revisions).To(HaveLen(2))
+		g.Expect(revisions[1].Revision).To(Equal("v3.0.0"))
+		g.Expect(revisions[1].Entries).To(Equal(3))
+	})
+}
@@ -19,7 +19,7 @@ package main
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
@@ -31,19 +31,29 @@ import (
 var inspectArtifactCmd = &cobra.Command{
 	Use:   "artifact",
 	Short: "Inspect downloads the specified OCI artifact and prints a report of its content.",
-	Long: `The inspect command downloads the specified OCI artifact and prints the artifact metadata,
+	Long: `The inspect command downloads the specified OCI artifact and prints the OCI manifest details, the artifact metadata,
+the attached referrers e.g. SBOMs and attestations, a summary of the Kubernetes objects by kind,
 lists the Kubernetes objects and the container image references.
-For private registries, the inspect command uses the credentials from '~/.docker/config.json'.`,
+For private registries, the inspect command uses the credentials from '~/.docker/config.json', or the credentials specified with '--creds' or '--registry-token'.`,
 	Example: ` kustomizer inspect artifact <oci url>
 
   # Inspect an OCI artifact
   kustomizer inspect artifact oci://docker.io/user/repo:latest
 
+  # Inspect an OCI artifact by digest
+  kustomizer inspect artifact oci://docker.io/user/repo@sha256:<digest>
+
+  # Inspect the production variant of a bundle artifact
+  kustomizer inspect artifact oci://docker.io/user/repo:v1.0.0#production
+
+  # Inspect the newest artifact in the 1.x range
+  kustomizer inspect artifact oci://docker.io/user/repo --semver "1.x"
+
   # Verify artifact with cosign public key
   kustomizer inspect artifact oci://docker.io/user/repo:v1.0.0 --verify --cosign-key ./keys/cosign.pub
 
   # Verify artifact signed with cosign and GitHub OIDC
-  kustomizer inspect artifact oci://docker.io/user/repo:v1.0.0 --verify
+  kustomizer inspect artifact oci://docker.io/user/repo:v1.0.0 --verify --certificate-identity https://github.com/org/repo/.github/workflows/release.yaml@refs/tags/v1.0.0 --certificate-oidc-issuer https://token.actions.githubusercontent.com
 
   # List only the container images references
   kustomizer inspect artifact oci://docker.io/user/repo:v1.0 --container-images
@@ -54,8 +64,12 @@ For private registries, the inspect command uses the credentials from '~/.docker
 type inspectArtifactFlags struct {
 	containerImages bool
 	ageIdentities   string
+	decryptIdentity []string
 	verify          bool
 	verifyKey       string
+	certIdentity    string
+	certOIDCIssuer  string
+	semverExp       string
 }
 
 var inspectArtifactArgs inspectArtifactFlags
@@ -65,11 +79,20 @@ func init() {
 		"List only the container images referenced in the Kubernetes manifests.")
 	inspectArtifactCmd.Flags().StringVar(&inspectArtifactArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	inspectArtifactCmd.Flags().StringSliceVar(&inspectArtifactArgs.decryptIdentity, "decrypt-identity", nil,
+		"Path to an age identity file used to decrypt encrypted artifacts, can be specified multiple times.")
 	inspectArtifactCmd.Flags().BoolVar(&inspectArtifactArgs.verify, "verify", false,
 		"Verify the artifact signature with cosign.")
 	inspectArtifactCmd.Flags().StringVar(&inspectArtifactArgs.verifyKey, "cosign-key", "",
 		"Path to the consign public key file, KMS URI or Kubernetes Secret. "+
-			"When not specified, cosign will try to verify the signature using Rekor.")
+			"When not specified, cosign verifies the keyless signature using Rekor and the certificate identity constraints.")
+	inspectArtifactCmd.Flags().StringVar(&inspectArtifactArgs.certIdentity, "certificate-identity", "",
+		"The identity expected in the keyless signing certificate e.g. 'https://github.com/org/repo/.github/workflows/release.yaml@refs/tags/v1.0.0'.")
+	inspectArtifactCmd.Flags().StringVar(&inspectArtifactArgs.certOIDCIssuer, "certificate-oidc-issuer", "",
+		"The OIDC issuer expected in the keyless signing certificate e.g. 'https://token.actions.githubusercontent.com'.")
+
+	inspectArtifactCmd.Flags().StringVar(&inspectArtifactArgs.semverExp, "semver", "",
+		"Inspect the newest tag that matches the semantic version constraint e.g. '>=1.0 <2.0'.")
 
 	inspectCmd.AddCommand(inspectArtifactCmd)
 }
@@ -79,37 +102,38 @@ func runInspectArtifactCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("you must specify an OCI URL e.g. 'oci://docker.io/user/repo:tag'")
 	}
 
-	url, err := registry.ParseURL(args[0])
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	baseURL, variant := registry.SplitVariant(args[0])
+	url, err := registry.ResolveURL(ctx, registryOpts, baseURL, inspectArtifactArgs.semverExp)
 	if err != nil {
 		return err
 	}
 
 	verified := false
 	if inspectArtifactArgs.verify {
-		if err := verifyCosign(url, inspectArtifactArgs.verifyKey); err != nil {
+		url, err = verifyArtifact(ctx, url, cosignVerifyOptions{
+			key:            inspectArtifactArgs.verifyKey,
+			certIdentity:   inspectArtifactArgs.certIdentity,
+			certOIDCIssuer: inspectArtifactArgs.certOIDCIssuer,
+		})
+		if err != nil {
 			return err
 		}
 		verified = true
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
-	defer cancel()
-
-	identities, err := registry.ParseAgeIdentities(inspectArtifactArgs.ageIdentities)
+	identities, err := registry.ParseAgeIdentities(append([]string{inspectArtifactArgs.ageIdentities}, inspectArtifactArgs.decryptIdentity...)...)
 	if err != nil {
 		return fmt.Errorf("faild to read decryption keys: %w", err)
 	}
 
-	yml, meta, err := registry.Pull(ctx, url, identities)
+	objects, meta, err := pullArtifactObjects(ctx, url, variant, identities)
 	if err != nil {
 		return fmt.Errorf("pulling %s failed: %w", url, err)
 	}
 
-	objects, err := ssa.ReadObjects(strings.NewReader(yml))
-	if err != nil {
-		return err
-	}
-
 	if inspectArtifactArgs.containerImages {
 		images := make(map[string]bool)
 		for _, object := range objects {
@@ -123,7 +147,15 @@ func runInspectArtifactCmd(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	manifest, err := registry.GetManifest(ctx, registryOpts, url)
+	if err != nil {
+		return fmt.Errorf("fetching manifest %s failed: %w", url, err)
+	}
+
 	rootCmd.Println(fmt.Sprintf("Artifact: oci://%s", meta.Digest))
+	if manifest.MediaType != "" {
+		rootCmd.Println("MediaType:", manifest.MediaType)
+	}
 	rootCmd.Println("BuiltBy:", fmt.Sprintf("kustomizer/v%s", meta.Version))
 	if verified {
 		rootCmd.Println("VerifiedBy: cosign")
@@ -133,6 +165,42 @@ func runInspectArtifactCmd(cmd *cobra.Command, args []string) error {
 		rootCmd.Println("EncryptedWith:", meta.Encrypted)
 	}
 	rootCmd.Println("Checksum:", meta.Checksum)
+	if meta.Mode != registry.ManifestsMode {
+		rootCmd.Println("Mode:", meta.Mode)
+	}
+	if len(meta.Annotations) > 0 {
+		rootCmd.Println("Annotations:")
+		keys := make([]string, 0, len(meta.Annotations))
+		for k := range meta.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rootCmd.Println(fmt.Sprintf("- %s: %s", k, meta.Annotations[k]))
+		}
+	}
+	rootCmd.Println(fmt.Sprintf("Layers: (total size %s)", formatSize(manifest.TotalSize())))
+	for _, layer := range manifest.Layers {
+		title := ""
+		if layer.Title != "" {
+			title = layer.Title + " "
+		}
+		rootCmd.Println(fmt.Sprintf("- %s%s %s (%s)", title, layer.Digest, layer.MediaType, formatSize(layer.Size)))
+	}
+	referrers, err := registry.Referrers(ctx, registryOpts, manifest.Digest)
+	if err != nil {
+		return fmt.Errorf("fetching referrers %s failed: %w", url, err)
+	}
+	if len(referrers) > 0 {
+		rootCmd.Println("Referrers:")
+		for _, referrer := range referrers {
+			rootCmd.Println(fmt.Sprintf("- %s %s (%s)", referrer.Digest, referrer.ArtifactType, formatSize(referrer.Size)))
+		}
+	}
+	rootCmd.Println("Summary:")
+	for _, kc := range countObjectsByKind(objects) {
+		rootCmd.Println(fmt.Sprintf("- %s: %d", kc.kind, kc.count))
+	}
 	rootCmd.Println("Resources:")
 	for _, object := range objects {
 		rootCmd.Println("-", ssa.FmtUnstructured(object))
@@ -145,6 +213,40 @@ func runInspectArtifactCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+type kindCount struct {
+	kind  string
+	count int
+}
+
+// countObjectsByKind returns the number of objects of each kind ordered alphabetically by kind.
+func countObjectsByKind(objects []*unstructured.Unstructured) []kindCount {
+	counts := make(map[string]int)
+	for _, object := range objects {
+		counts[object.GetKind()]++
+	}
+
+	result := make([]kindCount, 0, len(counts))
+	for kind, count := range counts {
+		result = append(result, kindCount{kind: kind, count: count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].kind < result[j].kind })
+	return result
+}
+
+// formatSize returns the size in bytes in a human-readable form using binary units.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 func getContainerImages(object *unstructured.Unstructured) []string {
 	images := make(map[string]bool)
 	var containers []interface{}
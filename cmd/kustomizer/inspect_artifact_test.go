@@ -56,6 +56,9 @@ func TestInspect(t *testing.T) {
 		g.Expect(err).NotTo(HaveOccurred())
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp(id))
+		g.Expect(output).To(MatchRegexp(`Layers: \(total size .+\)`))
+		g.Expect(output).To(MatchRegexp(`- ConfigMap: 1`))
+		g.Expect(output).To(MatchRegexp(`- CronJob: 1`))
 	})
 
 	t.Run("inspect artifact images", func(t *testing.T) {
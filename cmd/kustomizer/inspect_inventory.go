@@ -19,9 +19,15 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/stefanprodan/kustomizer/pkg/inventory"
 )
@@ -29,16 +35,34 @@ import (
 var inspectInventoryCmd = &cobra.Command{
 	Use:     "inventory",
 	Aliases: []string{"inv"},
-	Short:   "Inspect prints the content of the given inventory.",
+	Short:   "Inspect prints the content of the given inventory and the live status of the tracked objects.",
 	Example: ` kustomizer inspect inventory <name> -n <namespace>
 
-  # Get an inventory and list its content
+  # Get an inventory and list its content along with the readiness of each object
   kustomizer inspect inv my-app -n apps
+
+  # Print the manifests recorded with 'apply inventory --store-manifests' for the latest revision
+  kustomizer inspect inv my-app -n apps --export > my-app.yaml
+
+  # Print the inventory details and the status of each object in JSON format
+  kustomizer inspect inv my-app -n apps -o json
 `,
 	RunE: runInspectInventoryCmd,
 }
 
+type inspectInventoryFlags struct {
+	export bool
+	output string
+}
+
+var inspectInventoryArgs inspectInventoryFlags
+
 func init() {
+	inspectInventoryCmd.Flags().BoolVar(&inspectInventoryArgs.export, "export", false,
+		"Print the manifests stored for the latest revision instead of the inventory details.")
+	inspectInventoryCmd.Flags().StringVarP(&inspectInventoryArgs.output, "output", "o", "",
+		"Print the inventory details in JSON or YAML format.")
+
 	inspectCmd.AddCommand(inspectInventoryCmd)
 }
 
@@ -48,6 +72,10 @@ func runInspectInventoryCmd(cmd *cobra.Command, args []string) error {
 	}
 	name := args[0]
 
+	if err := validateOutput(inspectInventoryArgs.output); err != nil {
+		return err
+	}
+
 	i := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
 
 	kubeClient, err := newKubeClient(kubeconfigArgs)
@@ -65,6 +93,7 @@ func runInspectInventoryCmd(cmd *cobra.Command, args []string) error {
 	invStorage := &inventory.Storage{
 		Manager: resMgr,
 		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
@@ -74,6 +103,39 @@ func runInspectInventoryCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if inspectInventoryArgs.export {
+		rev := i.CurrentRevision()
+		if rev == nil || !rev.StoredManifests {
+			return fmt.Errorf("no manifests stored for inventory %s/%s, use 'apply inventory --store-manifests'", i.Namespace, i.Name)
+		}
+		manifests, err := invStorage.LoadManifests(ctx, i, rev.Number)
+		if err != nil {
+			return err
+		}
+		rootCmd.Print(manifests)
+		return nil
+	}
+
+	if isStructuredOutput(inspectInventoryArgs.output) {
+		objects, err := i.ListObjects()
+		if err != nil {
+			return err
+		}
+		entries := inventoryEntries(i)
+		out := newInventoryOutput(i)
+		for _, object := range objects {
+			entry := entries[ssa.FmtUnstructured(object)]
+			out.Objects = append(out.Objects, inventoryObjectOutput{
+				Subject:    ssa.FmtUnstructured(object),
+				Status:     objectStatus(ctx, resMgr, object),
+				Action:     entry.Action,
+				AppliedAt:  entry.AppliedAt,
+				Generation: entry.Generation,
+			})
+		}
+		return printOutput(inspectInventoryArgs.output, out)
+	}
+
 	rootCmd.Println(fmt.Sprintf("Inventory: %s/%s", i.Namespace, i.Name))
 	rootCmd.Println(fmt.Sprintf("LastAppliedAt: %s", i.LastAppliedAt))
 	if len(i.Source) > 0 {
@@ -82,20 +144,108 @@ func runInspectInventoryCmd(cmd *cobra.Command, args []string) error {
 	if len(i.Revision) > 0 {
 		rootCmd.Println(fmt.Sprintf("Revision: %s", i.Revision))
 	}
+	if len(i.Namespaces) > 0 {
+		rootCmd.Println(fmt.Sprintf("Namespaces: %s", strings.Join(i.Namespaces, ", ")))
+	}
+	if len(i.Meta) > 0 {
+		rootCmd.Println("Meta:")
+		for _, pair := range inventoryMetaPairs(i.Meta) {
+			rootCmd.Println(fmt.Sprintf("- %s", pair))
+		}
+	}
 	if len(i.Artifacts) > 0 {
 		rootCmd.Println("Artifacts:")
 		for _, entry := range i.Artifacts {
 			rootCmd.Println(fmt.Sprintf("- oci://%s", entry))
 		}
 	}
+	if p := i.Provenance; p != nil {
+		rootCmd.Println("Provenance:")
+		for _, artifact := range p.Artifacts {
+			rootCmd.Println(fmt.Sprintf("- artifact: %s (%s)", artifact.URL, artifact.Digest))
+		}
+		if p.GitURL != "" {
+			rootCmd.Println(fmt.Sprintf("- git: %s (%s)", p.GitURL, p.GitCommit))
+		}
+		for _, path := range p.Paths {
+			rootCmd.Println(fmt.Sprintf("- path: %s", path))
+		}
+		if p.Checksum != "" {
+			rootCmd.Println(fmt.Sprintf("- checksum: sha256:%s", p.Checksum))
+		}
+		if p.KustomizerVersion != "" {
+			rootCmd.Println(fmt.Sprintf("- kustomizer: v%s", p.KustomizerVersion))
+		}
+	}
 	rootCmd.Println("Resources:")
-	entries, err := i.ListMeta()
+	objects, err := i.ListObjects()
 	if err != nil {
 		return err
 	}
-	for _, entry := range entries {
-		rootCmd.Println("-", ssa.FmtObjMetadata(entry))
+	entries := inventoryEntries(i)
+	for _, object := range objects {
+		status := objectStatus(ctx, resMgr, object)
+		if details := entryDetails(entries[ssa.FmtUnstructured(object)]); details != "" {
+			status = fmt.Sprintf("%s (%s)", status, details)
+		}
+		rootCmd.Println("-", ssa.FmtUnstructured(object), status)
 	}
 
 	return nil
 }
+
+// inventoryEntries returns the entries of the given inventory indexed by the object ID in the format 'kind/namespace/name'.
+func inventoryEntries(i *inventory.Inventory) map[string]inventory.Resource {
+	entries := make(map[string]inventory.Resource, len(i.Resources))
+	for _, entry := range i.Resources {
+		if objMetadata, err := object.ParseObjMetadata(entry.ObjectID); err == nil {
+			entries[ssa.FmtObjMetadata(objMetadata)] = entry
+		}
+	}
+	return entries
+}
+
+// entryDetails returns the apply metadata recorded for the given inventory entry.
+func entryDetails(entry inventory.Resource) string {
+	var details []string
+	if entry.Action != "" {
+		details = append(details, fmt.Sprintf("last action: %s", entry.Action))
+	}
+	if entry.AppliedAt != "" {
+		details = append(details, fmt.Sprintf("last changed at: %s", entry.AppliedAt))
+	}
+	if entry.Generation > 0 {
+		details = append(details, fmt.Sprintf("generation: %d", entry.Generation))
+	}
+	return strings.Join(details, ", ")
+}
+
+// objectStatus returns the existence and the readiness of the in-cluster object, computed with kstatus.
+func objectStatus(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured) string {
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	if err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "not found"
+		}
+		return fmt.Sprintf("unknown: %s", err)
+	}
+
+	result, err := status.Compute(existingObject)
+	if err != nil {
+		return fmt.Sprintf("unknown: %s", err)
+	}
+
+	switch result.Status {
+	case status.CurrentStatus:
+		return "ready"
+	case status.InProgressStatus:
+		return fmt.Sprintf("in progress: %s", result.Message)
+	case status.FailedStatus:
+		return fmt.Sprintf("failed: %s", result.Message)
+	case status.TerminatingStatus:
+		return "terminating"
+	default:
+		return strings.ToLower(result.Status.String())
+	}
+}
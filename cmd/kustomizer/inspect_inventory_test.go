@@ -66,4 +66,105 @@ func TestGetInventory(t *testing.T) {
 		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
 		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%s/%s", id, id)))
 	})
+
+	t.Run("prints provenance", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect inventory %s --namespace %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(ContainSubstring(dir))
+		g.Expect(output).To(MatchRegexp("checksum: sha256:[a-f0-9]{64}"))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("kustomizer: v%s", VERSION)))
+	})
+
+	t.Run("prints objects status", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect inventory %s --namespace %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s ready", id, id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%s/%s ready", id, id)))
+	})
+
+	t.Run("prints entries apply metadata", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect inventory %s --namespace %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`ConfigMap/%s/%s ready \(last action: created, last changed at: \S+\)`, id, id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`CronJob/%s/%s .*generation: 1\)`, id, id)))
+	})
+}
+
+func TestInspectInventoryMeta(t *testing.T) {
+	g := NewWithT(t)
+	id := "meta-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("records the metadata", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --set-meta description=payments,owner=team-payments",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect inventory %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(ContainSubstring("- description=payments"))
+		g.Expect(output).To(ContainSubstring("- owner=team-payments"))
+	})
+
+	t.Run("keeps the metadata on apply", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --set-meta owner=",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"get inventories -n %s",
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(ContainSubstring("description=payments"))
+		g.Expect(output).ToNot(ContainSubstring("owner=team-payments"))
+	})
+
+	t.Run("fails for invalid keys", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --set-meta Owner/Team=payments",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+	})
 }
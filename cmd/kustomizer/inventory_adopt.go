@@ -0,0 +1,214 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryAdoptCmd = &cobra.Command{
+	Use:   "adopt",
+	Short: "Adopt records the live objects that match a label selector in an inventory, without applying them.",
+	Example: `  kustomizer inventory adopt -i <name> -n <namespace> --selector <selector>
+
+  # Record the objects deployed with 'kubectl apply -l app=my-app --prune' in the my-app inventory
+  kustomizer inventory adopt -i my-app -n apps --selector app=my-app
+
+  # Record the matching objects and label them with the inventory name and namespace
+  kustomizer inventory adopt -i my-app -n apps --selector app=my-app --relabel
+
+  # Record only the matching objects applied by kubectl
+  kustomizer inventory adopt -i my-app -n apps --selector app=my-app --field-manager kubectl --field-manager kubectl-client-side-apply
+
+  # Print the objects that would be adopted across all namespaces
+  kustomizer inventory adopt -i my-app -n apps --selector app=my-app --all-namespaces --dry-run
+`,
+	RunE: runInventoryAdoptCmd,
+}
+
+type inventoryAdoptFlags struct {
+	inventory     string
+	selector      string
+	fieldManagers []string
+	relabel       bool
+	allNamespaces bool
+	dryRun        bool
+}
+
+var inventoryAdoptArgs inventoryAdoptFlags
+
+func init() {
+	inventoryAdoptCmd.Flags().StringVarP(&inventoryAdoptArgs.inventory, "inventory", "i", "",
+		"The name of the inventory, the inventory is created if it doesn't exist.")
+	inventoryAdoptCmd.Flags().StringVarP(&inventoryAdoptArgs.selector, "selector", "l", "",
+		"Label selector for the live objects to adopt e.g. 'app=my-app'.")
+	inventoryAdoptCmd.Flags().StringSliceVar(&inventoryAdoptArgs.fieldManagers, "field-manager", nil,
+		"Adopt only the objects whose managed fields contain one of the given field managers.")
+	inventoryAdoptCmd.Flags().BoolVar(&inventoryAdoptArgs.relabel, "relabel", false,
+		"Set the inventory labels on the adopted objects.")
+	inventoryAdoptCmd.Flags().BoolVar(&inventoryAdoptArgs.allNamespaces, "all-namespaces", false,
+		"Look up the namespaced objects across all namespaces instead of the inventory namespace.")
+	inventoryAdoptCmd.Flags().BoolVar(&inventoryAdoptArgs.dryRun, "dry-run", false,
+		"Print the objects that would be adopted without changing the inventory.")
+
+	inventoryCmd.AddCommand(inventoryAdoptCmd)
+}
+
+func runInventoryAdoptCmd(cmd *cobra.Command, args []string) error {
+	if inventoryAdoptArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+	if inventoryAdoptArgs.selector == "" {
+		return fmt.Errorf("you must specify a label selector with --selector")
+	}
+
+	selector, err := labels.Parse(inventoryAdoptArgs.selector)
+	if err != nil {
+		return fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	discoveryClient, err := kubeconfigArgs.ToDiscoveryClient()
+	if err != nil {
+		return fmt.Errorf("discovery client init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(inventoryAdoptArgs.inventory, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("inventory query failed, error: %w", err)
+	}
+
+	ns := i.Namespace
+	if inventoryAdoptArgs.allNamespaces {
+		ns = ""
+	}
+	objects, err := listObjectsBySelector(ctx, kubeClient, discoveryClient, selector, ns)
+	if err != nil {
+		return err
+	}
+
+	existingObjects, err := i.ListObjects()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(existingObjects))
+	for _, object := range existingObjects {
+		existing[ssa.FmtUnstructured(object)] = true
+	}
+
+	var adopted []*unstructured.Unstructured
+	for _, object := range objects {
+		if existing[ssa.FmtUnstructured(object)] {
+			continue
+		}
+		// The objects generated by controllers are garbage collected with their owner.
+		if metav1.GetControllerOf(object) != nil {
+			logger.Println("skipping", ssa.FmtUnstructured(object), "owned by a controller")
+			continue
+		}
+		if len(inventoryAdoptArgs.fieldManagers) > 0 && !isManagedBy(object, inventoryAdoptArgs.fieldManagers...) {
+			logger.Println("skipping", ssa.FmtUnstructured(object), "not managed by", strings.Join(inventoryAdoptArgs.fieldManagers, ", "))
+			continue
+		}
+		adopted = append(adopted, object)
+	}
+
+	if len(adopted) == 0 {
+		logger.Println("no objects to adopt")
+		return nil
+	}
+
+	if err := i.AddObjects(adopted); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	for _, object := range adopted {
+		rootCmd.Println("-", ssa.FmtUnstructured(object))
+	}
+
+	if inventoryAdoptArgs.dryRun {
+		return nil
+	}
+
+	if inventoryAdoptArgs.relabel {
+		labels := resMgr.GetOwnerLabels(i.Name, i.Namespace)
+		labels[inventory.InventoryLabel] = inventory.LabelValue(i.Name, i.Namespace)
+		for _, object := range adopted {
+			if err := patchLabels(ctx, kubeClient, object, labels); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := invStorage.ApplyInventory(ctx, i, false); err != nil {
+		return fmt.Errorf("inventory apply failed, error: %w", err)
+	}
+
+	logger.Println(fmt.Sprintf("%d object(s) adopted by inventory %s/%s", len(adopted), i.Namespace, i.Name))
+	return nil
+}
+
+// patchLabels sets the given labels on the in-cluster object using a JSON merge patch.
+func patchLabels(ctx context.Context, kubeClient client.Client, object *unstructured.Unstructured, labels map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": labels,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := kubeClient.Patch(ctx, object.DeepCopy(), client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return fmt.Errorf("%s labeling failed, error: %w", ssa.FmtUnstructured(object), err)
+	}
+	return nil
+}
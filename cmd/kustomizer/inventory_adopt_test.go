@@ -0,0 +1,115 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+func TestInventoryAdopt(t *testing.T) {
+	g := NewWithT(t)
+	id := "adopt-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id,
+			Namespace: id,
+			Labels: map[string]string{
+				"app": id,
+			},
+		},
+		Data: map[string]string{
+			"key": "value",
+		},
+	}
+	err = envTestClient.Create(context.Background(), cm)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("fails without a selector", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"inventory adopt -i %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("adopts the matching objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory adopt -i %s -n %s --selector app=%s --relabel",
+			id,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
+
+		clusterCM := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(cm), clusterCM)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(clusterCM.GetLabels()).To(HaveKeyWithValue(inventory.InventoryLabel, inventory.LabelValue(id, id)))
+	})
+
+	t.Run("skips the objects not managed by the given field managers", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory adopt -i %s-other -n %s --selector app=%s --field-manager kubectl --dry-run",
+			id,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("not managed by kubectl"))
+		g.Expect(output).To(ContainSubstring("no objects to adopt"))
+	})
+
+	t.Run("skips the adopted objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory adopt -i %s -n %s --selector app=%s",
+			id,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("no objects to adopt"))
+	})
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Diff compares two revisions of an inventory and prints the Kubernetes resources added, changed or removed to stdout.",
+	Example: `  kustomizer inventory diff -i <name> -n <namespace> --from <number> --to <number>
+
+  # Print the objects added and removed between revisions 4 and 5,
+  # the changed fields are printed when the revisions were applied with --store-manifests
+  kustomizer inventory diff -i my-app -n apps --from 4 --to 5
+
+  # Diff two revisions and write the change set as a Markdown report
+  kustomizer inventory diff -i my-app -n apps --from 4 --to 5 -o markdown > changes.md
+`,
+	RunE: runInventoryDiffCmd,
+}
+
+type inventoryDiffFlags struct {
+	inventory   string
+	from        int
+	to          int
+	output      string
+	diffContext int
+	noColor     bool
+}
+
+var inventoryDiffArgs inventoryDiffFlags
+
+func init() {
+	inventoryDiffCmd.Flags().StringVarP(&inventoryDiffArgs.inventory, "inventory", "i", "",
+		"The name of the inventory.")
+	inventoryDiffCmd.Flags().IntVar(&inventoryDiffArgs.from, "from", 0,
+		"The number of the base revision, as listed by 'kustomizer history'.")
+	inventoryDiffCmd.Flags().IntVar(&inventoryDiffArgs.to, "to", 0,
+		"The number of the revision compared to the base revision, defaults to the latest revision.")
+	inventoryDiffCmd.Flags().StringVarP(&inventoryDiffArgs.output, "output", "o", "",
+		"Write the change set to stdout in JSON or YAML format, or as a Markdown or HTML report.")
+	inventoryDiffCmd.Flags().IntVar(&inventoryDiffArgs.diffContext, "diff-context", 3,
+		"The number of unchanged lines printed around the changed lines in the unified diff.")
+	inventoryDiffCmd.Flags().BoolVar(&inventoryDiffArgs.noColor, "no-color", false,
+		"Print the unified diff without colors, the colors are also disabled when the NO_COLOR environment variable is set.")
+
+	inventoryCmd.AddCommand(inventoryDiffCmd)
+}
+
+func runInventoryDiffCmd(cmd *cobra.Command, args []string) error {
+	if inventoryDiffArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+	if inventoryDiffArgs.from < 1 {
+		return fmt.Errorf("you must specify the base revision number with --from")
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(inventoryDiffArgs.inventory, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	from, err := i.GetRevision(inventoryDiffArgs.from)
+	if err != nil {
+		return err
+	}
+
+	to := i.CurrentRevision()
+	if inventoryDiffArgs.to > 0 {
+		to, err = i.GetRevision(inventoryDiffArgs.to)
+		if err != nil {
+			return err
+		}
+	}
+
+	fromObjects, toObjects, err := revisionObjects(ctx, invStorage, i, from, to)
+	if err != nil {
+		return err
+	}
+
+	printer, err := newChangeSetPrinter(inventoryDiffArgs.output, func(entry changeSetEntry) {
+		rootCmd.Println(`►`, entry.Subject, entry.Action)
+		if entry.Diff != "" {
+			rootCmd.Println(entry.Diff)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	color := !printer.isStructured() && useColor(inventoryDiffArgs.noColor)
+	if err := printObjectsDiff(printer, fromObjects, toObjects, inventoryDiffArgs.diffContext, color); err != nil {
+		return err
+	}
+
+	return printer.Flush()
+}
+
+// revisionObjects returns the objects of the given revisions, read from the stored manifests
+// when both revisions have them or from the inventory entries otherwise.
+func revisionObjects(ctx context.Context, invStorage *inventory.Storage, inv *inventory.Inventory,
+	from, to *inventory.Revision) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	revisions := []*inventory.Revision{from, to}
+	withManifests := from.StoredManifests && to.StoredManifests
+
+	var result [][]*unstructured.Unstructured
+	for _, rev := range revisions {
+		var objects []*unstructured.Unstructured
+		if withManifests {
+			manifests, err := invStorage.LoadManifests(ctx, inv, rev.Number)
+			if err != nil {
+				return nil, nil, err
+			}
+			objects, err = ssa.ReadObjects(strings.NewReader(manifests))
+			if err != nil {
+				return nil, nil, fmt.Errorf("extracting the manifests of revision %d failed: %w", rev.Number, err)
+			}
+		} else {
+			var err error
+			objects, err = rev.ListObjects()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		sort.Sort(ssa.SortableUnstructureds(objects))
+		result = append(result, objects)
+	}
+
+	return result[0], result[1], nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestInventoryDiff(t *testing.T) {
+	g := NewWithT(t)
+	id := "inv-diff-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies three revisions", func(t *testing.T) {
+		for i, version := range []string{"v1", "v2", "v3"} {
+			dir, err := makeTestDir(id, rollbackTestManifests(id, version, i > 0))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			storeManifests := i < 2
+			output, err := executeCommand(fmt.Sprintf(
+				"apply inv %s -n %s -f %s --store-manifests=%t",
+				id,
+				id,
+				dir,
+				storeManifests,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("diffs the stored manifests", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory diff -i %s -n %s --from 1 --to 2 --no-color",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/app configured", id)))
+		g.Expect(output).To(MatchRegexp("version: v2"))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/extra created", id)))
+	})
+
+	t.Run("diffs the object sets", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory diff -i %s -n %s --from 1",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).NotTo(MatchRegexp(fmt.Sprintf("ConfigMap/%s/app", id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/extra created", id)))
+	})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export prints the inventory record, its history and stored manifests in JSON format.",
+	Example: `  kustomizer inventory export -i <name> -n <namespace>
+
+  # Backup an inventory to a file
+  kustomizer inventory export -i my-app -n apps > my-app.json
+`,
+	RunE: runInventoryExportCmd,
+}
+
+type inventoryExportFlags struct {
+	inventory string
+}
+
+var inventoryExportArgs inventoryExportFlags
+
+func init() {
+	inventoryExportCmd.Flags().StringVarP(&inventoryExportArgs.inventory, "inventory", "i", "",
+		"The name of the inventory.")
+
+	inventoryCmd.AddCommand(inventoryExportCmd)
+}
+
+func runInventoryExportCmd(cmd *cobra.Command, args []string) error {
+	if inventoryExportArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(inventoryExportArgs.inventory, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	export := inventory.NewExport(i)
+	for _, rev := range i.History {
+		if !rev.StoredManifests {
+			continue
+		}
+		manifests, err := invStorage.LoadManifests(ctx, i, rev.Number)
+		if err != nil {
+			return err
+		}
+		export.Manifests[rev.Number] = manifests
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	rootCmd.Println(string(data))
+	return nil
+}
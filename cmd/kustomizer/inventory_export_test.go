@@ -0,0 +1,102 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestInventoryExportImport(t *testing.T) {
+	g := NewWithT(t)
+	id := "export-" + randStringRunes(5)
+	importNamespace := id + "-import"
+	exportPath := filepath.Join(tmpDir, id+".json")
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = createNamespace(importNamespace)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --revision v1.0.0 --store-manifests",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("exports the inventory", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory export -i %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(`"apiVersion": "kustomizer.dev/v1"`))
+		g.Expect(output).To(ContainSubstring(`"kind": "InventoryExport"`))
+		g.Expect(output).To(ContainSubstring(`"manifests"`))
+
+		err = os.WriteFile(exportPath, []byte(output), 0644)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("imports the inventory in another namespace", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory import -f %s -n %s",
+			exportPath,
+			importNamespace,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			importNamespace,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("v1.0.0"))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
+	})
+
+	t.Run("fails to overwrite an existing inventory", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"inventory import -f %s -n %s",
+			exportPath,
+			importNamespace,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import recreates an inventory record from a file generated with 'kustomizer inventory export'.",
+	Example: `  kustomizer inventory import -f <path> [-n <namespace>]
+
+  # Restore an inventory in the namespace recorded in the export file
+  kustomizer inventory import -f my-app.json
+
+  # Move an inventory to another namespace
+  kustomizer inventory import -f my-app.json -n apps-v2
+
+  # Overwrite an existing inventory
+  kustomizer inventory import -f my-app.json --force
+`,
+	RunE: runInventoryImportCmd,
+}
+
+type inventoryImportFlags struct {
+	filename string
+	force    bool
+}
+
+var inventoryImportArgs inventoryImportFlags
+
+func init() {
+	inventoryImportCmd.Flags().StringVarP(&inventoryImportArgs.filename, "filename", "f", "",
+		"Path to the inventory export file, use '-' to read from stdin.")
+	inventoryImportCmd.Flags().BoolVar(&inventoryImportArgs.force, "force", false,
+		"Overwrite the inventory if it already exists.")
+
+	inventoryCmd.AddCommand(inventoryImportCmd)
+}
+
+func runInventoryImportCmd(cmd *cobra.Command, args []string) error {
+	if inventoryImportArgs.filename == "" {
+		return fmt.Errorf("you must specify the export file with --filename")
+	}
+
+	var data []byte
+	var err error
+	if inventoryImportArgs.filename == stdinPath {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(inventoryImportArgs.filename)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s failed, error: %w", inventoryImportArgs.filename, err)
+	}
+
+	export, err := inventory.ReadExport(data)
+	if err != nil {
+		return err
+	}
+
+	i := export.Inventory
+	if cmd.Flags().Changed("namespace") {
+		i.Namespace = *kubeconfigArgs.Namespace
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	if !inventoryImportArgs.force {
+		existingInventory := inventory.NewInventory(i.Name, i.Namespace)
+		err := invStorage.GetInventory(ctx, existingInventory)
+		if err == nil {
+			return fmt.Errorf("inventory %s/%s already exists, use --force to overwrite it", i.Namespace, i.Name)
+		}
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("inventory query failed, error: %w", err)
+		}
+	}
+
+	if err := invStorage.ApplyInventory(ctx, i, false); err != nil {
+		return fmt.Errorf("inventory apply failed, error: %w", err)
+	}
+
+	for number, manifests := range export.Manifests {
+		if err := invStorage.StoreManifests(ctx, i, number, manifests); err != nil {
+			return err
+		}
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s imported with %d entries", i.Namespace, i.Name, len(i.Resources)))
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate moves an inventory record, including its history, to another storage backend.",
+	Example: `  kustomizer inventory migrate -i <name> -n <namespace> --inventory-kind <current kind> --to <target kind>
+
+  # Move an inventory from a ConfigMap to an Inventory custom resource
+  kustomizer inventory migrate -i my-app -n apps --to crd
+
+  # Move an inventory from a Secret back to a ConfigMap
+  kustomizer inventory migrate -i my-app -n apps --inventory-kind secret --to configmap
+`,
+	RunE: runInventoryMigrateCmd,
+}
+
+type inventoryMigrateFlags struct {
+	inventory string
+	to        string
+}
+
+var inventoryMigrateArgs inventoryMigrateFlags
+
+func init() {
+	inventoryMigrateCmd.Flags().StringVarP(&inventoryMigrateArgs.inventory, "inventory", "i", "",
+		"The name of the inventory.")
+	inventoryMigrateCmd.Flags().StringVar(&inventoryMigrateArgs.to, "to", "",
+		"The kind of the target storage, can be configmap, secret or inventory (alias crd), the current storage is set with --inventory-kind.")
+
+	inventoryCmd.AddCommand(inventoryMigrateCmd)
+}
+
+func runInventoryMigrateCmd(cmd *cobra.Command, args []string) error {
+	if inventoryMigrateArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+
+	targetKind := strings.ToLower(inventoryMigrateArgs.to)
+	if targetKind == "crd" {
+		targetKind = inventory.CustomResourceStorage
+	}
+	switch targetKind {
+	case inventory.ConfigMapStorage, inventory.SecretStorage, inventory.CustomResourceStorage:
+	case "":
+		return fmt.Errorf("you must specify the target storage kind with --to")
+	default:
+		return fmt.Errorf("unsupported target storage kind '%s', can be %s, %s or %s",
+			inventoryMigrateArgs.to, inventory.ConfigMapStorage, inventory.SecretStorage, inventory.CustomResourceStorage)
+	}
+	if targetKind == strings.ToLower(rootArgs.inventoryKind) {
+		return fmt.Errorf("the inventory is already stored in a %s, use --inventory-kind to set the current storage kind", targetKind)
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	targetStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    targetKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(inventoryMigrateArgs.inventory, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	err = targetStorage.GetInventory(ctx, inventory.NewInventory(i.Name, i.Namespace))
+	if err == nil {
+		return fmt.Errorf("inventory %s/%s already exists in the %s storage", i.Namespace, i.Name, targetKind)
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("inventory query failed, error: %w", err)
+	}
+
+	if err := invStorage.MigrateInventory(ctx, i, targetStorage); err != nil {
+		return err
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s migrated from %s to %s, use '--inventory-kind %s' from now on",
+		i.Namespace, i.Name, rootArgs.inventoryKind, targetKind, targetKind))
+	return nil
+}
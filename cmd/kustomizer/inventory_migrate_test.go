@@ -0,0 +1,97 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestInventoryMigrate(t *testing.T) {
+	g := NewWithT(t)
+	id := "migrate-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates two revisions", func(t *testing.T) {
+		for _, revision := range []string{"v1.0.0", "v2.0.0"} {
+			output, err := executeCommand(fmt.Sprintf(
+				"apply inv %s -k %s -n %s --revision %s",
+				id,
+				dir,
+				id,
+				revision,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("migrates the inventory to the custom resource storage", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory migrate -i %s -n %s --to crd",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("preserves the history", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"history -i %s -n %s --inventory-kind inventory",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("v1.0.0"))
+		g.Expect(output).To(MatchRegexp("v2.0.0"))
+	})
+
+	t.Run("fails to migrate to the current storage", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"inventory migrate -i %s -n %s --inventory-kind inventory --to inventory",
+			id,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+}
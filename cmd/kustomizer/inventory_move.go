@@ -0,0 +1,173 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryMoveCmd = &cobra.Command{
+	Use:   "move",
+	Short: "Move transfers the ownership of the matching objects from one inventory to another, without applying or deleting them.",
+	Example: `  kustomizer inventory move --from <name> --to <name> -n <namespace> --include <filter>
+
+  # Move a shared Service from the app-a inventory to app-b
+  kustomizer inventory move --from app-a --to app-b -n apps --include kind=Service,name=shared-svc
+
+  # Move all the ConfigMaps and print the entries without changing the inventories
+  kustomizer inventory move --from app-a --to app-b -n apps --include kind=ConfigMap --dry-run
+`,
+	RunE: runInventoryMoveCmd,
+}
+
+type inventoryMoveFlags struct {
+	from    string
+	to      string
+	include []string
+	exclude []string
+	dryRun  bool
+}
+
+var inventoryMoveArgs inventoryMoveFlags
+
+func init() {
+	inventoryMoveCmd.Flags().StringVar(&inventoryMoveArgs.from, "from", "",
+		"The name of the inventory that owns the objects.")
+	inventoryMoveCmd.Flags().StringVar(&inventoryMoveArgs.to, "to", "",
+		"The name of the inventory that takes over the objects, the inventory is created if it doesn't exist.")
+	inventoryMoveCmd.Flags().StringArrayVar(&inventoryMoveArgs.include, "include", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', only the matching entries are moved. The values can contain shell patterns e.g. 'name=web*'.")
+	inventoryMoveCmd.Flags().StringArrayVar(&inventoryMoveArgs.exclude, "exclude", nil,
+		"Filter in the format 'kind=<kind>,name=<name>,namespace=<namespace>', the matching entries are not moved.")
+	inventoryMoveCmd.Flags().BoolVar(&inventoryMoveArgs.dryRun, "dry-run", false,
+		"Print the entries that would be moved without changing the inventories.")
+
+	inventoryCmd.AddCommand(inventoryMoveCmd)
+}
+
+func runInventoryMoveCmd(cmd *cobra.Command, args []string) error {
+	if inventoryMoveArgs.from == "" || inventoryMoveArgs.to == "" {
+		return fmt.Errorf("you must specify the source and target inventories with --from and --to")
+	}
+	if inventoryMoveArgs.from == inventoryMoveArgs.to {
+		return fmt.Errorf("the source and target inventories must be different")
+	}
+	if len(inventoryMoveArgs.include) == 0 {
+		return fmt.Errorf("you must specify the entries to move with --include")
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	source := inventory.NewInventory(inventoryMoveArgs.from, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, source); err != nil {
+		return fmt.Errorf("inventory %s/%s query failed, error: %w", source.Namespace, source.Name, err)
+	}
+
+	target := inventory.NewInventory(inventoryMoveArgs.to, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, target); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("inventory %s/%s query failed, error: %w", target.Namespace, target.Name, err)
+	}
+
+	sourceObjects, err := source.ListObjects()
+	if err != nil {
+		return err
+	}
+
+	objects, err := filterObjects(sourceObjects, inventoryMoveArgs.include, inventoryMoveArgs.exclude)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("no entries in inventory %s/%s match the filters", source.Namespace, source.Name)
+	}
+
+	targetObjects, err := target.ListObjects()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(targetObjects))
+	for _, object := range targetObjects {
+		existing[ssa.FmtUnstructured(object)] = true
+	}
+
+	var added []*unstructured.Unstructured
+	for _, object := range objects {
+		rootCmd.Println("-", ssa.FmtUnstructured(object))
+		if !existing[ssa.FmtUnstructured(object)] {
+			added = append(added, object)
+		}
+	}
+
+	if inventoryMoveArgs.dryRun {
+		return nil
+	}
+
+	labels := resMgr.GetOwnerLabels(target.Name, target.Namespace)
+	labels[inventory.InventoryLabel] = inventory.LabelValue(target.Name, target.Namespace)
+	for _, object := range objects {
+		if err := patchLabels(ctx, kubeClient, object, labels); err != nil {
+			if apierrors.IsNotFound(err) {
+				logger.Println(ssa.FmtUnstructured(object), "not found, skipping relabeling")
+				continue
+			}
+			return err
+		}
+	}
+
+	// The target is updated first so that the moved objects are always tracked by at least one inventory.
+	if err := target.AddObjects(added); err != nil {
+		return fmt.Errorf("updating inventory failed, error: %w", err)
+	}
+	if err := invStorage.ApplyInventory(ctx, target, false); err != nil {
+		return fmt.Errorf("inventory %s/%s apply failed, error: %w", target.Namespace, target.Name, err)
+	}
+
+	source.RemoveObjects(objects)
+	if err := invStorage.ApplyInventory(ctx, source, false); err != nil {
+		return fmt.Errorf("inventory %s/%s apply failed, error: %w", source.Namespace, source.Name, err)
+	}
+
+	logger.Println(fmt.Sprintf("%d object(s) moved from inventory %s to %s", len(objects), source.ID(), target.ID()))
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+func TestInventoryMove(t *testing.T) {
+	g := NewWithT(t)
+	id := "move-" + randStringRunes(5)
+	target := id + "-target"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("fails without filters", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"inventory move --from %s --to %s -n %s",
+			id,
+			target,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("moves the matching entries", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory move --from %s --to %s -n %s --include kind=ConfigMap,name=%s",
+			id,
+			target,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			target,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
+		g.Expect(output).ToNot(MatchRegexp(fmt.Sprintf("Secret/%s/%s", id, id)))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).ToNot(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%s/%s", id, id)))
+
+		clusterCM := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, clusterCM)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(clusterCM.GetLabels()).To(HaveKeyWithValue(inventory.InventoryLabel, inventory.LabelValue(target, id)))
+	})
+}
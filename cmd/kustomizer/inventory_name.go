@@ -0,0 +1,115 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// deriveInventoryName returns a stable inventory name and the source identity it was derived from,
+// based on the OCI repository, the Git repository or the local path specified with the apply flags.
+func deriveInventoryName() (string, string, error) {
+	var name, identity string
+	switch {
+	case len(applyInventoryArgs.artifact) > 0:
+		repo, err := registry.ParseRepositoryURL(applyInventoryArgs.artifact[0])
+		if err != nil {
+			return "", "", err
+		}
+		identity = registry.URLPrefix + repo
+		name = repo[strings.LastIndex(repo, "/")+1:]
+	case applyInventoryArgs.gitURL != "":
+		identity = strings.TrimSuffix(applyInventoryArgs.gitURL, "/")
+		name = strings.TrimSuffix(identity[strings.LastIndex(identity, "/")+1:], ".git")
+		if applyInventoryArgs.gitPath != "" {
+			identity = fmt.Sprintf("%s//%s", identity, filepath.ToSlash(filepath.Clean(applyInventoryArgs.gitPath)))
+			name = fmt.Sprintf("%s-%s", name, filepath.Base(applyInventoryArgs.gitPath))
+		}
+	case applyInventoryArgs.kustomize != "":
+		path, err := filepath.Abs(applyInventoryArgs.kustomize)
+		if err != nil {
+			return "", "", err
+		}
+		identity = path
+		name = filepath.Base(path)
+	case len(applyInventoryArgs.filename) > 0 && applyInventoryArgs.filename[0] != stdinPath:
+		path, err := filepath.Abs(applyInventoryArgs.filename[0])
+		if err != nil {
+			return "", "", err
+		}
+		identity = path
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	default:
+		return "", "", fmt.Errorf("the inventory name can't be derived from stdin or remote manifests, you must specify it")
+	}
+
+	name = sanitizeInventoryName(name)
+	if name == "" {
+		return "", "", fmt.Errorf("the inventory name can't be derived from '%s', you must specify it", identity)
+	}
+	return name, identity, nil
+}
+
+// sanitizeInventoryName converts the given value to a DNS-1123 label.
+func sanitizeInventoryName(value string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(value), "-")
+	if len(name) > validation.DNS1123LabelMaxLength {
+		name = name[:validation.DNS1123LabelMaxLength]
+	}
+	return strings.Trim(name, "-")
+}
+
+// checkDerivedInventoryName rejects the derived inventory name if it's already used
+// by an inventory applied from a different source or with an explicit name.
+func checkDerivedInventoryName(ctx context.Context, resMgr *ssa.ResourceManager, inv *inventory.Inventory) error {
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	existingInventory := inventory.NewInventory(inv.Name, inv.Namespace)
+	if err := invStorage.GetInventory(ctx, existingInventory); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("inventory query failed, error: %w", err)
+	}
+
+	if existingInventory.DerivedFrom != inv.DerivedFrom {
+		owner := existingInventory.DerivedFrom
+		if owner == "" {
+			owner = "an explicitly named apply"
+		}
+		return fmt.Errorf("the derived inventory name %s/%s is already used by %s, you must specify the inventory name",
+			inv.Namespace, inv.Name, owner)
+	}
+	return nil
+}
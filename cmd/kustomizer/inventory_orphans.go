@@ -0,0 +1,229 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryOrphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "Orphans lists the objects labeled by kustomizer that are not recorded in their inventory, or whose inventory no longer exists.",
+	Example: `  kustomizer inventory orphans -n <namespace>
+
+  # List the orphaned objects in the apps namespace
+  kustomizer inventory orphans -n apps
+
+  # List the orphaned objects across all namespaces
+  kustomizer inventory orphans --all-namespaces
+
+  # Delete the orphaned objects left behind by an interrupted apply
+  kustomizer inventory orphans -n apps --delete
+
+  # List the orphaned objects across all namespaces in JSON format
+  kustomizer inventory orphans --all-namespaces -o json
+`,
+	RunE: runInventoryOrphansCmd,
+}
+
+type inventoryOrphansFlags struct {
+	allNamespaces bool
+	delete        bool
+	output        string
+}
+
+var inventoryOrphansArgs inventoryOrphansFlags
+
+func init() {
+	inventoryOrphansCmd.Flags().BoolVar(&inventoryOrphansArgs.allNamespaces, "all-namespaces", false,
+		"Look up the namespaced objects across all namespaces.")
+	inventoryOrphansCmd.Flags().BoolVar(&inventoryOrphansArgs.delete, "delete", false,
+		"Delete the orphaned objects from the cluster.")
+	inventoryOrphansCmd.Flags().StringVarP(&inventoryOrphansArgs.output, "output", "o", "",
+		"Print the orphaned objects in JSON or YAML format.")
+
+	inventoryCmd.AddCommand(inventoryOrphansCmd)
+}
+
+// orphanedObject holds an object labeled by kustomizer that is not tracked by any inventory.
+type orphanedObject struct {
+	object    *unstructured.Unstructured
+	inventory string
+	reason    string
+}
+
+func runInventoryOrphansCmd(cmd *cobra.Command, args []string) error {
+	if err := validateOutput(inventoryOrphansArgs.output); err != nil {
+		return err
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	discoveryClient, err := kubeconfigArgs.ToDiscoveryClient()
+	if err != nil {
+		return fmt.Errorf("discovery client init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	// The inventories are listed across all namespaces as an inventory can own objects outside its namespace.
+	inventories, err := invStorage.ListInventories(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing inventories failed, error: %w", err)
+	}
+
+	tracked := make(map[string]map[string]bool, len(inventories))
+	for _, inv := range inventories {
+		objects, err := inv.ListObjects()
+		if err != nil {
+			return err
+		}
+		ids := make(map[string]bool, len(objects))
+		for _, object := range objects {
+			ids[ssa.FmtUnstructured(object)] = true
+		}
+		tracked[inventory.LabelValue(inv.Name, inv.Namespace)] = ids
+	}
+
+	requirement, err := labels.NewRequirement(inventory.InventoryLabel, selection.Exists, nil)
+	if err != nil {
+		return err
+	}
+
+	ns := *kubeconfigArgs.Namespace
+	if inventoryOrphansArgs.allNamespaces {
+		ns = ""
+	}
+	objects, err := listObjectsBySelector(ctx, kubeClient, discoveryClient, labels.NewSelector().Add(*requirement), ns)
+	if err != nil {
+		return err
+	}
+
+	orphans := findOrphanedObjects(objects, tracked, inventoryOwner.Field)
+
+	if isStructuredOutput(inventoryOrphansArgs.output) {
+		outputs := make([]orphanOutput, 0, len(orphans))
+		for _, orphan := range orphans {
+			outputs = append(outputs, orphanOutput{
+				Subject:   ssa.FmtUnstructured(orphan.object),
+				Inventory: orphan.inventory,
+				Reason:    orphan.reason,
+			})
+		}
+		if err := printOutput(inventoryOrphansArgs.output, outputs); err != nil {
+			return err
+		}
+	}
+
+	if len(orphans) == 0 {
+		logger.Println("no orphaned objects found")
+		return nil
+	}
+
+	if !inventoryOrphansArgs.delete {
+		if !isStructuredOutput(inventoryOrphansArgs.output) {
+			var rows [][]string
+			for _, orphan := range orphans {
+				rows = append(rows, []string{ssa.FmtUnstructured(orphan.object), orphan.inventory, orphan.reason})
+			}
+			printTable(rootCmd.OutOrStdout(), []string{"object", "inventory", "reason"}, rows)
+		}
+		return nil
+	}
+
+	staleObjects := make([]*unstructured.Unstructured, 0, len(orphans))
+	for _, orphan := range orphans {
+		staleObjects = append(staleObjects, orphan.object)
+	}
+
+	deleteOpts := ssa.DefaultDeleteOptions()
+	deleteOpts.Exclusions = reconcileExclusions()
+	changeSet, err := resMgr.DeleteAll(ctx, staleObjects, deleteOpts)
+	if err != nil {
+		return fmt.Errorf("deleting orphaned objects failed, error: %w", err)
+	}
+	for _, change := range changeSet.Entries {
+		logger.Println(change.String())
+	}
+
+	return nil
+}
+
+// findOrphanedObjects returns the objects whose inventory label doesn't match any inventory,
+// or whose inventory doesn't list them. Only the objects managed by the given field manager
+// are considered, as the label can be copied by controllers to the objects they generate.
+func findOrphanedObjects(objects []*unstructured.Unstructured, tracked map[string]map[string]bool,
+	fieldManager string) []orphanedObject {
+	var orphans []orphanedObject
+	for _, object := range objects {
+		if !isManagedBy(object, fieldManager) {
+			continue
+		}
+		value := object.GetLabels()[inventory.InventoryLabel]
+		ids, ok := tracked[value]
+		switch {
+		case !ok:
+			orphans = append(orphans, orphanedObject{object: object, inventory: value, reason: "inventory not found"})
+		case !ids[ssa.FmtUnstructured(object)]:
+			orphans = append(orphans, orphanedObject{object: object, inventory: value, reason: "not in inventory"})
+		}
+	}
+	return orphans
+}
+
+// isManagedBy returns true if the object has no controller owner reference
+// and its managed fields contain an entry for one of the given field managers.
+func isManagedBy(object *unstructured.Unstructured, fieldManagers ...string) bool {
+	if metav1.GetControllerOf(object) != nil {
+		return false
+	}
+	for _, entry := range object.GetManagedFields() {
+		for _, fieldManager := range fieldManagers {
+			if entry.Manager == fieldManager {
+				return true
+			}
+		}
+	}
+	return false
+}
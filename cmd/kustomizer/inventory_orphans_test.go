@@ -0,0 +1,110 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+func TestInventoryOrphans(t *testing.T) {
+	g := NewWithT(t)
+	id := "orphans-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	orphan := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id + "-orphan",
+			Namespace: id,
+			Labels: map[string]string{
+				inventory.InventoryLabel: inventory.LabelValue("missing", id),
+			},
+		},
+	}
+
+	generated := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id + "-generated",
+			Namespace: id,
+			Labels: map[string]string{
+				inventory.InventoryLabel: inventory.LabelValue("missing", id),
+			},
+		},
+	}
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Create(context.Background(), orphan, client.FieldOwner(inventoryOwner.Field))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		err = envTestClient.Create(context.Background(), generated, client.FieldOwner("some-controller"))
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("reports the orphaned objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory orphans -n %s",
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-orphan", id, id)))
+		g.Expect(output).To(ContainSubstring("inventory not found"))
+		g.Expect(output).ToNot(ContainSubstring(fmt.Sprintf("Secret/%s/%s", id, id)))
+		g.Expect(output).ToNot(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-generated", id, id)))
+	})
+
+	t.Run("deletes the orphaned objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory orphans -n %s --delete",
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(orphan), &corev1.ConfigMap{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(generated), &corev1.ConfigMap{})
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+}
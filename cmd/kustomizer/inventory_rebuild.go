@@ -0,0 +1,220 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild recreates an inventory record from the cluster objects labeled with the inventory name and namespace.",
+	Example: `  kustomizer inventory rebuild -i <name> -n <namespace>
+
+  # Recreate a deleted inventory from the objects labeled with 'kustomizer.dev/inventory=apps_my-app'
+  kustomizer inventory rebuild -i my-app -n apps
+
+  # Print the objects that would be recorded without writing the inventory
+  kustomizer inventory rebuild -i my-app -n apps --dry-run
+
+  # Overwrite an existing inventory with the objects found on the cluster
+  kustomizer inventory rebuild -i my-app -n apps --force
+`,
+	RunE: runInventoryRebuildCmd,
+}
+
+type inventoryRebuildFlags struct {
+	inventory string
+	dryRun    bool
+	force     bool
+}
+
+var inventoryRebuildArgs inventoryRebuildFlags
+
+func init() {
+	inventoryRebuildCmd.Flags().StringVarP(&inventoryRebuildArgs.inventory, "inventory", "i", "",
+		"The name of the inventory.")
+	inventoryRebuildCmd.Flags().BoolVar(&inventoryRebuildArgs.dryRun, "dry-run", false,
+		"Print the objects found on the cluster without writing the inventory.")
+	inventoryRebuildCmd.Flags().BoolVar(&inventoryRebuildArgs.force, "force", false,
+		"Overwrite the inventory if it already exists.")
+
+	inventoryCmd.AddCommand(inventoryRebuildCmd)
+}
+
+func runInventoryRebuildCmd(cmd *cobra.Command, args []string) error {
+	if inventoryRebuildArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	discoveryClient, err := kubeconfigArgs.ToDiscoveryClient()
+	if err != nil {
+		return fmt.Errorf("discovery client init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(inventoryRebuildArgs.inventory, *kubeconfigArgs.Namespace)
+
+	if !inventoryRebuildArgs.force && !inventoryRebuildArgs.dryRun {
+		err := invStorage.GetInventory(ctx, inventory.NewInventory(i.Name, i.Namespace))
+		if err == nil {
+			return fmt.Errorf("inventory %s/%s already exists, use --force to overwrite it", i.Namespace, i.Name)
+		}
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("inventory query failed, error: %w", err)
+		}
+	}
+
+	logger.Println("discovering the objects labeled with", fmt.Sprintf("%s=%s", inventory.InventoryLabel, inventory.LabelValue(i.Name, i.Namespace)))
+	selector := labels.SelectorFromSet(labels.Set{inventory.InventoryLabel: inventory.LabelValue(i.Name, i.Namespace)})
+	labeledObjects, err := listObjectsBySelector(ctx, kubeClient, discoveryClient, selector, "")
+	if err != nil {
+		return err
+	}
+
+	// The inventory label can be copied by controllers to the objects they generate,
+	// only the objects applied by kustomizer are recorded.
+	var objects []*unstructured.Unstructured
+	for _, object := range labeledObjects {
+		if !isManagedBy(object, inventoryOwner.Field) {
+			logger.Println("skipping", ssa.FmtUnstructured(object), "not managed by", inventoryOwner.Field)
+			continue
+		}
+		objects = append(objects, object)
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("no objects found for inventory %s/%s", i.Namespace, i.Name)
+	}
+
+	if err := i.AddObjects(objects); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	for _, object := range objects {
+		rootCmd.Println("-", ssa.FmtUnstructured(object))
+	}
+
+	if inventoryRebuildArgs.dryRun {
+		return nil
+	}
+
+	if err := invStorage.ApplyInventory(ctx, i, false); err != nil {
+		return fmt.Errorf("inventory apply failed, error: %w", err)
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s rebuilt with %d entries", i.Namespace, i.Name, len(i.Resources)))
+	return nil
+}
+
+// listObjectsBySelector returns the name, namespace, labels, owner references and managed fields of the objects that match the given label selector
+// from all the API resources that can be listed. The namespaced objects are listed in the given namespace,
+// or in all namespaces when the namespace is empty, the cluster-scoped objects are always listed.
+func listObjectsBySelector(ctx context.Context, kubeClient client.Client, discoveryClient discovery.DiscoveryInterface,
+	selector labels.Selector, namespace string) ([]*unstructured.Unstructured, error) {
+	resourceLists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && len(resourceLists) == 0 {
+		return nil, fmt.Errorf("discovering the API resources failed: %w", err)
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") || !hasVerb(resource.Verbs, "list") {
+				continue
+			}
+
+			opts := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+			if resource.Namespaced && namespace != "" {
+				opts = append(opts, client.InNamespace(namespace))
+			}
+
+			list := &metav1.PartialObjectMetadataList{}
+			list.SetGroupVersionKind(gv.WithKind(resource.Kind + "List"))
+			if err := kubeClient.List(ctx, list, opts...); err != nil {
+				if apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+					continue
+				}
+				if apierrors.IsForbidden(err) {
+					logger.Println("skipping", resource.Name, "in", gv.String(), "due to insufficient permissions")
+					continue
+				}
+				return nil, fmt.Errorf("listing %s failed: %w", resource.Name, err)
+			}
+
+			for _, item := range list.Items {
+				object := &unstructured.Unstructured{}
+				object.SetGroupVersionKind(gv.WithKind(resource.Kind))
+				object.SetName(item.GetName())
+				object.SetNamespace(item.GetNamespace())
+				object.SetLabels(item.GetLabels())
+				object.SetOwnerReferences(item.GetOwnerReferences())
+				object.SetManagedFields(item.GetManagedFields())
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+func hasVerb(verbs metav1.Verbs, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
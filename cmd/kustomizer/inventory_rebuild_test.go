@@ -0,0 +1,106 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+func TestInventoryRebuild(t *testing.T) {
+	g := NewWithT(t)
+	id := "rebuild-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("fails if the inventory exists", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"inventory rebuild -i %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("rebuilds a deleted inventory", func(t *testing.T) {
+		err := envTestClient.Delete(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		generated := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id + "-generated",
+				Namespace: id,
+				Labels: map[string]string{
+					inventory.InventoryLabel: inventory.LabelValue(id, id),
+				},
+			},
+		}
+		err = envTestClient.Create(context.Background(), generated, client.FieldOwner("some-controller"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory rebuild -i %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%s/%s", id, id)))
+		g.Expect(output).ToNot(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-generated", id, id)))
+	})
+}
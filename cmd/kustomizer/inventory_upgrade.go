@@ -0,0 +1,174 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var inventoryUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade rewrites the inventories recorded by older kustomizer versions in the current storage format.",
+	Long: `The upgrade command records the namespace scope of the legacy inventories, sets the storage format version
+and relabels their objects with the '<namespace>_<name>' inventory label value.
+The legacy inventories are also upgraded transparently the next time they are applied.`,
+	Example: `  kustomizer inventory upgrade -n <namespace>
+
+  # Upgrade the legacy inventories in the apps namespace
+  kustomizer inventory upgrade -n apps
+
+  # Upgrade a single inventory
+  kustomizer inventory upgrade -i my-app -n apps
+
+  # List the legacy inventories across all namespaces without upgrading them
+  kustomizer inventory upgrade --all-namespaces --dry-run
+`,
+	RunE: runInventoryUpgradeCmd,
+}
+
+type inventoryUpgradeFlags struct {
+	inventory     string
+	allNamespaces bool
+	dryRun        bool
+}
+
+var inventoryUpgradeArgs inventoryUpgradeFlags
+
+func init() {
+	inventoryUpgradeCmd.Flags().StringVarP(&inventoryUpgradeArgs.inventory, "inventory", "i", "",
+		"The name of the inventory, when not specified all the inventories in the namespace are upgraded.")
+	inventoryUpgradeCmd.Flags().BoolVar(&inventoryUpgradeArgs.allNamespaces, "all-namespaces", false,
+		"Upgrade the legacy inventories across all namespaces.")
+	inventoryUpgradeCmd.Flags().BoolVar(&inventoryUpgradeArgs.dryRun, "dry-run", false,
+		"Print the legacy inventories without upgrading them.")
+
+	inventoryCmd.AddCommand(inventoryUpgradeCmd)
+}
+
+func runInventoryUpgradeCmd(cmd *cobra.Command, args []string) error {
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	var inventories []*inventory.Inventory
+	if inventoryUpgradeArgs.inventory != "" {
+		i := inventory.NewInventory(inventoryUpgradeArgs.inventory, *kubeconfigArgs.Namespace)
+		if err := invStorage.GetInventory(ctx, i); err != nil {
+			return fmt.Errorf("inventory query failed, error: %w", err)
+		}
+		inventories = append(inventories, i)
+	} else {
+		ns := *kubeconfigArgs.Namespace
+		if inventoryUpgradeArgs.allNamespaces {
+			ns = ""
+		}
+		inventories, err = invStorage.ListInventories(ctx, ns)
+		if err != nil {
+			return fmt.Errorf("listing inventories failed, error: %w", err)
+		}
+	}
+
+	upgraded := 0
+	for _, i := range inventories {
+		if !i.IsLegacy() {
+			continue
+		}
+		upgraded++
+
+		if inventoryUpgradeArgs.dryRun {
+			rootCmd.Println(fmt.Sprintf("%s format %d", i.ID(), i.Format))
+			continue
+		}
+
+		if err := relabelLegacyObjects(ctx, kubeClient, i); err != nil {
+			return err
+		}
+
+		format := i.Format
+		if err := invStorage.ApplyInventory(ctx, i, false); err != nil {
+			return fmt.Errorf("inventory %s apply failed, error: %w", i.ID(), err)
+		}
+		logger.Println(fmt.Sprintf("inventory %s upgraded from format %d to %d", i.ID(), format, inventory.CurrentFormat))
+	}
+
+	if upgraded == 0 {
+		logger.Println("no legacy inventories found")
+	}
+	return nil
+}
+
+// relabelLegacyObjects sets the inventory label to the current value on the in-cluster objects
+// of the given inventory that are labeled with a different value.
+func relabelLegacyObjects(ctx context.Context, kubeClient client.Client, i *inventory.Inventory) error {
+	objects, err := i.ListObjects()
+	if err != nil {
+		return err
+	}
+
+	value := inventory.LabelValue(i.Name, i.Namespace)
+	for _, object := range objects {
+		existingObject := &metav1.PartialObjectMetadata{}
+		existingObject.SetGroupVersionKind(object.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(object), err)
+		}
+
+		current, ok := existingObject.GetLabels()[inventory.InventoryLabel]
+		if !ok || current == value {
+			continue
+		}
+
+		labeled := &unstructured.Unstructured{}
+		labeled.SetGroupVersionKind(object.GroupVersionKind())
+		labeled.SetName(object.GetName())
+		labeled.SetNamespace(object.GetNamespace())
+		if err := patchLabels(ctx, kubeClient, labeled, map[string]string{inventory.InventoryLabel: value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
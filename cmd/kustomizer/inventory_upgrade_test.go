@@ -0,0 +1,95 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+func TestInventoryUpgrade(t *testing.T) {
+	g := NewWithT(t)
+	id := "upgrade-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("upgrades legacy inventory", func(t *testing.T) {
+		invKey := client.ObjectKey{Name: "inv-" + id, Namespace: id}
+		inv := &corev1.ConfigMap{}
+		err := envTestClient.Get(context.Background(), invKey, inv)
+		g.Expect(err).NotTo(HaveOccurred())
+		annotations := inv.GetAnnotations()
+		delete(annotations, inventoryOwner.Group+"/format")
+		delete(annotations, inventoryOwner.Group+"/namespaces")
+		inv.SetAnnotations(annotations)
+		err = envTestClient.Update(context.Background(), inv)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cm := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		cm.Labels[inventory.InventoryLabel] = id
+		err = envTestClient.Update(context.Background(), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"inventory upgrade -n %s --dry-run",
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("format %d", inventory.LegacyFormat)))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inventory upgrade -n %s",
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), invKey, inv)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(inv.GetAnnotations()[inventoryOwner.Group+"/format"]).To(Equal(fmt.Sprint(inventory.CurrentFormat)))
+		g.Expect(inv.GetAnnotations()).To(HaveKey(inventoryOwner.Group + "/namespaces"))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(cm.GetLabels()[inventory.InventoryLabel]).To(Equal(inventory.LabelValue(id, id)))
+	})
+}
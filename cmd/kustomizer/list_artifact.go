@@ -32,12 +32,17 @@ var listArtifactCmd = &cobra.Command{
 	Use:     "artifact",
 	Aliases: []string{"artifacts"},
 	Short:   "List the versions of an OCI artifact.",
-	Long: `The list command fetches the tags of the specified OCI artifact from its image repository.
-If a semantic version condition is specified, the tags are filtered and ordered by semver.
-For private registries, the list command uses the credentials from '~/.docker/config.json'.`,
+	Long: `The list command fetches the tags of the specified OCI artifact from its image repository
+and prints the digest and the creation timestamp of each version.
+The tags are ordered by semver when possible, the tags that are not semantic versions are listed last.
+If a semantic version condition is specified, the tags are filtered by semver.
+For private registries, the list command uses the credentials from '~/.docker/config.json', or the credentials specified with '--creds' or '--registry-token'.`,
 	Example: `  kustomizer list artifacts <oci repository url> --semver <condition>
 
-  # List all versions ordered by semver
+  # List all versions with their digest and creation timestamp
+  kustomizer list artifacts oci://docker.io/user/repo
+
+  # List all semver versions excluding prerelease
   kustomizer list artifacts oci://docker.io/user/repo --semver="*"
 
   # List all versions including prerelease ordered by semver
@@ -77,50 +82,82 @@ func runListArtifactCmd(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	tags, err := registry.List(ctx, url)
+	tags, err := registry.List(ctx, registryOpts, url)
 	if err != nil {
 		return fmt.Errorf("pulling %s failed: %w", url, err)
 	}
 
-	var rows [][]string
-
+	var c *semver.Constraints
 	if exp := listArtifactArgs.semverExp; exp != "" {
-		c, err := semver.NewConstraint(exp)
+		c, err = semver.NewConstraint(exp)
 		if err != nil {
 			return fmt.Errorf("semver '%s' parse error: %w", exp, err)
 		}
+	}
 
-		var matchingVersions []*semver.Version
-		for _, t := range tags {
-			v, err := semver.NewVersion(t)
-			if err != nil {
-				continue
-			}
+	var versions []*semver.Version
+	var others []string
+	for _, tag := range tags {
+		// exclude cosign signatures and referrers indexes
+		if strings.HasSuffix(tag, ".sig") || strings.HasPrefix(tag, "sha256-") {
+			continue
+		}
 
-			if c != nil && !c.Check(v) {
-				continue
+		v, err := semver.NewVersion(tag)
+		if err != nil {
+			if c == nil {
+				others = append(others, tag)
 			}
+			continue
+		}
 
-			matchingVersions = append(matchingVersions, v)
+		if c != nil && !c.Check(v) {
+			continue
 		}
 
-		sort.Sort(sort.Reverse(semver.Collection(matchingVersions)))
+		versions = append(versions, v)
+	}
+
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
 
-		for _, ver := range matchingVersions {
-			row := []string{ver.String(), fmt.Sprintf("%s:%s", url, ver.Original())}
-			rows = append(rows, row)
+	var rows [][]string
+	for _, ver := range versions {
+		row, err := listArtifactRow(ctx, url, ver.Original(), ver.String())
+		if err != nil {
+			return err
 		}
-	} else {
-		for _, tag := range tags {
-			// exclude cosign signatures
-			if !strings.HasSuffix(tag, ".sig") {
-				row := []string{tag, fmt.Sprintf("%s:%s", url, tag)}
-				rows = append(rows, row)
-			}
+		rows = append(rows, row)
+	}
+	for _, tag := range others {
+		row, err := listArtifactRow(ctx, url, tag, tag)
+		if err != nil {
+			return err
 		}
+		rows = append(rows, row)
 	}
 
-	printTable(rootCmd.OutOrStdout(), []string{"version", "url"}, rows)
+	printTable(rootCmd.OutOrStdout(), []string{"version", "digest", "created", "url"}, rows)
 
 	return nil
 }
+
+// listArtifactRow fetches the manifest of the tagged artifact and returns its version, digest, creation timestamp and URL.
+func listArtifactRow(ctx context.Context, url, tag, version string) ([]string, error) {
+	tagURL := fmt.Sprintf("%s:%s", url, tag)
+	manifest, err := registry.GetManifest(ctx, registryOpts, tagURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching manifest %s failed: %w", tagURL, err)
+	}
+
+	digest := manifest.Digest
+	if i := strings.LastIndex(digest, "@"); i >= 0 {
+		digest = digest[i+1:]
+	}
+
+	created := manifest.Annotations[registry.CreatedAnnotation]
+	if created == "" {
+		created = "-"
+	}
+
+	return []string{version, digest, created, tagURL}, nil
+}
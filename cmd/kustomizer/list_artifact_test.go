@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -66,6 +67,8 @@ func TestListArtifact(t *testing.T) {
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp(ver1))
 		g.Expect(output).To(MatchRegexp(ver2))
+		g.Expect(output).To(MatchRegexp("sha256:"))
+		g.Expect(strings.Index(output, ver2)).To(BeNumerically("<", strings.Index(output, ver1)))
 	})
 
 	t.Run("lists prereleases", func(t *testing.T) {
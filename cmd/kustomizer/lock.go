@@ -0,0 +1,149 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock resolves the OCI artifacts references to immutable digests.",
+	Long: `The lock command resolves the tags and semver ranges of the specified OCI artifacts to digests
+and writes them to a lock file. The apply and pull commands use the locked digests for the artifacts
+found in the lock file, which guarantees that the same artifacts are deployed even if the tags are re-pushed.`,
+	Example: `  kustomizer lock -a <oci url> [-a <oci url>] [-o <lock file path>]
+
+  # Lock the artifacts and commit the lock file
+  kustomizer lock -a oci://ghcr.io/user/app:v1.0.0 -a oci://ghcr.io/user/infra:^2.0
+  git add kustomizer.lock && git commit -m "lock artifacts"
+
+  # Apply the locked digests, the artifacts not found in the lock file are resolved from the registry
+  kustomizer apply inventory my-app -n apps -a oci://ghcr.io/user/app:v1.0.0 -a oci://ghcr.io/user/infra:^2.0
+
+  # Pull the locked digest
+  kustomizer pull artifact oci://ghcr.io/user/app:v1.0.0 --lock-file ./kustomizer.lock
+`,
+	RunE: runLockCmd,
+}
+
+type lockFlags struct {
+	artifacts []string
+	output    string
+}
+
+var lockArgs lockFlags
+
+func init() {
+	lockCmd.Flags().StringSliceVarP(&lockArgs.artifacts, "artifact", "a", nil,
+		"OCI artifact to lock in the format 'oci://<image-url>:<tag|semver>[#variant]'.")
+	lockCmd.Flags().StringVarP(&lockArgs.output, "output", "o", registry.LockFileName,
+		"Path to the lock file.")
+
+	rootCmd.AddCommand(lockCmd)
+}
+
+func runLockCmd(cmd *cobra.Command, args []string) error {
+	if len(lockArgs.artifacts) == 0 {
+		return fmt.Errorf("at least one artifact must be specified with -a")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	lock := registry.NewLockFile()
+	for _, ociURL := range lockArgs.artifacts {
+		baseURL, variant := registry.SplitVariant(ociURL)
+		url, err := registry.ResolveURL(ctx, registryOpts, baseURL, "")
+		if err != nil {
+			return fmt.Errorf("parsing %s failed: %w", ociURL, err)
+		}
+
+		manifest, err := registry.GetManifest(ctx, registryOpts, url)
+		if err != nil {
+			return fmt.Errorf("resolving %s failed: %w", ociURL, err)
+		}
+
+		digest := registry.URLPrefix + manifest.Digest
+		if variant != "" {
+			digest += registry.VariantSeparator + variant
+		}
+
+		lock.Artifacts = append(lock.Artifacts, registry.LockedArtifact{URL: ociURL, Digest: digest})
+		logger.Println("locked", ociURL, "to", digest)
+	}
+
+	if err := lock.Write(lockArgs.output); err != nil {
+		return fmt.Errorf("writing lock file failed: %w", err)
+	}
+	logger.Println("lock file written to", lockArgs.output)
+
+	return nil
+}
+
+// checkDigests returns an error if any of the artifacts is not referenced by digest.
+func checkDigests(artifacts []string) error {
+	for _, artifact := range artifacts {
+		if !registry.IsDigest(artifact) {
+			return fmt.Errorf("%s is not referenced by digest, use 'oci://<repo>@sha256:<digest>' or lock it with 'kustomizer lock'", artifact)
+		}
+	}
+	return nil
+}
+
+// lockArtifacts replaces the artifacts found in the lock file with their locked digests,
+// if the lock file doesn't exist the artifacts are returned unchanged.
+// When the lock file exists, the artifacts missing from it are rejected unless they are
+// referenced by digest or allowUnlocked is set.
+func lockArtifacts(artifacts []string, lockFile string, allowUnlocked bool) ([]string, error) {
+	if lockFile == "" || len(artifacts) == 0 {
+		return artifacts, nil
+	}
+
+	lock, err := registry.ReadLockFile(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading lock file failed: %w", err)
+	}
+	if lock == nil {
+		return artifacts, nil
+	}
+
+	result := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if digest, ok := lock.Lookup(artifact); ok {
+			logger.Println("using locked", strings.TrimPrefix(digest, registry.URLPrefix), "for", artifact)
+			result = append(result, digest)
+			continue
+		}
+		if registry.IsDigest(artifact) {
+			result = append(result, artifact)
+			continue
+		}
+		if !allowUnlocked {
+			return nil, fmt.Errorf("%s not found in %s, lock it with 'kustomizer lock' or use --allow-unlocked to resolve it from the registry", artifact, lockFile)
+		}
+		logger.Println(artifact, "not found in", lockFile, "resolving it from the registry")
+		result = append(result, artifact)
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLock(t *testing.T) {
+	g := NewWithT(t)
+	id := randStringRunes(5)
+	artifact := fmt.Sprintf("oci://%s/%s:v1.0.0", registryHost, id)
+	lockFile := filepath.Join(tmpDir, id+".lock")
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dirRepushed, err := makeTestDir(id+"-repushed", testManifests(id+"-repushed", id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("lock artifact", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s",
+			artifact,
+			dir,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"lock -a %s -o %s",
+			artifact,
+			lockFile,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		data, err := os.ReadFile(lockFile)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring(fmt.Sprintf("oci://%s/%s@sha256:", registryHost, id)))
+	})
+
+	t.Run("pull locked digest after tag is re-pushed", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s",
+			artifact,
+			dirRepushed,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"pull artifact %s --lock-file %s",
+			artifact,
+			lockFile,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).NotTo(ContainSubstring(id + "-repushed"))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s",
+			artifact,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(id + "-repushed"))
+	})
+
+	t.Run("reject artifact missing from the lock file", func(t *testing.T) {
+		unlocked := fmt.Sprintf("oci://%s/%s:v2.0.0", registryHost, id)
+		_, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s",
+			unlocked,
+			dir,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --lock-file %s",
+			unlocked,
+			lockFile,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("not found in " + lockFile))
+
+		output, err := executeCommand(fmt.Sprintf(
+			"pull artifact %s --lock-file %s --allow-unlocked",
+			unlocked,
+			lockFile,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(id))
+	})
+}
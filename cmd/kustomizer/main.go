@@ -17,8 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/fluxcd/pkg/ssa"
@@ -27,6 +29,8 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/stefanprodan/kustomizer/pkg/config"
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
 
 var VERSION = "2.0.0-dev.0"
@@ -45,8 +49,11 @@ Distribute Kubernetes configuration as OCI artifacts to container registries:
 
 - kustomizer push artifact oci://<image-url>:<tag> -k [-f] [-p]
 - kustomizer tag artifact oci://<image-url>:<tag> <new-tag>
+- kustomizer copy artifact oci://<image-url>:<tag> oci://<image-url>:<tag>
+- kustomizer delete artifact oci://<image-url>:<tag> [--digest]
 - kustomizer pull artifact oci://<image-url>:<tag>
 - kustomizer inspect artifact oci://<image-url>:<tag>
+- kustomizer lock -a oci://<image-url>:<tag> [-o kustomizer.lock]
 
 Build, customize and apply Kubernetes resources:
 
@@ -59,15 +66,59 @@ Manage the applied Kubernetes resources:
 - kustomizer get inventories --namespace <namespace>
 - kustomizer inspect inventory <name> --namespace <namespace>
 - kustomizer delete inventory <name> --namespace <namespace>
+- kustomizer history -i <name> --namespace <namespace>
+- kustomizer rollback -i <name> --namespace <namespace> --to-revision <number>
+- kustomizer watch -i <name> --namespace <namespace> --interval <duration>
+- kustomizer inventory export -i <name> --namespace <namespace>
+- kustomizer inventory import -f <path> --namespace <namespace>
+- kustomizer inventory upgrade --namespace <namespace>
+
+Registry credentials are read from '~/.docker/config.json', unless specified with
+'--creds' or '--registry-token' or with the $KUSTOMIZER_REGISTRY_CREDS and $KUSTOMIZER_REGISTRY_TOKEN env vars.
+The explicit credentials are sent only to the registry of the artifact, or to the one set with '--creds-registry'.
+For ECR, GCR, Artifact Registry and ACR, the ambient cloud identity can be used with '--provider aws|gcp|azure'.
+Registries with self-signed certificates or without TLS can be accessed with '--insecure-registry <host>',
+registries with private CAs or mTLS with '--registry-ca-file', '--registry-cert-file' and '--registry-key-file'.
 `,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		registryOpts = registry.Options{
+			Credentials:        envOrDefault(rootArgs.registryCreds, registryCredsEnv),
+			Token:              envOrDefault(rootArgs.registryToken, registryTokenEnv),
+			Registry:           rootArgs.registryCredsHost,
+			Provider:           rootArgs.registryProvider,
+			InsecureRegistries: rootArgs.insecureRegistries,
+			CAFile:             rootArgs.registryCAFile,
+			CertFile:           rootArgs.registryCertFile,
+			KeyFile:            rootArgs.registryKeyFile,
+			Mirrors:            registryMirrors(),
+		}
+		return registryOpts.Validate()
+	},
 }
 
 type rootFlags struct {
-	timeout time.Duration
+	timeout            time.Duration
+	inventoryKind      string
+	registryCreds      string
+	registryToken      string
+	registryCredsHost  string
+	registryProvider   string
+	insecureRegistries []string
+	registryCAFile     string
+	registryCertFile   string
+	registryKeyFile    string
 }
 
+const (
+	// registryCredsEnv is the environment variable that holds the registry credentials in the format 'username:password'.
+	registryCredsEnv = "KUSTOMIZER_REGISTRY_CREDS"
+	// registryTokenEnv is the environment variable that holds the registry bearer token.
+	registryTokenEnv = "KUSTOMIZER_REGISTRY_TOKEN"
+)
+
 var (
 	rootArgs       = rootFlags{}
+	registryOpts   = registry.Options{}
 	logger         = stderrLogger{stderr: os.Stderr}
 	cfg            = config.NewConfig()
 	inventoryOwner = ssa.Owner{
@@ -81,6 +132,28 @@ var kubeconfigArgs = genericclioptions.NewConfigFlags(false)
 func init() {
 	rootCmd.PersistentFlags().DurationVar(&rootArgs.timeout, "timeout", time.Minute,
 		"The length of time to wait before giving up on the current operation.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.inventoryKind, "inventory-kind", inventory.ConfigMapStorage,
+		"The kind of the object that stores the inventory, can be configmap, secret or inventory (the inventories.kustomizer.dev custom resource).")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.registryCreds, "creds", "",
+		"The container registry credentials in the format 'username:password', defaults to $"+registryCredsEnv+". When not specified, the credentials are read from '~/.docker/config.json'.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.registryToken, "registry-token", "",
+		"The container registry bearer token e.g. a short-lived token issued by the CI, defaults to $"+registryTokenEnv+".")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.registryCredsHost, "creds-registry", "",
+		"The registry host e.g. 'ghcr.io' the credentials and token are sent to, "+
+			"defaults to the registry of the artifact URL, or to the destination registry when copying artifacts.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.registryProvider, "provider", registry.GenericProvider,
+		"The registry authentication provider, can be 'generic' for the docker config credentials, "+
+			"'aws' for ECR with the AWS CLI credentials, 'gcp' for GCR and Artifact Registry with the Google application default credentials "+
+			"or 'azure' for ACR with the Azure CLI credentials.")
+	rootCmd.PersistentFlags().StringSliceVar(&rootArgs.insecureRegistries, "insecure-registry", nil,
+		"The registry host e.g. 'localhost:5000' or 'registry.svc.cluster.local' that is accessed without TLS verification "+
+			"and with a fallback to plain HTTP, can be specified multiple times.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.registryCAFile, "registry-ca-file", "",
+		"Path to a PEM bundle of the certificate authorities used to verify the registry, in addition to the system trust store.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.registryCertFile, "registry-cert-file", "",
+		"Path to the PEM encoded client certificate used for mTLS with the registry.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.registryKeyFile, "registry-key-file", "",
+		"Path to the PEM encoded client key used for mTLS with the registry.")
 
 	kubeconfigArgs.Timeout = nil
 	kubeconfigArgs.Namespace = nil
@@ -97,11 +170,59 @@ func init() {
 func main() {
 	loadConfig()
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			if exitErr.err != nil {
+				logger.Println(`✗`, exitErr.err)
+			}
+			os.Exit(exitErr.code)
+		}
 		logger.Println(`✗`, err)
 		os.Exit(1)
 	}
 }
 
+// exitCodeError is returned by the commands that must exit with a specific code,
+// the process exits in main so that the deferred cleanups of the command run first.
+type exitCodeError struct {
+	code   int
+	reason string
+
+	// err is the failure that caused the exit, printed before exiting when set.
+	err error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("exit code %d: %s, error: %s", e.code, e.reason, e.err)
+	}
+	return fmt.Sprintf("exit code %d: %s", e.code, e.reason)
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// registryMirrors returns the registry mirrors from the config.
+func registryMirrors() []registry.Mirror {
+	var mirrors []registry.Mirror
+	for _, m := range cfg.Mirrors {
+		mirrors = append(mirrors, registry.Mirror{
+			Source: strings.TrimSuffix(m.Source, "/"),
+			Target: strings.TrimSuffix(m.Mirror, "/"),
+		})
+	}
+	return mirrors
+}
+
+// envOrDefault returns the flag value or, when the flag is not set, the value of the env var.
+func envOrDefault(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
 func loadConfig() {
 	if c, err := config.Read(""); err != nil {
 		logger.Println(`✗`, fmt.Errorf("loading the config failed, error: %w", err))
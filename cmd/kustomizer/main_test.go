@@ -35,6 +35,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
 )
 
 func init() {
@@ -113,7 +115,10 @@ func startTestRegistry() (string, error) {
 	config.Log.AccessLog.Disabled = true
 	config.HTTP.Addr = fmt.Sprintf(":%d", port)
 	config.HTTP.DrainTimeout = time.Duration(10) * time.Second
-	config.Storage = map[string]configuration.Parameters{"inmemory": map[string]interface{}{}}
+	config.Storage = map[string]configuration.Parameters{
+		"inmemory": map[string]interface{}{},
+		"delete":   map[string]interface{}{"enabled": true},
+	}
 	dockerRegistry, err := registry.NewRegistry(context.Background(), config)
 	if err != nil {
 		return "", err
@@ -198,16 +203,44 @@ func executeCommand(cmd string) (string, error) {
 }
 
 func resetCmdArgs() {
-	applyInventoryArgs = applyInventoryFlags{}
+	applyInventoryArgs = applyInventoryFlags{serverSide: true}
 	buildInventoryArgs = buildInventoryFlags{}
+	copyArtifactArgs = copyArtifactFlags{}
+	deleteArgs = deleteFlags{}
+	deleteArtifactArgs = deleteArtifactFlags{}
+	historyArgs = historyFlags{}
+	rollbackArgs = rollbackFlags{}
+	inspectInventoryArgs = inspectInventoryFlags{}
+	inventoryExportArgs = inventoryExportFlags{}
+	inventoryImportArgs = inventoryImportFlags{}
+	inventoryDiffArgs = inventoryDiffFlags{}
+	inventoryRebuildArgs = inventoryRebuildFlags{}
+	inventoryAdoptArgs = inventoryAdoptFlags{}
+	inventoryOrphansArgs = inventoryOrphansFlags{}
+	inventoryMoveArgs = inventoryMoveFlags{}
+	watchArgs = watchFlags{}
+	inventoryUpgradeArgs = inventoryUpgradeFlags{}
+	inventoryMigrateArgs = inventoryMigrateFlags{}
+	pruneExpiredArgs = pruneExpiredFlags{}
 	deleteInventoryArgs = deleteInventoryFlags{}
 	diffInventoryArgs = diffInventoryFlags{}
 	diffArtifactArgs = diffArtifactFlags{}
+	diffLocalArgs = diffLocalFlags{}
 	getInventoriesArgs = getInventoriesFlags{}
 	inspectArtifactArgs = inspectArtifactFlags{}
 	listArtifactArgs = listArtifactFlags{}
+	lockArgs = lockFlags{}
 	pullArtifactArgs = pullArtifactFlags{}
 	pushArtifactArgs = pushArtifactFlags{}
+	rootArgs.inventoryKind = inventory.ConfigMapStorage
+	rootArgs.registryCreds = ""
+	rootArgs.registryToken = ""
+	rootArgs.registryCredsHost = ""
+	rootArgs.registryProvider = "generic"
+	rootArgs.insecureRegistries = nil
+	rootArgs.registryCAFile = ""
+	rootArgs.registryCertFile = ""
+	rootArgs.registryKeyFile = ""
 }
 
 var testManifests = func(name, namespace string, immutable bool) []TestFile {
@@ -0,0 +1,41 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"path"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/stefanprodan/kustomizer/pkg/drift"
+)
+
+// maskFields redacts the fields matched by the config masking rules
+// while preserving the information about which values changed.
+func maskFields(existingObject, desiredObject *unstructured.Unstructured) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	kind := desiredObject.GetKind()
+	for _, rule := range cfg.Masking {
+		if ok, _ := path.Match(rule.Kind, kind); !ok {
+			continue
+		}
+
+		// the paths are validated when the config is loaded
+		paths, _ := drift.ParsePaths(rule.Paths)
+		existingObject, desiredObject = drift.Mask(existingObject, desiredObject, paths)
+	}
+	return existingObject, desiredObject
+}
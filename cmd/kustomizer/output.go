@@ -0,0 +1,161 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+// inventoryOutput is the machine-readable representation of an inventory.
+type inventoryOutput struct {
+	Name          string                `json:"name"`
+	Namespace     string                `json:"namespace"`
+	Source        string                `json:"source,omitempty"`
+	Revision      string                `json:"revision,omitempty"`
+	LastAppliedAt string                `json:"lastAppliedAt,omitempty"`
+	Group         string                `json:"group,omitempty"`
+	DependsOn     []string              `json:"dependsOn,omitempty"`
+	TTL           string                `json:"ttl,omitempty"`
+	Namespaces    []string              `json:"namespaces,omitempty"`
+	Meta          map[string]string     `json:"meta,omitempty"`
+	Artifacts     []string              `json:"artifacts,omitempty"`
+	Provenance    *inventory.Provenance `json:"provenance,omitempty"`
+	Entries       int                   `json:"entries"`
+
+	// Ready is the number of objects that are ready, set only when the status is requested.
+	Ready *int `json:"ready,omitempty"`
+
+	// Objects is the list of the tracked objects, set only when inspecting an inventory.
+	Objects []inventoryObjectOutput `json:"objects,omitempty"`
+}
+
+// inventoryObjectOutput is the machine-readable representation of an inventory entry.
+type inventoryObjectOutput struct {
+	// Subject represents the object ID in the format 'kind/namespace/name'.
+	Subject string `json:"subject"`
+
+	// Status is the existence and the readiness of the in-cluster object.
+	Status string `json:"status"`
+
+	// Action is the action taken for the object by the last apply.
+	Action string `json:"action,omitempty"`
+
+	// AppliedAt is the timestamp of the last apply that created or configured the object.
+	AppliedAt string `json:"appliedAt,omitempty"`
+
+	// Generation is the object generation observed after it was last created or configured.
+	Generation int64 `json:"generation,omitempty"`
+}
+
+// revisionOutput is the machine-readable representation of an inventory revision.
+type revisionOutput struct {
+	Number          int      `json:"number"`
+	AppliedAt       string   `json:"appliedAt"`
+	Source          string   `json:"source,omitempty"`
+	Revision        string   `json:"revision,omitempty"`
+	Artifacts       []string `json:"artifacts,omitempty"`
+	Checksum        string   `json:"checksum"`
+	Changes         int      `json:"changes"`
+	Entries         int      `json:"entries"`
+	StoredManifests bool     `json:"storedManifests"`
+}
+
+// orphanOutput is the machine-readable representation of an orphaned object.
+type orphanOutput struct {
+	// Subject represents the object ID in the format 'kind/namespace/name'.
+	Subject string `json:"subject"`
+
+	// Inventory is the value of the inventory label set on the object.
+	Inventory string `json:"inventory"`
+
+	// Reason explains why the object is orphaned.
+	Reason string `json:"reason"`
+}
+
+func newInventoryOutput(inv *inventory.Inventory) inventoryOutput {
+	out := inventoryOutput{
+		Name:          inv.Name,
+		Namespace:     inv.Namespace,
+		Source:        inv.Source,
+		Revision:      inv.Revision,
+		LastAppliedAt: inv.LastAppliedAt,
+		Group:         inv.Group,
+		DependsOn:     inv.DependsOn,
+		Namespaces:    inv.Namespaces,
+		Meta:          inv.Meta,
+		Artifacts:     inv.Artifacts,
+		Provenance:    inv.Provenance,
+		Entries:       len(inv.Resources),
+	}
+	if inv.TTL > 0 {
+		out.TTL = inv.TTL.String()
+	}
+	return out
+}
+
+func newRevisionOutput(rev inventory.Revision) revisionOutput {
+	return revisionOutput{
+		Number:          rev.Number,
+		AppliedAt:       rev.AppliedAt,
+		Source:          rev.Source,
+		Revision:        rev.Revision,
+		Artifacts:       rev.Artifacts,
+		Checksum:        rev.Checksum,
+		Changes:         rev.Changes,
+		Entries:         len(rev.Resources),
+		StoredManifests: rev.StoredManifests,
+	}
+}
+
+// validateOutput returns an error if the given output format is not supported by the inventory commands.
+func validateOutput(output string) error {
+	switch output {
+	case "", "text", "json", "yaml":
+		return nil
+	default:
+		return fmt.Errorf("unsupported output, can be text, json or yaml")
+	}
+}
+
+// isStructuredOutput returns true if the given output format is JSON or YAML.
+func isStructuredOutput(output string) bool {
+	return output == "json" || output == "yaml"
+}
+
+// printOutput writes the given value to stdout in JSON or YAML format.
+func printOutput(output string, v interface{}) error {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		rootCmd.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		rootCmd.Print(string(data))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var pruneExpiredCmd = &cobra.Command{
+	Use:   "expired",
+	Short: "Prune deletes the inventories applied with --inventory-ttl whose last apply is older than their TTL, including their objects.",
+	Example: `  kustomizer prune expired -n <namespace>
+
+  # Delete the expired preview environments
+  kustomizer prune expired -n previews
+
+  # List the expired inventories across all namespaces without deleting them
+  kustomizer prune expired --all-namespaces --dry-run
+`,
+	RunE: runPruneExpiredCmd,
+}
+
+type pruneExpiredFlags struct {
+	allNamespaces bool
+	dryRun        bool
+}
+
+var pruneExpiredArgs pruneExpiredFlags
+
+func init() {
+	pruneExpiredCmd.Flags().BoolVar(&pruneExpiredArgs.allNamespaces, "all-namespaces", false,
+		"Prune the expired inventories across all namespaces.")
+	pruneExpiredCmd.Flags().BoolVar(&pruneExpiredArgs.dryRun, "dry-run", false,
+		"Print the expired inventories without deleting them.")
+
+	pruneCmd.AddCommand(pruneExpiredCmd)
+}
+
+func runPruneExpiredCmd(cmd *cobra.Command, args []string) error {
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	ns := *kubeconfigArgs.Namespace
+	if pruneExpiredArgs.allNamespaces {
+		ns = ""
+	}
+	inventories, err := invStorage.ListInventories(ctx, ns)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var expired []*inventory.Inventory
+	for _, inv := range inventories {
+		if inv.IsExpired(now) {
+			expired = append(expired, inv)
+		}
+	}
+
+	if len(expired) == 0 {
+		logger.Println("no expired inventories found")
+		return nil
+	}
+
+	for _, inv := range expired {
+		if pruneExpiredArgs.dryRun {
+			rootCmd.Println(fmt.Sprintf("%s expired (last applied at %s, ttl %s)", inv.ID(), inv.LastAppliedAt, inv.TTL))
+			continue
+		}
+
+		logger.Println(fmt.Sprintf("deleting expired inventory %s (last applied at %s, ttl %s)", inv.ID(), inv.LastAppliedAt, inv.TTL))
+		if err := deleteInventory(inv.Name, inv.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPruneExpired(t *testing.T) {
+	g := NewWithT(t)
+	id := "ttl-" + randStringRunes(5)
+	expired := "expired-" + id
+	active := "active-" + id
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates inventories with TTL", func(t *testing.T) {
+		for name, ttl := range map[string]string{expired: "1s", active: "1h"} {
+			dir, err := makeTestDir(name, testManifests(name, id, false))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			_, err = executeCommand(fmt.Sprintf(
+				"apply inv %s -k %s -n %s --inventory-ttl %s",
+				name,
+				dir,
+				id,
+				ttl,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	t.Run("lists the expired inventories", func(t *testing.T) {
+		time.Sleep(2 * time.Second)
+
+		output, err := executeCommand(fmt.Sprintf("prune expired -n %s --dry-run", id))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("%s/%s expired", id, expired)))
+		g.Expect(output).NotTo(MatchRegexp(active))
+	})
+
+	t.Run("deletes the expired inventories", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf("prune expired -n %s", id))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf("get inventories -n %s", id))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).NotTo(MatchRegexp(expired))
+		g.Expect(output).To(MatchRegexp(active))
+	})
+}
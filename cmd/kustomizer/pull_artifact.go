@@ -18,10 +18,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/spf13/cobra"
 	"os"
 	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
 
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
@@ -29,8 +32,9 @@ import (
 var pullArtifactCmd = &cobra.Command{
 	Use:   "artifact",
 	Short: "Pull downloads Kubernetes manifests from a container registry.",
-	Long: `The pull command downloads the specified OCI artifact and writes the Kubernetes manifests to stdout.
-For private registries, the pull command uses the credentials from '~/.docker/config.json'.`,
+	Long: `The pull command downloads the specified OCI artifact, verifies its checksum
+and writes the Kubernetes manifests to stdout or to a directory.
+For private registries, the pull command uses the credentials from '~/.docker/config.json', or the credentials specified with '--creds' or '--registry-token'.`,
 	Example: `  kustomizer pull artifact <oci url>
 
   # Pull Kubernetes manifests from an OCI artifact hosted on Docker Hub
@@ -39,22 +43,73 @@ For private registries, the pull command uses the credentials from '~/.docker/co
   # Pull an OCI artifact using the digest and write the Kubernetes manifests to stdout
   kustomizer pull artifact oci://docker.io/user/repo@sha256:<digest>
 
+  # Pull only artifacts pinned to digests, directly or through the lock file
+  kustomizer pull artifact oci://docker.io/user/repo@sha256:<digest> --require-digest
+
+  # Pull a tag that is not recorded in the lock file of the current directory
+  kustomizer pull artifact oci://docker.io/user/repo:v2.0.0 --allow-unlocked
+
+  # Pull an OCI artifact and write the Kubernetes manifests to ./deploy/all.yaml
+  kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 -o ./deploy
+
+  # Pull an artifact pushed with '--mode source' and write the kustomization directory to ./overlay
+  kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 -o ./overlay
+
+  # Pull from Artifact Registry with the Google application default credentials e.g. GKE workload identity
+  kustomizer pull artifact oci://us-docker.pkg.dev/project/repo/app:v1.0.0 --provider gcp
+
+  # Pull from an in-cluster registry without TLS
+  kustomizer pull artifact oci://registry.registry.svc.cluster.local:5000/repo:v1.0.0 --insecure-registry registry.registry.svc.cluster.local:5000
+
+  # Pull from a registry with a private CA and mTLS
+  kustomizer pull artifact oci://registry.example.com/repo:v1.0.0 \
+	--registry-ca-file ./certs/ca.pem \
+	--registry-cert-file ./certs/client.pem \
+	--registry-key-file ./certs/client-key.pem
+
+  # Pull the production variant of a bundle artifact
+  kustomizer pull artifact oci://docker.io/user/repo:v1.0.0#production
+
+  # Pull only the CRDs layer of an artifact pushed with --split-layers
+  kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --layer crds
+
+  # Pull the newest artifact in the 1.x range
+  kustomizer pull artifact oci://docker.io/user/repo:^1.0
+
+  # Pull the newest artifact matching a semver range
+  kustomizer pull artifact oci://docker.io/user/repo --semver ">=1.0 <2.0"
+
   # Pull the latest artifact from a local registry
   kustomizer pull artifact oci://localhost:5000/repo
 
   # Pull and verify artifact with cosign
   kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --verify --cosign-key ./keys/cosign.pub
 
+  # Pull and verify artifact signed keyless in GitHub Actions
+  kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --verify --certificate-identity https://github.com/org/repo/.github/workflows/release.yaml@refs/tags/v1.0.0 --certificate-oidc-issuer https://token.actions.githubusercontent.com
+
   # Pull encrypted artifact
   kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --age-identities ./keys/id.txt
+
+  # Pull an artifact encrypted for multiple recipients using one of the identities
+  kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --decrypt-identity ./keys/team-a.txt --decrypt-identity ./keys/team-b.txt
 `,
 	RunE: runPullArtifactCmd,
 }
 
 type pullArtifactFlags struct {
-	ageIdentities string
-	verify        bool
-	verifyKey     string
+	ageIdentities   string
+	decryptIdentity []string
+	verify          bool
+	verifyKey       string
+	certIdentity    string
+	certOIDCIssuer  string
+	output          string
+	semverExp       string
+	layers          []string
+	lockFile        string
+	requireDigest   bool
+	allowUnlocked   bool
 }
 
 var pullArtifactArgs pullArtifactFlags
@@ -62,11 +117,29 @@ var pullArtifactArgs pullArtifactFlags
 func init() {
 	pullArtifactCmd.Flags().StringVar(&pullArtifactArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	pullArtifactCmd.Flags().StringSliceVar(&pullArtifactArgs.decryptIdentity, "decrypt-identity", nil,
+		"Path to an age identity file used to decrypt encrypted artifacts, can be specified multiple times.")
 	pullArtifactCmd.Flags().BoolVar(&pullArtifactArgs.verify, "verify", false,
 		"Verify the artifact signature with cosign.")
 	pullArtifactCmd.Flags().StringVar(&pullArtifactArgs.verifyKey, "cosign-key", "",
 		"Path to the consign public key file, KMS URI or Kubernetes Secret. "+
-			"When not specified, cosign will try to verify the signature using Rekor.")
+			"When not specified, cosign verifies the keyless signature using Rekor and the certificate identity constraints.")
+	pullArtifactCmd.Flags().StringVar(&pullArtifactArgs.certIdentity, "certificate-identity", "",
+		"The identity expected in the keyless signing certificate e.g. 'https://github.com/org/repo/.github/workflows/release.yaml@refs/tags/v1.0.0'.")
+	pullArtifactCmd.Flags().StringVar(&pullArtifactArgs.certOIDCIssuer, "certificate-oidc-issuer", "",
+		"The OIDC issuer expected in the keyless signing certificate e.g. 'https://token.actions.githubusercontent.com'.")
+	pullArtifactCmd.Flags().StringVar(&pullArtifactArgs.lockFile, "lock-file", registry.LockFileName,
+		"Path to the lock file generated with 'kustomizer lock', if the artifact is found in the lock file it is pulled by its locked digest.")
+	pullArtifactCmd.Flags().BoolVar(&pullArtifactArgs.allowUnlocked, "allow-unlocked", false,
+		"Resolve the artifact from the registry when it's not found in the lock file, by default the artifacts missing from an existing lock file are rejected.")
+	pullArtifactCmd.Flags().BoolVar(&pullArtifactArgs.requireDigest, "require-digest", false,
+		"Reject the artifact if it's not referenced by digest e.g. 'oci://docker.io/user/repo@sha256:<digest>', either directly or through the lock file.")
+	pullArtifactCmd.Flags().StringSliceVar(&pullArtifactArgs.layers, "layer", nil,
+		"Pull only the named layers of an artifact pushed with '--split-layers' e.g. 'crds'.")
+	pullArtifactCmd.Flags().StringVar(&pullArtifactArgs.semverExp, "semver", "",
+		"Pull the newest tag that matches the semantic version constraint e.g. '>=1.0 <2.0'.")
+	pullArtifactCmd.Flags().StringVarP(&pullArtifactArgs.output, "output", "o", "-",
+		"The directory where the Kubernetes manifests are written to, when set to '-' the manifests are written to stdout.")
 
 	pullCmd.AddCommand(pullArtifactCmd)
 }
@@ -76,35 +149,115 @@ func runPullArtifactCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("you must specify an artifact name e.g. 'oci://docker.io/user/repo:tag'")
 	}
 
-	url, err := registry.ParseURL(args[0])
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	locked, err := lockArtifacts(args[:1], pullArtifactArgs.lockFile, pullArtifactArgs.allowUnlocked)
+	if err != nil {
+		return err
+	}
+
+	if pullArtifactArgs.requireDigest {
+		if err := checkDigests(locked); err != nil {
+			return err
+		}
+	}
+
+	baseURL, variant := registry.SplitVariant(locked[0])
+	url, err := registry.ResolveURL(ctx, registryOpts, baseURL, pullArtifactArgs.semverExp)
 	if err != nil {
 		return err
 	}
 
 	if pullArtifactArgs.verify {
-		if err := verifyCosign(url, pullArtifactArgs.verifyKey); err != nil {
+		url, err = verifyArtifact(ctx, url, cosignVerifyOptions{
+			key:            pullArtifactArgs.verifyKey,
+			certIdentity:   pullArtifactArgs.certIdentity,
+			certOIDCIssuer: pullArtifactArgs.certOIDCIssuer,
+		})
+		if err != nil {
 			return err
 		}
 	}
 
-	identities, err := registry.ParseAgeIdentities(pullArtifactArgs.ageIdentities)
+	identities, err := registry.ParseAgeIdentities(append([]string{pullArtifactArgs.ageIdentities}, pullArtifactArgs.decryptIdentity...)...)
 	if err != nil {
 		return fmt.Errorf("faild to read decryption keys: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
-	defer cancel()
-
-	yml, _, err := registry.Pull(ctx, url, identities)
+	var yml string
+	var meta *registry.Metadata
+	layers := pullArtifactArgs.layers
+	if variant != "" {
+		layers = append([]string{variant}, layers...)
+	}
+	if len(layers) > 0 {
+		var contents []registry.Content
+		contents, meta, err = registry.PullLayers(ctx, registryOpts, url, identities, layers...)
+		yml = registry.JoinContents(contents)
+	} else {
+		yml, meta, err = registry.Pull(ctx, registryOpts, url, identities)
+	}
+	if errors.Is(err, registry.ErrSourceArtifact) {
+		if pullArtifactArgs.output == "" || pullArtifactArgs.output == "-" {
+			return fmt.Errorf("%w, use -o to specify the directory", err)
+		}
+		meta, err = registry.PullSource(ctx, registryOpts, url, pullArtifactArgs.output)
+		if err != nil {
+			return fmt.Errorf("pulling %s failed: %w", url, err)
+		}
+		logger.Println(fmt.Sprintf("sources written to %s (checksum %s)", pullArtifactArgs.output, meta.Checksum))
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("pulling %s failed: %w", url, err)
 	}
 
-	rootCmd.Println(yml)
+	if pullArtifactArgs.output == "" || pullArtifactArgs.output == "-" {
+		rootCmd.Println(yml)
+		return nil
+	}
+
+	if err := os.MkdirAll(pullArtifactArgs.output, os.ModePerm); err != nil {
+		return fmt.Errorf("creating output directory failed: %w", err)
+	}
+
+	manifestsPath := filepath.Join(pullArtifactArgs.output, "all.yaml")
+	if err := os.WriteFile(manifestsPath, []byte(yml), 0644); err != nil {
+		return fmt.Errorf("writing manifests failed: %w", err)
+	}
+
+	logger.Println(fmt.Sprintf("manifests written to %s (checksum %s)", manifestsPath, meta.Checksum))
 	return nil
 }
 
-func verifyCosign(url, key string) error {
+// cosignVerifyOptions holds the cosign public key and the constraints of the keyless signing certificate.
+type cosignVerifyOptions struct {
+	key            string
+	certIdentity   string
+	certOIDCIssuer string
+}
+
+// verifyArtifact resolves the artifact URL to its digest and verifies the signature of the digest with cosign.
+// It returns the digest URL, pulling the artifact by digest ensures the content can't change after the verification.
+func verifyArtifact(ctx context.Context, url string, opts cosignVerifyOptions) (string, error) {
+	manifest, err := registry.GetManifest(ctx, registryOpts, url)
+	if err != nil {
+		return "", fmt.Errorf("fetching manifest %s failed: %w", url, err)
+	}
+
+	if err := verifyCosign(manifest.Digest, opts); err != nil {
+		return "", err
+	}
+	return manifest.Digest, nil
+}
+
+func verifyCosign(url string, opts cosignVerifyOptions) error {
+	// without a key any certificate issued by Fulcio is valid, the signer identity must be pinned
+	if opts.key == "" && (opts.certIdentity == "" || opts.certOIDCIssuer == "") {
+		return fmt.Errorf("keyless verification requires --certificate-identity and --certificate-oidc-issuer")
+	}
+
 	cosign, err := exec.LookPath("cosign")
 	if err != nil {
 		return fmt.Errorf("cosign not found in path $PATH: %w", err)
@@ -113,12 +266,21 @@ func verifyCosign(url, key string) error {
 	cosignCmd := exec.Command(cosign, []string{"verify"}...)
 	cosignCmd.Env = os.Environ()
 
-	if key != "" {
-		cosignCmd.Args = append(cosignCmd.Args, "--key", key)
+	if opts.key != "" {
+		cosignCmd.Args = append(cosignCmd.Args, "--key", opts.key)
 	} else {
 		cosignCmd.Env = append(cosignCmd.Env, "COSIGN_EXPERIMENTAL=true")
 	}
-	cosignCmd.Args = append(cosignCmd.Args, url)
+	if opts.certIdentity != "" {
+		cosignCmd.Args = append(cosignCmd.Args, "--certificate-identity", opts.certIdentity)
+	}
+	if opts.certOIDCIssuer != "" {
+		cosignCmd.Args = append(cosignCmd.Args, "--certificate-oidc-issuer", opts.certOIDCIssuer)
+	}
+	if registryOpts.IsInsecure(registryOpts.MirrorURL(url)) {
+		cosignCmd.Args = append(cosignCmd.Args, "--allow-insecure-registry")
+	}
+	cosignCmd.Args = append(cosignCmd.Args, registryOpts.MirrorURL(url))
 
 	if msg, err := cosignCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("cosign verify failed, %s %w", msg, err)
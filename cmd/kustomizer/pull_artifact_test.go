@@ -18,9 +18,14 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	. "github.com/onsi/gomega"
+
+	"github.com/stefanprodan/kustomizer/pkg/config"
 )
 
 func TestPull(t *testing.T) {
@@ -57,4 +62,122 @@ func TestPull(t *testing.T) {
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp(id))
 	})
+
+	t.Run("keyless verification requires identity constraints", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"pull artifact %s --verify",
+			artifact,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("--certificate-identity"))
+	})
+
+	t.Run("pull artifact with docker credential helper", func(t *testing.T) {
+		configDir, err := makeTestDir(id+"-docker", []TestFile{
+			{
+				Name: "config.json",
+				Body: fmt.Sprintf(`{"credHelpers": {"%s": "kustomizer-test"}}`, registryHost),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Setenv("DOCKER_CONFIG", configDir)
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s",
+			artifact,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("docker-credential-kustomizer-test"))
+	})
+
+	t.Run("pull artifact to directory", func(t *testing.T) {
+		outDir := filepath.Join(tmpDir, id+"-pull")
+		output, err := executeCommand(fmt.Sprintf(
+			"pull artifact %s -o %s",
+			artifact,
+			outDir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		data, err := os.ReadFile(filepath.Join(outDir, "all.yaml"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(MatchRegexp(id))
+	})
+
+	t.Run("pull artifact by semver range", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"pull artifact oci://%s/%s:^1.0",
+			registryHost,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(id))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact oci://%s/%s --semver 2.x",
+			registryHost,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("no tag matching semver"))
+	})
+
+	t.Run("pull artifact by digest", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"pull artifact %s --require-digest",
+			artifact,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("not referenced by digest"))
+
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect artifact %s",
+			artifact,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		digest := regexp.MustCompile(`sha256:[a-f0-9]{64}`).FindString(output)
+		g.Expect(digest).NotTo(BeEmpty())
+
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact oci://%s/%s@%s --require-digest",
+			registryHost,
+			id,
+			digest,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(id))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact oci://%s/%s@%s --semver 1.x",
+			registryHost,
+			id,
+			digest,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("digest reference"))
+	})
+
+	t.Run("pull artifact from mirror", func(t *testing.T) {
+		cfg.Mirrors = []config.Mirror{{Source: "mirror.kustomizer.dev", Mirror: registryHost}}
+		defer func() { cfg.Mirrors = nil }()
+
+		output, err := executeCommand(fmt.Sprintf(
+			"pull artifact oci://mirror.kustomizer.dev/%s:%s",
+			id,
+			tag,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(id))
+	})
 }
@@ -23,12 +23,18 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
 
+	"github.com/stefanprodan/kustomizer/pkg/fetch"
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
 
@@ -38,7 +44,8 @@ var pushArtifactCmd = &cobra.Command{
 	Long: `The push command scans the given path for Kubernetes manifests or Kustomize overlays,
 builds the manifests into a multi-doc YAML, packages the YAML file into an OCI artifact and
 pushes the image to the container registry.
-The push command uses the credentials from '~/.docker/config.json'.`,
+SOPS encrypted Secrets are pushed as they are and can be decrypted at apply time with 'kustomizer apply inventory --sops'.
+The push command uses the credentials from '~/.docker/config.json', or the credentials specified with '--creds' or '--registry-token'.`,
 	Example: `  kustomizer push artifact <oci url> -k <overlay path> [-f <dir path>|<file path>]
 
   # Build Kubernetes plain manifests and push the resulting multi-doc YAML to Docker Hub
@@ -53,6 +60,16 @@ The push command uses the credentials from '~/.docker/config.json'.`,
 	--source="$(git config --get remote.origin.url)" \
 	--revision="$(git tag --points-at HEAD)/$(git rev-parse HEAD)"
 
+  # Push with the registry token issued by the CI instead of the docker config credentials
+  export KUSTOMIZER_REGISTRY_TOKEN="<TOKEN>"
+  kustomizer push artifact oci://registry.example.com/org/repo:v1.0.0 -f ./deploy/manifests
+
+  # Push with explicit registry credentials
+  kustomizer push artifact oci://ghcr.io/user/repo:v1.0.0 -f ./deploy/manifests --creds "$GITHUB_ACTOR:$GITHUB_TOKEN"
+
+  # Push to ECR with the AWS credentials of the environment e.g. an IAM role for service accounts
+  kustomizer push artifact oci://123456789012.dkr.ecr.us-east-1.amazonaws.com/repo:v1.0.0 -f ./deploy/manifests --provider aws
+
   # Push to a local registry
   kustomizer push artifact oci://localhost:5000/repo:latest -f ./deploy/manifests 
 
@@ -63,8 +80,37 @@ The push command uses the credentials from '~/.docker/config.json'.`,
   # Push and sign artifact with cosign and GitHub OIDC (GH Actions)
   kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -f ./deploy/manifests --sign
 
+  # Push and sign artifact with cosign and GitLab OIDC (GitLab CI with an 'id_tokens' entry named SIGSTORE_ID_TOKEN)
+  kustomizer push artifact oci://registry.gitlab.com/org/repo:v1.0.0 -f ./deploy/manifests --sign --identity-token "$SIGSTORE_ID_TOKEN"
+
+  # Push artifact and attach an SBOM of the container images and an in-toto attestation of the build inputs
+  kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -k ./deploy/production --sbom --attest \
+	--source="$(git config --get remote.origin.url)" \
+	--revision="$(git branch --show-current)/$(git rev-parse HEAD)"
+
+  # Push artifact with custom OCI annotations
+  kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -f ./deploy/manifests \
+	--annotation org.opencontainers.image.licenses=Apache-2.0 \
+	--annotation org.opencontainers.image.description="app deploy manifests"
+
+  # Push the CRDs, the base manifests and the overlay as separate layers named 'crds', 'base' and 'production'
+  kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -f ./deploy/crds -f ./deploy/base -k ./deploy/production --split-layers
+
+  # Push the kustomization directory instead of the rendered manifests, the overlay is built at apply time
+  kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -k ./deploy/production --mode source
+
+  # Push a bundle with the dev, staging and production overlays rendered in separate layers,
+  # the variant is selected at apply time with 'kustomizer apply inventory -a oci://docker.io/user/repo:v1.0.0#production'
+  kustomizer push artifact oci://docker.io/user/repo:v1.0.0 \
+	--variant dev=./deploy/dev \
+	--variant staging=./deploy/staging \
+	--variant production=./deploy/production
+
   # Push encrypted artifact
   kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -f ./deploy/manifests --age-recipients ./keys/pub.txt 
+
+  # Push artifact encrypted for multiple age public keys
+  kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -f ./deploy/manifests --encrypt-recipient age1... --encrypt-recipient age1...
 `,
 	RunE: runPushArtifactCmd,
 }
@@ -74,30 +120,66 @@ type pushArtifactFlags struct {
 	kustomize     string
 	patch         []string
 	ageRecipients string
+	encryptTo     []string
 	sign          bool
 	signKey       string
+	identityToken string
+	sbom          bool
+	attest        bool
+	annotations   []string
+	splitLayers   bool
+	variants      []string
+	mode          string
 	source        string
 	revision      string
+	httpHeaders   []string
+	httpToken     string
+	httpChecksums []string
 }
 
 var pushArtifactArgs pushArtifactFlags
 
 func init() {
 	pushArtifactCmd.Flags().StringSliceVarP(&pushArtifactArgs.filename, "filename", "f", nil,
-		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively.")
+		"Path to Kubernetes manifest(s). If a directory is specified, then all manifests in the directory tree will be processed recursively. Use '-' to read from stdin or an HTTP(S) URL to download the manifests, the downloads are limited to 50MiB.")
 	pushArtifactCmd.Flags().StringVarP(&pushArtifactArgs.kustomize, "kustomize", "k", "",
 		"Path to a directory that contains a kustomization.yaml.")
 	pushArtifactCmd.Flags().StringSliceVarP(&pushArtifactArgs.patch, "patch", "p", nil,
 		"Path to a kustomization file that contains a list of patches.")
 	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.ageRecipients, "age-recipients", "",
 		"Path to a file containing one or more age recipients (public keys generated by age-keygen).")
+	pushArtifactCmd.Flags().StringSliceVar(&pushArtifactArgs.encryptTo, "encrypt-recipient", nil,
+		"An age public key e.g. 'age1...' used to encrypt the artifact content before upload, can be specified multiple times.")
 	pushArtifactCmd.Flags().BoolVar(&pushArtifactArgs.sign, "sign", false,
-		"Sign the artifact with cosign.")
+		"Sign the pushed artifact digest with cosign and upload the signature to the registry, requires the cosign binary in $PATH.")
 	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.signKey, "cosign-key", "",
 		"Path to the consign private key file, KMS URI or Kubernetes Secret. "+
-			"When not specified, cosign will try to producing an identity token from the environment (GH Actions or GCP).")
+			"When not specified, cosign signs keyless with a short-lived certificate issued for the ambient OIDC identity (GH Actions, GitLab CI or GCP) "+
+			"and records the signature in the Rekor transparency log.")
+	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.identityToken, "identity-token", "",
+		"The OIDC identity token used for keyless signing, when not specified cosign detects the token from the environment.")
+	pushArtifactCmd.Flags().StringArrayVar(&pushArtifactArgs.annotations, "annotation", nil,
+		"Annotation in the format 'key=value' added to the artifact manifest e.g. 'org.opencontainers.image.licenses=Apache-2.0', can be specified multiple times.")
+	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.mode, "mode", registry.ManifestsMode,
+		"The artifact content, can be 'manifests' for the rendered Kubernetes manifests or 'source' for the raw kustomization directory specified with -k, "+
+			"which must be self-contained and is built when the artifact is applied.")
+	pushArtifactCmd.Flags().BoolVar(&pushArtifactArgs.splitLayers, "split-layers", false,
+		"Push the manifests of each input path in a separate layer named after the path, the layers can be pulled selectively with 'kustomizer pull artifact --layer'.")
+	pushArtifactCmd.Flags().StringArrayVar(&pushArtifactArgs.variants, "variant", nil,
+		"Variant in the format 'name=path' where path is a Kustomize overlay, the overlays are built separately and pushed as a bundle with one layer per variant, "+
+			"can be specified multiple times. A variant is selected with the URL fragment e.g. 'oci://docker.io/user/repo:v1.0.0#production'.")
+	pushArtifactCmd.Flags().BoolVar(&pushArtifactArgs.sbom, "sbom", false,
+		"Attach an SPDX SBOM listing the container images referenced by the Kubernetes manifests to the pushed artifact.")
+	pushArtifactCmd.Flags().BoolVar(&pushArtifactArgs.attest, "attest", false,
+		"Attach an in-toto attestation describing the build inputs (kustomize path, source and revision) to the pushed artifact.")
 	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.source, "source", "", "the source address, e.g. the Git URL")
 	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.revision, "revision", "", "the source revision in the format '<branch|tag>/<commit-sha>'")
+	pushArtifactCmd.Flags().StringArrayVar(&pushArtifactArgs.httpHeaders, "http-header", nil,
+		"HTTP header in the format 'key: value' added to the requests for manifests hosted at HTTP(S) URLs.")
+	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.httpToken, "http-token", "",
+		"Bearer token used to authenticate the requests for manifests hosted at HTTP(S) URLs.")
+	pushArtifactCmd.Flags().StringSliceVar(&pushArtifactArgs.httpChecksums, "http-checksum", nil,
+		"SHA-256 checksum of the manifests hosted at HTTP(S) URLs, specified in the same order as the URLs.")
 
 	pushCmd.AddCommand(pushArtifactCmd)
 }
@@ -107,8 +189,14 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("you must specify an artifact name e.g. 'oci://docker.io/user/repo:tag'")
 	}
 
-	if pushArtifactArgs.kustomize == "" && len(pushArtifactArgs.filename) == 0 {
-		return fmt.Errorf("-f or -k is required")
+	bundleMode := len(pushArtifactArgs.variants) > 0
+	switch {
+	case bundleMode && (pushArtifactArgs.kustomize != "" || len(pushArtifactArgs.filename) > 0):
+		return fmt.Errorf("--variant can't be used with -f or -k")
+	case bundleMode && (pushArtifactArgs.splitLayers || pushArtifactArgs.mode == registry.SourceMode):
+		return fmt.Errorf("--variant can't be used with --split-layers or --mode %s", registry.SourceMode)
+	case !bundleMode && pushArtifactArgs.kustomize == "" && len(pushArtifactArgs.filename) == 0:
+		return fmt.Errorf("-f, -k or --variant is required")
 	}
 
 	url, err := registry.ParseURL(args[0])
@@ -116,16 +204,57 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	sourceMode := pushArtifactArgs.mode == registry.SourceMode
+	switch {
+	case pushArtifactArgs.mode != "" && pushArtifactArgs.mode != registry.ManifestsMode && !sourceMode:
+		return fmt.Errorf("unsupported mode '%s', can be '%s' or '%s'", pushArtifactArgs.mode, registry.ManifestsMode, registry.SourceMode)
+	case sourceMode && (pushArtifactArgs.kustomize == "" || len(pushArtifactArgs.filename) > 0 || len(pushArtifactArgs.patch) > 0):
+		return fmt.Errorf("--mode %s requires -k and can't be used with -f or --patch", registry.SourceMode)
+	case sourceMode && (pushArtifactArgs.splitLayers || pushArtifactArgs.ageRecipients != "" || len(pushArtifactArgs.encryptTo) > 0):
+		return fmt.Errorf("--mode %s can't be used with --split-layers or age encryption", registry.SourceMode)
+	}
+
+	annotations, err := parseKeyValuePairs(pushArtifactArgs.annotations)
+	if err != nil {
+		return fmt.Errorf("invalid annotation: %w", err)
+	}
+	if err := registry.ValidateAnnotations(annotations); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	logger.Println("building manifests...")
-	objects, _, err := buildManifests(ctx, pushArtifactArgs.kustomize, pushArtifactArgs.filename, nil, pushArtifactArgs.patch, nil)
+	fetcher, err := newHTTPFetcher(pushArtifactArgs.filename, pushArtifactArgs.httpHeaders, pushArtifactArgs.httpToken, pushArtifactArgs.httpChecksums)
 	if err != nil {
 		return err
 	}
 
-	sort.Sort(ssa.SortableUnstructureds(objects))
+	logger.Println("building manifests...")
+	var objects []*unstructured.Unstructured
+	var contents []registry.Content
+	switch {
+	case bundleMode:
+		objects, contents, err = buildVariantLayers(ctx, pushArtifactArgs.variants, pushArtifactArgs.patch, fetcher)
+		if err != nil {
+			return err
+		}
+	case pushArtifactArgs.splitLayers:
+		if len(pushArtifactArgs.patch) > 0 {
+			return fmt.Errorf("--split-layers can't be used with --patch")
+		}
+		objects, contents, err = buildSourceLayers(ctx, pushArtifactArgs.kustomize, pushArtifactArgs.filename, fetcher)
+		if err != nil {
+			return err
+		}
+	default:
+		objects, _, err = buildManifests(ctx, pushArtifactArgs.kustomize, pushArtifactArgs.filename, nil, pushArtifactArgs.patch, nil, fetcher)
+		if err != nil {
+			return err
+		}
+
+		sort.Sort(ssa.SortableUnstructureds(objects))
+	}
 
 	for _, object := range objects {
 		rootCmd.Println(ssa.FmtUnstructured(object))
@@ -135,48 +264,109 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if len(contents) > 0 {
+		yml = registry.JoinContents(contents)
+	}
+
+	var cosign string
+	if pushArtifactArgs.sign {
+		cosign, err = exec.LookPath("cosign")
+		if err != nil {
+			return fmt.Errorf("cosign not found in path $PATH: %w", err)
+		}
+	}
 
 	recipients, err := registry.ParseAgeRecipients(pushArtifactArgs.ageRecipients)
 	if err != nil {
 		return fmt.Errorf("faild to read encryption keys: %w", err)
 	}
 
+	recipientKeys, err := registry.ParseAgeRecipientKeys(pushArtifactArgs.encryptTo)
+	if err != nil {
+		return err
+	}
+	recipients = append(recipients, recipientKeys...)
+
 	if len(recipients) > 0 {
 		logger.Println("pushing encrypted image", url)
 	} else {
 		logger.Println("pushing image", url)
 	}
 
-	digest, err := registry.Push(ctx, url, []byte(yml), &registry.Metadata{
+	meta := &registry.Metadata{
 		Version:        VERSION,
 		Checksum:       fmt.Sprintf("%x", sha256.Sum256([]byte(yml))),
 		Created:        time.Now().UTC().Format(time.RFC3339),
 		SourceURL:      pushArtifactArgs.source,
 		SourceRevision: pushArtifactArgs.revision,
-	}, recipients)
+		Annotations:    annotations,
+	}
+	if bundleMode {
+		meta.Mode = registry.BundleMode
+	}
+
+	var digest string
+	switch {
+	case sourceMode:
+		digest, err = registry.PushSource(ctx, registryOpts, url, pushArtifactArgs.kustomize, meta)
+	case len(contents) > 0:
+		digest, err = registry.PushLayers(ctx, registryOpts, url, contents, meta, recipients)
+	default:
+		digest, err = registry.Push(ctx, registryOpts, url, []byte(yml), meta, recipients)
+	}
 	if err != nil {
 		return fmt.Errorf("pushing image failed: %w", err)
 	}
 
 	logger.Println("published digest", digest)
 
-	// adapted from https://github.com/containerd/nerdctl
-	if pushArtifactArgs.sign {
-		cosign, err := exec.LookPath("cosign")
+	if pushArtifactArgs.sbom {
+		sbom, err := newSBOM(digest, objects)
 		if err != nil {
-			return fmt.Errorf("cosign not found in path $PATH: %w", err)
+			return fmt.Errorf("generating SBOM failed: %w", err)
+		}
+		sbomDigest, err := registry.Attach(ctx, registryOpts, digest, registry.SBOMArtifactType, sbom, nil)
+		if err != nil {
+			return fmt.Errorf("attaching SBOM failed: %w", err)
 		}
+		logger.Println("attached SBOM", sbomDigest)
+	}
+
+	if pushArtifactArgs.attest {
+		statement, err := newBuildStatement(digest, pushArtifactArgs.kustomize, pushArtifactArgs.filename,
+			pushArtifactArgs.patch, pushArtifactArgs.source, pushArtifactArgs.revision)
+		if err != nil {
+			return fmt.Errorf("generating attestation failed: %w", err)
+		}
+		attDigest, err := registry.Attach(ctx, registryOpts, digest, registry.AttestationArtifactType, statement, nil)
+		if err != nil {
+			return fmt.Errorf("attaching attestation failed: %w", err)
+		}
+		logger.Println("attached attestation", attDigest)
+	}
 
+	// adapted from https://github.com/containerd/nerdctl
+	if pushArtifactArgs.sign {
 		cosignCmd := exec.Command(cosign, []string{"sign"}...)
 		cosignCmd.Env = os.Environ()
 
 		if pushArtifactArgs.signKey != "" {
 			cosignCmd.Args = append(cosignCmd.Args, "--key", pushArtifactArgs.signKey)
 		} else {
+			// keyless signing runs unattended in CI, skip the confirmation prompt for uploading to Rekor
 			cosignCmd.Env = append(cosignCmd.Env, "COSIGN_EXPERIMENTAL=true")
+			cosignCmd.Args = append(cosignCmd.Args, "--yes")
+			if pushArtifactArgs.identityToken != "" {
+				cosignCmd.Args = append(cosignCmd.Args, "--identity-token", pushArtifactArgs.identityToken)
+			}
+		}
+
+		if registryOpts.IsInsecure(digest) {
+			cosignCmd.Args = append(cosignCmd.Args, "--allow-insecure-registry")
 		}
 
-		cosignCmd.Args = append(cosignCmd.Args, url)
+		// sign the pushed digest instead of the tag, as the tag could be moved to another image in the meantime
+		cosignCmd.Args = append(cosignCmd.Args, digest)
 		stdout, _ := cosignCmd.StdoutPipe()
 		stderr, _ := cosignCmd.StderrPipe()
 		if err := cosignCmd.Start(); err != nil {
@@ -194,9 +384,108 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 		}
 
 		if err := cosignCmd.Wait(); err != nil {
-			return err
+			return fmt.Errorf("cosign sign failed: %w", err)
 		}
+		logger.Println("signed digest", digest)
 	}
 
 	return nil
 }
+
+// buildSourceLayers builds the manifests of each input path separately and returns
+// the layers contents named after the paths, along with all the objects.
+func buildSourceLayers(ctx context.Context, kustomizePath string, filePaths []string, fetcher fetch.Fetcher) ([]*unstructured.Unstructured, []registry.Content, error) {
+	var objects []*unstructured.Unstructured
+	var contents []registry.Content
+	names := make(map[string]int)
+
+	addLayer := func(source string, objs []*unstructured.Unstructured) error {
+		sort.Sort(ssa.SortableUnstructureds(objs))
+		yml, err := ssa.ObjectsToYAML(objs)
+		if err != nil {
+			return err
+		}
+
+		name := layerTitle(source)
+		names[name]++
+		if n := names[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+
+		contents = append(contents, registry.Content{Name: name, Data: []byte(yml)})
+		objects = append(objects, objs...)
+		return nil
+	}
+
+	for _, filePath := range filePaths {
+		objs, _, err := buildManifests(ctx, "", []string{filePath}, nil, nil, nil, fetcher)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := addLayer(filePath, objs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if kustomizePath != "" {
+		objs, _, err := buildManifests(ctx, kustomizePath, nil, nil, nil, nil, fetcher)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := addLayer(kustomizePath, objs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return objects, contents, nil
+}
+
+// buildVariantLayers builds the Kustomize overlay of each variant in the format 'name=path'
+// and returns the layers contents named after the variants, along with all the objects.
+func buildVariantLayers(ctx context.Context, variants []string, patchPaths []string, fetcher fetch.Fetcher) ([]*unstructured.Unstructured, []registry.Content, error) {
+	paths, err := parseKeyValuePairs(variants)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid variant: %w", err)
+	}
+	if len(paths) != len(variants) {
+		return nil, nil, fmt.Errorf("invalid variant: names must be unique")
+	}
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, nil, fmt.Errorf("invalid variant name '%s': %s", name, strings.Join(errs, "; "))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var objects []*unstructured.Unstructured
+	var contents []registry.Content
+	for _, name := range names {
+		objs, _, err := buildManifests(ctx, paths[name], nil, nil, patchPaths, nil, fetcher)
+		if err != nil {
+			return nil, nil, fmt.Errorf("building variant '%s' failed: %w", name, err)
+		}
+
+		sort.Sort(ssa.SortableUnstructureds(objs))
+		yml, err := ssa.ObjectsToYAML(objs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		contents = append(contents, registry.Content{Name: name, Data: []byte(yml)})
+		objects = append(objects, objs...)
+	}
+
+	return objects, contents, nil
+}
+
+// layerTitle returns the base name of the path or URL without the file extension.
+func layerTitle(source string) string {
+	if source == stdinPath {
+		return "stdin"
+	}
+	base := path.Base(strings.TrimSuffix(filepath.ToSlash(source), "/"))
+	return strings.TrimSuffix(base, path.Ext(base))
+}
@@ -18,9 +18,12 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/gomega"
+
+	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
 
 func TestPush(t *testing.T) {
@@ -46,4 +49,231 @@ func TestPush(t *testing.T) {
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp(id))
 	})
+
+	t.Run("push artifact with explicit credentials", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --creds user:pass",
+			artifact,
+			dir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --creds user:pass --creds-registry registry.example.com",
+			artifact,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(id))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --creds user:pass --registry-token token",
+			artifact,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("mutually exclusive"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --creds user",
+			artifact,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("username:password"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --provider ibm",
+			artifact,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unsupported provider"))
+	})
+
+	t.Run("push artifact to insecure registry", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --insecure-registry %s",
+			artifact,
+			dir,
+			registryHost,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --insecure-registry %s",
+			artifact,
+			registryHost,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(id))
+	})
+
+	t.Run("push artifact with invalid registry TLS config", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --registry-cert-file %s",
+			artifact,
+			dir,
+			filepath.Join(dir, "config.yaml"),
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("certificate and key must be specified together"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --registry-ca-file %s",
+			artifact,
+			dir,
+			filepath.Join(dir, "config.yaml"),
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("no certificates found"))
+	})
+
+	t.Run("push artifact with split layers", func(t *testing.T) {
+		crdsDir, err := makeTestDir(id+"-crds", []TestFile{
+			{
+				Name: "crds.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "%[1]s-crds"
+  namespace: "%[1]s"
+`, id),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		artifactLayers := fmt.Sprintf("oci://%s/%s:v4.0.0", registryHost, id)
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -f %s -k %s --split-layers",
+			artifactLayers,
+			crdsDir,
+			dir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s",
+			artifactLayers,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(id + "-crds"))
+		g.Expect(output).To(ContainSubstring("kind: CronJob"))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --layer %s",
+			artifactLayers,
+			filepath.Base(crdsDir),
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(id + "-crds"))
+		g.Expect(output).NotTo(ContainSubstring("kind: CronJob"))
+	})
+
+	t.Run("push bundle artifact with variants", func(t *testing.T) {
+		prodDir, err := makeTestDir(id+"-prod", testManifests(id+"-prod", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		artifactBundle := fmt.Sprintf("oci://%s/%s:v5.0.0", registryHost, id)
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s --variant dev=%s --variant prod=%s",
+			artifactBundle,
+			dir,
+			prodDir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		_, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s",
+			artifactBundle,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("bundle"))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s#prod",
+			artifactBundle,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(id + "-prod"))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"apply inventory %s -a %s#prod -n %s",
+			id+"-bundle",
+			artifactBundle,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s-prod created", id, id)))
+	})
+
+	t.Run("push artifact with annotations", func(t *testing.T) {
+		artifactV3 := fmt.Sprintf("oci://%s/%s:v3.0.0", registryHost, id)
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --annotation org.opencontainers.image.licenses=Apache-2.0",
+			artifactV3,
+			dir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect artifact %s",
+			artifactV3,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("org.opencontainers.image.licenses: Apache-2.0"))
+
+		_, err = executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --annotation %s=override",
+			artifactV3,
+			dir,
+			registry.ChecksumAnnotation,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("push artifact with SBOM and attestation", func(t *testing.T) {
+		artifactV2 := fmt.Sprintf("oci://%s/%s:v2.0.0", registryHost, id)
+		output, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --sbom --attest --source https://github.com/org/repo --revision main/%s",
+			artifactV2,
+			dir,
+			"0123456789abcdef0123456789abcdef01234567",
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect artifact %s",
+			artifactV2,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(ContainSubstring("Referrers:"))
+		g.Expect(output).To(ContainSubstring(registry.SBOMArtifactType))
+		g.Expect(output).To(ContainSubstring(registry.AttestationArtifactType))
+	})
 }
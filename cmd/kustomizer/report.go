@@ -0,0 +1,121 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+)
+
+// reportActions is the order of the actions in the report summary table.
+var reportActions = []string{
+	string(ssa.CreatedAction),
+	string(ssa.ConfiguredAction),
+	string(ssa.DeletedAction),
+	string(ssa.UnchangedAction),
+	unknownAction,
+}
+
+// writeMarkdownReport writes the entries as a Markdown document with a summary table
+// and a collapsible section for each object, suitable for posting as a pull request comment.
+func writeMarkdownReport(w io.Writer, entries []changeSetEntry, counts map[string]int) error {
+	var sb strings.Builder
+	sb.WriteString("### Change set\n\n")
+	sb.WriteString("| Action | Objects |\n| --- | --- |\n")
+	for _, action := range reportActions {
+		if counts[action] > 0 {
+			sb.WriteString(fmt.Sprintf("| %s | %d |\n", action, counts[action]))
+		}
+	}
+	sb.WriteString("\n")
+
+	for _, entry := range entries {
+		details, lang, err := reportDetails(entry)
+		if err != nil {
+			return err
+		}
+
+		if details == "" {
+			sb.WriteString(fmt.Sprintf("- `%s` %s\n", entry.Subject, entry.Action))
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("<details><summary><code>%s</code> %s</summary>\n\n",
+			html.EscapeString(entry.Subject), entry.Action))
+		sb.WriteString(fmt.Sprintf("```%s\n%s\n```\n\n</details>\n", lang, details))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeHTMLReport writes the entries as an HTML document with a summary table
+// and a collapsible section for each object.
+func writeHTMLReport(w io.Writer, entries []changeSetEntry, counts map[string]int) error {
+	var sb strings.Builder
+	sb.WriteString("<h3>Change set</h3>\n")
+	sb.WriteString("<table>\n<tr><th>Action</th><th>Objects</th></tr>\n")
+	for _, action := range reportActions {
+		if counts[action] > 0 {
+			sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td></tr>\n", action, counts[action]))
+		}
+	}
+	sb.WriteString("</table>\n")
+
+	for _, entry := range entries {
+		details, _, err := reportDetails(entry)
+		if err != nil {
+			return err
+		}
+
+		subject := fmt.Sprintf("<code>%s</code> %s", html.EscapeString(entry.Subject), html.EscapeString(entry.Action))
+		if details == "" {
+			sb.WriteString(fmt.Sprintf("<p>%s</p>\n", subject))
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("<details><summary>%s</summary>\n<pre>%s</pre>\n</details>\n",
+			subject, html.EscapeString(details)))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// reportDetails returns the diff, the JSON patch or the changed fields of the given entry
+// together with the language used for syntax highlighting.
+func reportDetails(entry changeSetEntry) (string, string, error) {
+	switch {
+	case entry.Diff != "":
+		return entry.Diff, "diff", nil
+	case len(entry.Patch) > 0:
+		data, err := json.MarshalIndent(entry.Patch, "", "  ")
+		if err != nil {
+			return "", "", err
+		}
+		return string(data), "json", nil
+	case len(entry.Paths) > 0:
+		return strings.Join(entry.Paths, "\n"), "", nil
+	default:
+		return "", "", nil
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// applyProgress records the objects applied from a build, so that a failed apply
+// can be resumed without reapplying the objects that were applied before the failure.
+// The progress is stored in the user cache dir and is removed after a successful apply.
+type applyProgress struct {
+	// Checksum is the SHA256 sum of the applied objects.
+	Checksum string `json:"checksum"`
+
+	// Applied holds the IDs of the applied objects in the format 'kind/namespace/name'.
+	Applied []string `json:"applied"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// loadApplyProgress returns the progress of the inventory with the given name and namespace.
+// When resume is false or when the recorded progress belongs to a different build,
+// the returned progress is empty.
+func loadApplyProgress(name, namespace string, objects []*unstructured.Unstructured, resume bool) (*applyProgress, error) {
+	yml, err := ssa.ObjectsToYAML(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("locating the cache dir failed, error: %w", err)
+	}
+
+	p := &applyProgress{
+		Checksum: fmt.Sprintf("%x", sha256.Sum256([]byte(yml))),
+		path:     filepath.Join(cacheDir, "kustomizer", "progress", progressFileName(name, namespace)),
+	}
+
+	if !resume {
+		return p, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, fmt.Errorf("reading apply progress failed, error: %w", err)
+	}
+
+	var recorded applyProgress
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("parsing apply progress from %s failed, error: %w", p.path, err)
+	}
+
+	if recorded.Checksum != p.Checksum {
+		logger.Println("the build changed since the failed apply, applying all objects")
+		return p, nil
+	}
+
+	p.Applied = recorded.Applied
+	return p, nil
+}
+
+// progressFileName returns the name of the progress file of the given inventory, keyed by
+// the cluster API server and the kubeconfig context, so that the progress recorded for
+// an inventory on one cluster is never resumed on another cluster.
+func progressFileName(name, namespace string) string {
+	var server, kubeContext string
+	if restConfig, err := kubeconfigArgs.ToRESTConfig(); err == nil {
+		server = restConfig.Host
+	}
+	if kubeconfigArgs.Context != nil && *kubeconfigArgs.Context != "" {
+		kubeContext = *kubeconfigArgs.Context
+	} else if rawConfig, err := kubeconfigArgs.ToRawKubeConfigLoader().RawConfig(); err == nil {
+		kubeContext = rawConfig.CurrentContext
+	}
+
+	key := sha256.Sum256([]byte(strings.Join([]string{server, kubeContext}, "|")))
+	return fmt.Sprintf("%s-%s-%x.json", namespace, name, key[:8])
+}
+
+// Pending returns the objects that were not applied.
+func (p *applyProgress) Pending(objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	applied := make(map[string]bool, len(p.Applied))
+	for _, id := range p.Applied {
+		applied[id] = true
+	}
+
+	var result []*unstructured.Unstructured
+	for _, object := range objects {
+		if applied[ssa.FmtUnstructured(object)] {
+			logger.Println(ssa.FmtUnstructured(object), "skipped (applied before the failure)")
+			continue
+		}
+		result = append(result, object)
+	}
+	return result
+}
+
+// Record adds the change set entries to the progress and writes it to disk.
+func (p *applyProgress) Record(changeSet *ssa.ChangeSet) error {
+	if changeSet == nil || len(changeSet.Entries) == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, entry := range changeSet.Entries {
+		p.Applied = append(p.Applied, entry.Subject)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.path, data, 0o600)
+}
+
+// Clear removes the recorded progress from disk.
+func (p *applyProgress) Clear() error {
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// retryOptions holds the settings for retrying failed API calls with exponential backoff.
+type retryOptions struct {
+	// Retries is the maximum number of retries, zero disables retrying.
+	Retries int
+
+	// Interval is the initial wait between retries, doubled after each attempt.
+	Interval time.Duration
+}
+
+// retryOnError runs fn until it succeeds, it returns a non-retriable error or the retries are exhausted.
+func retryOnError(ctx context.Context, opts retryOptions, fn func() error) error {
+	backoff := wait.Backoff{
+		Duration: opts.Interval,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    opts.Retries,
+	}
+
+	for {
+		err := fn()
+		if err == nil || backoff.Steps < 1 || !isRetriableError(err) {
+			return err
+		}
+
+		delay := backoff.Step()
+		logger.Println("retrying in", delay.Round(time.Millisecond), "after error:", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isRetriableError returns true for API throttling, server errors and webhook connection failures.
+func isRetriableError(err error) bool {
+	return apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		strings.Contains(err.Error(), "connection refused")
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Rollback re-applies the objects recorded in a previous inventory revision and prunes the objects added since.",
+	Example: `  kustomizer rollback -i <name> -n <namespace> --to-revision <number>
+
+  # List the revisions of an inventory and rollback to revision 3 using its stored manifests or OCI artifacts
+  kustomizer history -i my-app -n apps
+  kustomizer rollback -i my-app -n apps --to-revision 3
+
+  # Rollback to revision 3 without waiting for the objects to become ready
+  kustomizer rollback -i my-app -n apps --to-revision 3 --wait=false
+
+  # Rollback to a revision applied from encrypted OCI artifacts
+  kustomizer rollback -i my-app -n apps --to-revision 3 --age-identities ./keys/id.txt
+`,
+	RunE: runRollbackCmd,
+}
+
+type rollbackFlags struct {
+	inventory     string
+	toRevision    int
+	wait          bool
+	ageIdentities string
+}
+
+var rollbackArgs rollbackFlags
+
+func init() {
+	rollbackCmd.Flags().StringVarP(&rollbackArgs.inventory, "inventory", "i", "",
+		"The name of the inventory.")
+	rollbackCmd.Flags().IntVar(&rollbackArgs.toRevision, "to-revision", 0,
+		"The number of the revision to rollback to, as listed by 'kustomizer history'.")
+	rollbackCmd.Flags().BoolVar(&rollbackArgs.wait, "wait", true,
+		"Wait for the applied Kubernetes objects to become ready and for the pruned objects to be terminated.")
+	rollbackCmd.Flags().StringVar(&rollbackArgs.ageIdentities, "age-identities", "",
+		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func runRollbackCmd(cmd *cobra.Command, args []string) error {
+	if rollbackArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+	if rollbackArgs.toRevision < 1 {
+		return fmt.Errorf("you must specify a revision number with --to-revision")
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(rollbackArgs.inventory, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	rev, err := i.GetRevision(rollbackArgs.toRevision)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case rev.StoredManifests:
+		manifests, err := invStorage.LoadManifests(ctx, i, rev.Number)
+		if err != nil {
+			return err
+		}
+
+		tmpDir, err := os.MkdirTemp("", "rollback")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		manifestsPath := filepath.Join(tmpDir, "manifests.yaml")
+		if err := os.WriteFile(manifestsPath, []byte(manifests), 0600); err != nil {
+			return err
+		}
+
+		applyInventoryArgs.filename = []string{manifestsPath}
+		applyInventoryArgs.storeManifests = true
+	case len(rev.Artifacts) > 0:
+		var artifacts []string
+		for _, artifact := range rev.Artifacts {
+			artifacts = append(artifacts, "oci://"+artifact)
+		}
+		applyInventoryArgs.artifact = artifacts
+	default:
+		return fmt.Errorf("revision %d can't be restored, it was not applied from OCI artifacts or with --store-manifests", rev.Number)
+	}
+
+	logger.Println(fmt.Sprintf("rolling back inventory %s/%s to revision %d", i.Namespace, i.Name, rev.Number))
+
+	applyInventoryArgs.source = rev.Source
+	applyInventoryArgs.revision = rev.Revision
+	applyInventoryArgs.derivedFrom = i.DerivedFrom
+	applyInventoryArgs.ageIdentities = rollbackArgs.ageIdentities
+	applyInventoryArgs.prune = true
+	applyInventoryArgs.wait = rollbackArgs.wait
+
+	return applyInventory(i.Name)
+}
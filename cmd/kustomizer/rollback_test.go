@@ -0,0 +1,262 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRollback(t *testing.T) {
+	g := NewWithT(t)
+	id := "rollback-" + randStringRunes(5)
+	artifact := fmt.Sprintf("oci://%s/%s", registryHost, id)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies two revisions", func(t *testing.T) {
+		for i, version := range []string{"v1", "v2"} {
+			dir, err := makeTestDir(id, rollbackTestManifests(id, version, i > 0))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			output, err := executeCommand(fmt.Sprintf(
+				"push artifact %s:%s -f %s",
+				artifact,
+				version,
+				dir,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+
+			output, err = executeCommand(fmt.Sprintf(
+				"apply inv %s -n %s -a %s:%s --revision %s",
+				id,
+				id,
+				artifact,
+				version,
+				version,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("restores the first revision", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"rollback -i %s -n %s --to-revision 1",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKeyWithValue("version", "v1"))
+
+		configMap.Name = "extra"
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("records the rollback as a new revision", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"history -i %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?m)^3\s.*\sv1\s`))
+	})
+
+	t.Run("fails for unknown revisions", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"rollback -i %s -n %s --to-revision 10",
+			id,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestRollbackBundle(t *testing.T) {
+	g := NewWithT(t)
+	id := "rollback-" + randStringRunes(5)
+	artifact := fmt.Sprintf("oci://%s/%s", registryHost, id)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies two revisions of a bundle variant", func(t *testing.T) {
+		for i, version := range []string{"v1", "v2"} {
+			var variants []string
+			for _, variant := range []string{"dev", "prod"} {
+				files := append(rollbackTestManifests(id, variant+"-"+version, i > 0), TestFile{
+					Name: "kustomization.yaml",
+					Body: "resources:\n- configs.yaml\n",
+				})
+				dir, err := makeTestDir(id+"-"+variant, files)
+				g.Expect(err).NotTo(HaveOccurred())
+				variants = append(variants, fmt.Sprintf("--variant %s=%s", variant, dir))
+			}
+
+			output, err := executeCommand(fmt.Sprintf(
+				"push artifact %s:%s %s",
+				artifact,
+				version,
+				strings.Join(variants, " "),
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+
+			output, err = executeCommand(fmt.Sprintf(
+				"apply inv %s -n %s -a %s:%s#prod --revision %s",
+				id,
+				id,
+				artifact,
+				version,
+				version,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("restores the variant of the first revision", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"rollback -i %s -n %s --to-revision 1",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKeyWithValue("version", "prod-v1"))
+	})
+}
+
+func TestRollbackStoredManifests(t *testing.T) {
+	g := NewWithT(t)
+	id := "rollback-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies two revisions with stored manifests", func(t *testing.T) {
+		for i, version := range []string{"v1", "v2"} {
+			dir, err := makeTestDir(id, rollbackTestManifests(id, version, i > 0))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			output, err := executeCommand(fmt.Sprintf(
+				"apply inv %s -n %s -f %s --store-manifests",
+				id,
+				id,
+				dir,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("exports the stored manifests", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s --export",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp("version: v2"))
+		g.Expect(output).To(MatchRegexp("name: extra"))
+	})
+
+	t.Run("restores the first revision from the stored manifests", func(t *testing.T) {
+		err := os.RemoveAll(filepath.Join(tmpDir, id))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"rollback -i %s -n %s --to-revision 1",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKeyWithValue("version", "v1"))
+	})
+}
+
+func rollbackTestManifests(id, version string, extra bool) []TestFile {
+	body := fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: "%[1]s"
+data:
+  version: "%[2]s"
+`, id, version)
+	if extra {
+		body += fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: extra
+  namespace: "%[1]s"
+`, id)
+	}
+	return []TestFile{{Name: "configs.yaml", Body: body}}
+}
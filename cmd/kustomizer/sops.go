@@ -0,0 +1,95 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// sopsMetadataField is the top-level field where SOPS records the encryption metadata.
+const sopsMetadataField = "sops"
+
+// isSOPSEncrypted returns true if the object contains the SOPS encryption metadata.
+func isSOPSEncrypted(object *unstructured.Unstructured) bool {
+	_, ok := object.Object[sopsMetadataField]
+	return ok
+}
+
+// checkSOPSEncrypted returns an error listing the SOPS encrypted objects, if any.
+func checkSOPSEncrypted(objects []*unstructured.Unstructured) error {
+	var encrypted []string
+	for _, object := range objects {
+		if isSOPSEncrypted(object) {
+			encrypted = append(encrypted, ssa.FmtUnstructured(object))
+		}
+	}
+	if len(encrypted) > 0 {
+		return fmt.Errorf("SOPS encrypted objects found, use --sops to decrypt them: %s", strings.Join(encrypted, ", "))
+	}
+	return nil
+}
+
+// decryptSOPS replaces the SOPS encrypted objects with their plain text version, the objects
+// are decrypted by the sops binary using the local age, PGP or cloud KMS keys.
+func decryptSOPS(ctx context.Context, objects []*unstructured.Unstructured) error {
+	sops := ""
+	for i, object := range objects {
+		if !isSOPSEncrypted(object) {
+			continue
+		}
+
+		if sops == "" {
+			var err error
+			sops, err = exec.LookPath("sops")
+			if err != nil {
+				return fmt.Errorf("sops not found in path $PATH: %w", err)
+			}
+		}
+
+		data, err := yaml.Marshal(object.Object)
+		if err != nil {
+			return err
+		}
+
+		// kustomize transformations e.g. namespace and common labels alter the unencrypted fields
+		// which invalidates the MAC, the encrypted values are still authenticated by AES-GCM
+		cmd := exec.CommandContext(ctx, sops, "--decrypt", "--ignore-mac",
+			"--input-type", "yaml", "--output-type", "yaml", "/dev/stdin")
+		cmd.Stdin = bytes.NewReader(data)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("decrypting %s failed, %s %w", ssa.FmtUnstructured(object), strings.TrimSpace(stderr.String()), err)
+		}
+
+		decrypted := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(out, &decrypted.Object); err != nil {
+			return fmt.Errorf("decoding %s failed: %w", ssa.FmtUnstructured(object), err)
+		}
+		objects[i] = decrypted
+	}
+	return nil
+}
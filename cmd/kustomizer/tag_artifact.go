@@ -29,7 +29,7 @@ var tagArtifactCmd = &cobra.Command{
 	Use:   "artifact",
 	Short: "Tag adds a tag for the specified OCI artifact.",
 	Long: `The tag command tags an existing artifact on the remote container registry.
-This command uses the credentials from '~/.docker/config.json'.`,
+This command uses the credentials from '~/.docker/config.json', or the credentials specified with '--creds' or '--registry-token'.`,
 	Example: `  kustomizer tag artifact <oci url> <tag>
 
   # Tag an OCI artifact as latest
@@ -57,7 +57,7 @@ func runTagArtifactCmd(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	res, err := registry.Tag(ctx, url, tag)
+	res, err := registry.Tag(ctx, registryOpts, url, tag)
 	if err != nil {
 		return fmt.Errorf("tagging %s failed: %w", url, err)
 	}
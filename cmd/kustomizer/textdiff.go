@@ -0,0 +1,78 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"golang.org/x/term"
+)
+
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+	colorReset = "\033[0m"
+)
+
+// unifiedDiff returns the line diff between the two texts in the unified format without the file headers,
+// the changed lines are surrounded by the given number of context lines.
+// When color is enabled, the removed lines are red, the added lines green and the hunk headers cyan.
+func unifiedDiff(a, b string, context int, color bool) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:       difflib.SplitLines(a),
+		B:       difflib.SplitLines(b),
+		Context: context,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(diff, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		if color {
+			line = colorize(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func colorize(line string) string {
+	switch {
+	case strings.HasPrefix(line, "@@"):
+		return colorCyan + line + colorReset
+	case strings.HasPrefix(line, "+"):
+		return colorGreen + line + colorReset
+	case strings.HasPrefix(line, "-"):
+		return colorRed + line + colorReset
+	default:
+		return line
+	}
+}
+
+// useColor returns true if stdout is a terminal, and colors were not disabled
+// with the --no-color flag or the NO_COLOR environment variable.
+func useColor(noColor bool) bool {
+	_, ok := os.LookupEnv("NO_COLOR")
+	return !noColor && !ok && term.IsTerminal(int(os.Stdout.Fd()))
+}
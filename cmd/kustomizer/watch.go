@@ -0,0 +1,163 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/stefanprodan/kustomizer/pkg/fetch"
+)
+
+// watchDebounce is the interval used to group the file system events triggered by a single change.
+const watchDebounce = 500 * time.Millisecond
+
+// watchApplyInventory applies the inventory, then it reapplies it every time the local files change
+// when --watch is set, and at every interval when --interval is set, until the process is interrupted.
+func watchApplyInventory(name string) error {
+	if applyInventoryArgs.dryRun || applyInventoryArgs.confirm {
+		return fmt.Errorf("--watch and --interval can't be used with --dry-run or --confirm")
+	}
+	for _, filename := range applyInventoryArgs.filename {
+		if filename == stdinPath {
+			return fmt.Errorf("--watch and --interval can't be used when reading the manifests from stdin")
+		}
+	}
+
+	var watcher *fsnotify.Watcher
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if applyInventoryArgs.watch {
+		var err error
+		watcher, err = newInventoryWatcher()
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	var ticks <-chan time.Time
+	if applyInventoryArgs.interval > 0 {
+		ticker := time.NewTicker(applyInventoryArgs.interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := applyInventory(name); err != nil {
+		logger.Println(`✗`, err)
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-events:
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchPath(watcher, event.Name); err != nil {
+						logger.Println(`✗`, err)
+					}
+				}
+			}
+			debounce = time.After(watchDebounce)
+		case err := <-errs:
+			logger.Println(`✗`, err)
+		case <-debounce:
+			debounce = nil
+			logger.Println("changes detected, reapplying...")
+			if err := applyInventory(name); err != nil {
+				logger.Println(`✗`, err)
+			}
+		case <-ticks:
+			logger.Println("reconciling...")
+			if err := applyInventory(name); err != nil {
+				logger.Println(`✗`, err)
+			}
+		}
+	}
+}
+
+// newInventoryWatcher returns a file watcher for the local paths specified with -k and -f.
+func newInventoryWatcher() (*fsnotify.Watcher, error) {
+	if applyInventoryArgs.kustomize == "" && len(applyInventoryArgs.filename) == 0 {
+		return nil, fmt.Errorf("--watch requires -k or -f")
+	}
+	if applyInventoryArgs.gitURL != "" {
+		return nil, fmt.Errorf("--watch can't be used with --git-url")
+	}
+
+	var paths []string
+	if applyInventoryArgs.kustomize != "" {
+		paths = append(paths, applyInventoryArgs.kustomize)
+	}
+	for _, filename := range applyInventoryArgs.filename {
+		if !fetch.IsURL(filename) {
+			paths = append(paths, filename)
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("file watcher init failed: %w", err)
+	}
+
+	for _, path := range paths {
+		if err := watchPath(watcher, path); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	return watcher, nil
+}
+
+// watchPath adds the given directory tree to the watcher, for files the parent directory is watched
+// to keep track of the editors that replace the files on save.
+func watchPath(watcher *fsnotify.Watcher, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return watcher.Add(filepath.Dir(path))
+	}
+
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if err := watcher.Add(p); err != nil {
+				return fmt.Errorf("watching %s failed: %w", p, err)
+			}
+		}
+		return nil
+	})
+}
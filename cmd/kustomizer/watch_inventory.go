@@ -0,0 +1,207 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/drift"
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch periodically compares the in-cluster objects with the last applied revision of an inventory and prints the drifted objects.",
+	Long: `The watch command compares the in-cluster objects with the manifests recorded with 'apply inventory --store-manifests'
+using server-side dry-run apply. For the inventories without stored manifests, only the deleted objects are detected.`,
+	Example: `  kustomizer watch -i <name> -n <namespace>
+
+  # Check the objects of an inventory for drift every minute
+  kustomizer watch -i my-app -n apps --interval 1m
+
+  # Check the objects of an inventory for drift and exit with an error as soon as drift is detected
+  kustomizer watch -i my-app -n apps --interval 5m --exit-on-drift
+
+  # Check the objects of an inventory for drift and ignore the changes to the replicas field
+  kustomizer watch -i my-app -n apps --ignore-paths /spec/replicas
+`,
+	RunE: runWatchCmd,
+}
+
+type watchFlags struct {
+	inventory   string
+	interval    time.Duration
+	exitOnDrift bool
+	ignorePaths []string
+	concurrency int
+}
+
+var watchArgs watchFlags
+
+func init() {
+	watchCmd.Flags().StringVarP(&watchArgs.inventory, "inventory", "i", "",
+		"The name of the inventory.")
+	watchCmd.Flags().DurationVar(&watchArgs.interval, "interval", time.Minute,
+		"The interval at which the in-cluster objects are checked for drift.")
+	watchCmd.Flags().BoolVar(&watchArgs.exitOnDrift, "exit-on-drift", false,
+		"Exit with an error as soon as drift is detected.")
+	watchCmd.Flags().StringSliceVar(&watchArgs.ignorePaths, "ignore-paths", nil,
+		"List of JSON pointers e.g. '/spec/replicas' to fields excluded from drift detection.")
+	watchCmd.Flags().IntVar(&watchArgs.concurrency, "concurrency", 4,
+		"The number of objects diffed in parallel.")
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+func runWatchCmd(cmd *cobra.Command, args []string) error {
+	if watchArgs.inventory == "" {
+		return fmt.Errorf("you must specify an inventory name with --inventory")
+	}
+	if watchArgs.interval <= 0 {
+		return fmt.Errorf("the interval must be greater than zero")
+	}
+
+	ignorePaths, err := drift.ParsePaths(watchArgs.ignorePaths)
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage := &inventory.Storage{
+		Manager: resMgr,
+		Owner:   inventoryOwner,
+		Kind:    rootArgs.inventoryKind,
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ticker := time.NewTicker(watchArgs.interval)
+	defer ticker.Stop()
+
+	i := inventory.NewInventory(watchArgs.inventory, *kubeconfigArgs.Namespace)
+	logger.Println(fmt.Sprintf("watching inventory %s/%s for drift every %s", i.Namespace, i.Name, watchArgs.interval))
+	for {
+		drifted, err := checkInventoryDrift(ctx, resMgr, invStorage, i.Name, i.Namespace, ignorePaths)
+		switch {
+		case err != nil:
+			logger.Println(`✗`, err)
+		case drifted > 0 && watchArgs.exitOnDrift:
+			return fmt.Errorf("drift detected in %d object(s)", drifted)
+		case drifted == 0:
+			logger.Println("no drift detected")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkInventoryDrift prints a drift event for each object of the given inventory that was modified
+// or deleted since the last apply, and returns the number of drifted objects.
+func checkInventoryDrift(parentCtx context.Context, resMgr *ssa.ResourceManager, invStorage *inventory.Storage,
+	name, namespace string, ignorePaths []drift.Path) (int, error) {
+	ctx, cancel := context.WithTimeout(parentCtx, rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(name, namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return 0, fmt.Errorf("inventory query failed, error: %w", err)
+	}
+
+	rev := i.CurrentRevision()
+	if rev == nil || !rev.StoredManifests {
+		return checkMissingObjects(ctx, resMgr, i)
+	}
+
+	manifests, err := invStorage.LoadManifests(ctx, i, rev.Number)
+	if err != nil {
+		return 0, err
+	}
+	objects, err := ssa.ReadObjects(strings.NewReader(manifests))
+	if err != nil {
+		return 0, fmt.Errorf("extracting the manifests of revision %d failed: %w", rev.Number, err)
+	}
+
+	drifted := 0
+	for _, result := range diffObjects(ctx, resMgr, objects, inventoryOwner.Field, ignorePaths, watchArgs.concurrency) {
+		if result.err != nil {
+			return drifted, result.err
+		}
+		switch result.change.Action {
+		case string(ssa.CreatedAction):
+			printDriftEvent(result.change.Subject, "deleted")
+			drifted++
+		case string(ssa.ConfiguredAction):
+			printDriftEvent(result.change.Subject, "drifted")
+			drifted++
+		}
+	}
+	return drifted, nil
+}
+
+// checkMissingObjects prints a drift event for each object of the given inventory that was deleted from the cluster.
+func checkMissingObjects(ctx context.Context, resMgr *ssa.ResourceManager, i *inventory.Inventory) (int, error) {
+	objects, err := i.ListObjects()
+	if err != nil {
+		return 0, err
+	}
+
+	drifted := 0
+	for _, object := range objects {
+		existingObject := &unstructured.Unstructured{}
+		existingObject.SetGroupVersionKind(object.GroupVersionKind())
+		if err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return drifted, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(object), err)
+			}
+			printDriftEvent(ssa.FmtUnstructured(object), "deleted")
+			drifted++
+		}
+	}
+	return drifted, nil
+}
+
+func printDriftEvent(subject, action string) {
+	rootCmd.Println(time.Now().UTC().Format(time.RFC3339), `►`, subject, action)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestWatchDrift(t *testing.T) {
+	g := NewWithT(t)
+	id := "watch-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --store-manifests",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+	})
+
+	t.Run("exits on drift", func(t *testing.T) {
+		cm := &corev1.ConfigMap{}
+		err := envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		cm.Data["key"] = "drifted"
+		err = envTestClient.Update(context.Background(), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"watch -i %s -n %s --exit-on-drift",
+			id,
+			id,
+		))
+
+		t.Logf("\n%s", output)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("drift detected in 1 object(s)"))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s drifted", id, id)))
+	})
+}
@@ -9,6 +9,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"path/filepath"
 	"sigs.k8s.io/yaml"
+
+	"github.com/stefanprodan/kustomizer/pkg/drift"
 )
 
 const (
@@ -27,6 +29,31 @@ type Config struct {
 
 	// FieldManager holds the manager name and group used for server-side apply.
 	FieldManager *FieldManager `json:"fieldManager,omitempty"`
+
+	// Masking holds the rules for redacting sensitive fields in diffs.
+	Masking []MaskingRule `json:"masking,omitempty"`
+
+	// Mirrors holds the registries from where the artifacts are pulled instead of their origin.
+	Mirrors []Mirror `json:"mirrors,omitempty"`
+}
+
+// Mirror redirects the pulls of artifacts from a registry or repository to another location.
+type Mirror struct {
+	// Source is the registry host or repository prefix e.g. 'ghcr.io' or 'ghcr.io/org'.
+	Source string `json:"source"`
+
+	// Mirror is the location that replaces the source e.g. 'registry.internal/ghcr'.
+	Mirror string `json:"mirror"`
+}
+
+// MaskingRule holds the fields that are redacted in the diffs of the matching objects.
+type MaskingRule struct {
+	// Kind of the objects e.g. 'ProviderConfig', can contain shell file name patterns.
+	Kind string `json:"kind"`
+
+	// Paths is the list of JSON pointers to the redacted fields e.g. '/spec/credentials',
+	// a '*' path segment matches any map key or list index.
+	Paths []string `json:"paths"`
 }
 
 type FieldManager struct {
@@ -125,6 +152,21 @@ func Read(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("the filed manager group can't be empty")
 	}
 
+	for _, rule := range cfg.Masking {
+		if rule.Kind == "" {
+			return nil, fmt.Errorf("the masking rule kind can't be empty")
+		}
+		if _, err := drift.ParsePaths(rule.Paths); err != nil {
+			return nil, fmt.Errorf("invalid masking rule for kind %s, error: %w", rule.Kind, err)
+		}
+	}
+
+	for _, mirror := range cfg.Mirrors {
+		if mirror.Source == "" || mirror.Mirror == "" {
+			return nil, fmt.Errorf("the mirror source and location can't be empty")
+		}
+	}
+
 	return cfg, nil
 }
 
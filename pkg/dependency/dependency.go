@@ -0,0 +1,241 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dependency contains utilities for ordering Kubernetes objects based on their declared dependencies.
+package dependency
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DependsOnAnnotation holds a comma separated list of object references
+// in the format '[<kind>/]<namespace>/<name>', for cluster-scoped objects the namespace is omitted.
+const DependsOnAnnotation = "kustomizer.dev/depends-on"
+
+// Batch is a group of objects that can be applied together.
+type Batch struct {
+	// Objects is the list of objects in this batch.
+	Objects []*unstructured.Unstructured
+
+	// Dependencies is the subset of objects that other
+	// objects from the subsequent batches depend on.
+	Dependencies []*unstructured.Unstructured
+}
+
+// ObjectRef is the identity of an object targeted by depends-on references.
+type ObjectRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// String returns the reference in the format '<kind>/<namespace>/<name>'.
+func (r ObjectRef) String() string {
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// RefOf returns the reference of the given object.
+func RefOf(object *unstructured.Unstructured) ObjectRef {
+	return ObjectRef{
+		Kind:      object.GetKind(),
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+	}
+}
+
+// Sort groups the given objects in batches using their depends-on annotation,
+// the objects in a batch depend only on objects from the previous batches.
+// The objects order is preserved inside each batch and references
+// to objects outside the given set are ignored, use Validate to reject them.
+func Sort(objects []*unstructured.Unstructured) ([]Batch, error) {
+	deps := make([][]int, len(objects))
+	for i, object := range objects {
+		for _, ref := range references(object) {
+			deps[i] = append(deps[i], findObjects(objects, ref)...)
+		}
+	}
+
+	level := make([]int, len(objects))
+	for i := range level {
+		level[i] = -1
+	}
+
+	var batches []Batch
+	for placed := 0; placed < len(objects); {
+		var current []int
+		for i := range objects {
+			if level[i] >= 0 {
+				continue
+			}
+			ready := true
+			for _, d := range deps[i] {
+				if level[d] < 0 {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				current = append(current, i)
+			}
+		}
+
+		if len(current) == 0 {
+			var pending []string
+			for i, object := range objects {
+				if level[i] < 0 {
+					pending = append(pending, ssa.FmtUnstructured(object))
+				}
+			}
+			return nil, fmt.Errorf("circular dependency detected between %s", strings.Join(pending, ", "))
+		}
+
+		batch := Batch{}
+		for _, i := range current {
+			level[i] = len(batches)
+			batch.Objects = append(batch.Objects, objects[i])
+		}
+		batches = append(batches, batch)
+		placed += len(current)
+	}
+
+	for i := range objects {
+		for _, d := range deps[i] {
+			batch := &batches[level[d]]
+			if !contains(batch.Dependencies, objects[d]) {
+				batch.Dependencies = append(batch.Dependencies, objects[d])
+			}
+		}
+	}
+
+	return batches, nil
+}
+
+// Validate returns an error listing the depends-on references of the given objects
+// that match neither one of these objects nor one of the applied objects.
+func Validate(objects, applied []*unstructured.Unstructured) error {
+	var missing []string
+	for _, object := range objects {
+		for _, ref := range references(object) {
+			if len(findObjects(objects, ref)) == 0 && len(findObjects(applied, ref)) == 0 {
+				missing = append(missing, fmt.Sprintf("%s (required by %s)", ref, ssa.FmtUnstructured(object)))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("depends-on references not found in the applied objects: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Rewrite updates the depends-on references of the given objects after they were moved or renamed,
+// the moved map holds the previous reference of each object and its current one.
+// The references are matched the same way as by Sort and are rewritten in the '<kind>/<namespace>/<name>' format,
+// the references that don't match any of the previous identities are left untouched.
+func Rewrite(objects []*unstructured.Unstructured, moved map[ObjectRef]ObjectRef) {
+	previous := make([]ObjectRef, 0, len(moved))
+	for ref := range moved {
+		previous = append(previous, ref)
+	}
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[i].String() < previous[j].String()
+	})
+
+	for _, object := range objects {
+		refs := references(object)
+		if len(refs) == 0 {
+			continue
+		}
+
+		var result []string
+		for _, ref := range refs {
+			kind, namespace, name := parseRef(ref)
+			var targets []string
+			for _, prev := range previous {
+				if prev.Name == name && prev.Namespace == namespace && (kind == "" || prev.Kind == kind) {
+					targets = append(targets, moved[prev].String())
+				}
+			}
+			if len(targets) == 0 {
+				targets = append(targets, ref)
+			}
+			result = append(result, targets...)
+		}
+
+		annotations := object.GetAnnotations()
+		annotations[DependsOnAnnotation] = strings.Join(result, ",")
+		object.SetAnnotations(annotations)
+	}
+}
+
+// references returns the non-empty depends-on references of the given object.
+func references(object *unstructured.Unstructured) []string {
+	refs, ok := object.GetAnnotations()[DependsOnAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var result []string
+	for _, ref := range strings.Split(refs, ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+func parseRef(ref string) (kind, namespace, name string) {
+	parts := strings.Split(ref, "/")
+	switch len(parts) {
+	case 1:
+		name = parts[0]
+	case 2:
+		namespace, name = parts[0], parts[1]
+	default:
+		kind, namespace, name = parts[0], parts[1], strings.Join(parts[2:], "/")
+	}
+	return kind, namespace, name
+}
+
+func findObjects(objects []*unstructured.Unstructured, ref string) []int {
+	kind, namespace, name := parseRef(ref)
+
+	var matches []int
+	for i, object := range objects {
+		if object.GetName() != name || object.GetNamespace() != namespace {
+			continue
+		}
+		if kind != "" && object.GetKind() != kind {
+			continue
+		}
+		matches = append(matches, i)
+	}
+	return matches
+}
+
+func contains(objects []*unstructured.Unstructured, object *unstructured.Unstructured) bool {
+	for _, o := range objects {
+		if o == object {
+			return true
+		}
+	}
+	return false
+}
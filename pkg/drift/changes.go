@@ -0,0 +1,109 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FieldChange holds the in-cluster and the desired value of a changed field.
+type FieldChange struct {
+	// Path is the JSON pointer to the changed field e.g. '/spec/replicas'.
+	Path string `json:"path"`
+
+	// Old is the in-cluster value, nil if the field is added.
+	Old interface{} `json:"old,omitempty"`
+
+	// New is the desired value, nil if the field is removed.
+	New interface{} `json:"new,omitempty"`
+}
+
+// Changes returns the fields that differ between the existing and the desired object,
+// the lists of different lengths are compared item by item.
+func Changes(existingObject, desiredObject *unstructured.Unstructured) []FieldChange {
+	var changes []FieldChange
+	compareFields("", existingObject.Object, desiredObject.Object, true, true, &changes)
+	return changes
+}
+
+func compareFields(path string, existing, desired interface{}, existingOk, desiredOk bool, changes *[]FieldChange) {
+	if existingOk && desiredOk && apiequality.Semantic.DeepEqual(existing, desired) {
+		return
+	}
+
+	existingMap, existingIsMap := existing.(map[string]interface{})
+	desiredMap, desiredIsMap := desired.(map[string]interface{})
+	if existingIsMap && desiredIsMap {
+		keys := make([]string, 0, len(existingMap)+len(desiredMap))
+		for key := range existingMap {
+			keys = append(keys, key)
+		}
+		for key := range desiredMap {
+			if _, ok := existingMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			existingValue, existingOk := existingMap[key]
+			desiredValue, desiredOk := desiredMap[key]
+			compareFields(path+"/"+escapeToken(key), existingValue, desiredValue, existingOk, desiredOk, changes)
+		}
+		return
+	}
+
+	existingList, existingIsList := existing.([]interface{})
+	desiredList, desiredIsList := desired.([]interface{})
+	if existingIsList && desiredIsList {
+		size := len(existingList)
+		if len(desiredList) > size {
+			size = len(desiredList)
+		}
+		for i := 0; i < size; i++ {
+			var existingValue, desiredValue interface{}
+			existingOk, desiredOk := i < len(existingList), i < len(desiredList)
+			if existingOk {
+				existingValue = existingList[i]
+			}
+			if desiredOk {
+				desiredValue = desiredList[i]
+			}
+			compareFields(path+"/"+strconv.Itoa(i), existingValue, desiredValue, existingOk, desiredOk, changes)
+		}
+		return
+	}
+
+	change := FieldChange{Path: path}
+	if existingOk {
+		change.Old = existing
+	}
+	if desiredOk {
+		change.New = desired
+	}
+	*changes = append(*changes, change)
+}
+
+// escapeToken encodes the '~' and '/' characters of a JSON pointer token as '~0' and '~1'.
+func escapeToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
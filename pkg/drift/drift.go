@@ -0,0 +1,207 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package drift contains utilities for excluding fields from the drift detection of Kubernetes objects.
+package drift
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Path is a field path parsed from a JSON pointer e.g. '/spec/replicas'.
+type Path []string
+
+// ParsePaths returns the field paths for the given JSON pointers (RFC 6901),
+// the '~1' and '~0' sequences are decoded to '/' and '~'.
+func ParsePaths(pointers []string) ([]Path, error) {
+	paths := make([]Path, 0, len(pointers))
+	for _, pointer := range pointers {
+		if !strings.HasPrefix(pointer, "/") || len(pointer) < 2 {
+			return nil, fmt.Errorf("invalid path '%s', must be a JSON pointer e.g. '/spec/replicas'", pointer)
+		}
+
+		var path Path
+		for _, token := range strings.Split(pointer[1:], "/") {
+			token = strings.ReplaceAll(token, "~1", "/")
+			token = strings.ReplaceAll(token, "~0", "~")
+			path = append(path, token)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Remove returns a copy of the given object without the fields at the given paths.
+func Remove(object *unstructured.Unstructured, paths []Path) *unstructured.Unstructured {
+	o := object.DeepCopy()
+	for _, path := range paths {
+		removeField(o.Object, path)
+	}
+	return o
+}
+
+// HasDrifted returns true if the existing object is semantically different from the desired one,
+// without taking into account the fields at the given paths and the server-side generated metadata.
+func HasDrifted(existingObject, desiredObject *unstructured.Unstructured, paths []Path) bool {
+	existing := Remove(existingObject, paths)
+	desired := Remove(desiredObject, paths)
+
+	if !apiequality.Semantic.DeepEqual(existing.GetLabels(), desired.GetLabels()) {
+		return true
+	}
+
+	if !apiequality.Semantic.DeepEqual(existing.GetAnnotations(), desired.GetAnnotations()) {
+		return true
+	}
+
+	for _, o := range []*unstructured.Unstructured{existing, desired} {
+		unstructured.RemoveNestedField(o.Object, "metadata")
+		unstructured.RemoveNestedField(o.Object, "status")
+	}
+
+	return !apiequality.Semantic.DeepEqual(existing.Object, desired.Object)
+}
+
+func removeField(obj interface{}, path Path) {
+	if len(path) == 0 {
+		return
+	}
+
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(o, path[0])
+			return
+		}
+		removeField(o[path[0]], path[1:])
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(o) {
+			return
+		}
+		if len(path) == 1 {
+			// keep the other items at their index
+			o[i] = nil
+			return
+		}
+		removeField(o[i], path[1:])
+	}
+}
+
+// Mask returns copies of the given objects with the values at the given paths replaced by asterisks.
+// When the values differ, the masked values are suffixed with '(before)' and '(after)',
+// so that the diff shows that a sensitive field changed without revealing its value.
+// A '*' path segment matches any map key or list index.
+func Mask(existingObject, desiredObject *unstructured.Unstructured, paths []Path) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	existing, desired := existingObject.DeepCopy(), desiredObject.DeepCopy()
+	for _, path := range paths {
+		maskField(existing.Object, desired.Object, path)
+	}
+	return existing, desired
+}
+
+const mask = "*****"
+
+func maskField(existing, desired interface{}, path Path) {
+	if len(path) == 0 {
+		return
+	}
+
+	existingMap, existingIsMap := existing.(map[string]interface{})
+	desiredMap, desiredIsMap := desired.(map[string]interface{})
+	if existingIsMap || desiredIsMap {
+		for _, key := range matchKeys(existingMap, desiredMap, path[0]) {
+			existingValue, existingOk := existingMap[key]
+			desiredValue, desiredOk := desiredMap[key]
+			if len(path) > 1 {
+				maskField(existingValue, desiredValue, path[1:])
+				continue
+			}
+
+			existingMasked, desiredMasked := maskValues(existingValue, desiredValue, existingOk, desiredOk)
+			if existingOk {
+				existingMap[key] = existingMasked
+			}
+			if desiredOk {
+				desiredMap[key] = desiredMasked
+			}
+		}
+		return
+	}
+
+	existingList, _ := existing.([]interface{})
+	desiredList, _ := desired.([]interface{})
+	size := len(existingList)
+	if len(desiredList) > size {
+		size = len(desiredList)
+	}
+	for i := 0; i < size; i++ {
+		if path[0] != "*" && path[0] != strconv.Itoa(i) {
+			continue
+		}
+
+		var existingValue, desiredValue interface{}
+		existingOk, desiredOk := i < len(existingList), i < len(desiredList)
+		if existingOk {
+			existingValue = existingList[i]
+		}
+		if desiredOk {
+			desiredValue = desiredList[i]
+		}
+
+		if len(path) > 1 {
+			maskField(existingValue, desiredValue, path[1:])
+			continue
+		}
+
+		existingMasked, desiredMasked := maskValues(existingValue, desiredValue, existingOk, desiredOk)
+		if existingOk {
+			existingList[i] = existingMasked
+		}
+		if desiredOk {
+			desiredList[i] = desiredMasked
+		}
+	}
+}
+
+func matchKeys(existing, desired map[string]interface{}, token string) []string {
+	if token != "*" {
+		return []string{token}
+	}
+
+	var keys []string
+	for key := range existing {
+		keys = append(keys, key)
+	}
+	for key := range desired {
+		if _, ok := existing[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func maskValues(existing, desired interface{}, existingOk, desiredOk bool) (interface{}, interface{}) {
+	if existingOk && desiredOk && !apiequality.Semantic.DeepEqual(existing, desired) {
+		return mask + " (before)", mask + " (after)"
+	}
+	return mask, mask
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"bytes"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// OwnedFields returns the fields owned by the given field manager, as recorded in the managed fields
+// of the existing object, merged with the fields set in the desired object.
+func OwnedFields(existingObject, desiredObject *unstructured.Unstructured, manager string) (*fieldpath.Set, error) {
+	fields := fieldpath.SetFromValue(value.NewValueInterface(desiredObject.Object))
+	for _, entry := range existingObject.GetManagedFields() {
+		if entry.Manager != manager || entry.FieldsV1 == nil {
+			continue
+		}
+
+		managed := &fieldpath.Set{}
+		if err := managed.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+			return nil, fmt.Errorf("failed to parse the managed fields of %s, error: %w", existingObject.GetName(), err)
+		}
+		fields = fields.Union(managed)
+	}
+	return fields, nil
+}
+
+// Prune returns a copy of the given object that contains only the given fields,
+// a nil field set selects all the fields.
+func Prune(object *unstructured.Unstructured, fields *fieldpath.Set) *unstructured.Unstructured {
+	if fields == nil {
+		return object.DeepCopy()
+	}
+
+	pruned, _ := pruneFields(object.DeepCopy().Object, fields).(map[string]interface{})
+	return &unstructured.Unstructured{Object: pruned}
+}
+
+func pruneFields(obj interface{}, fields *fieldpath.Set) interface{} {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for key, item := range o {
+			k := key
+			if pruned, ok := pruneItem(item, fieldpath.PathElement{FieldName: &k}, fields); ok {
+				result[key] = pruned
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(o))
+		for i, item := range o {
+			for _, pe := range listPathElements(i, item, fields) {
+				if pruned, ok := pruneItem(item, pe, fields); ok {
+					result = append(result, pruned)
+					break
+				}
+			}
+		}
+		return result
+	default:
+		return obj
+	}
+}
+
+// pruneItem returns the parts of the item that are in the field set, the members
+// that have no owned children are returned as they are.
+func pruneItem(item interface{}, pe fieldpath.PathElement, fields *fieldpath.Set) (interface{}, bool) {
+	if children, ok := fields.Children.Get(pe); ok {
+		return pruneFields(item, children), true
+	}
+	if fields.Members.Has(pe) {
+		return item, true
+	}
+	return nil, false
+}
+
+// listPathElements returns the path elements of the field set that select the given list item.
+func listPathElements(index int, item interface{}, fields *fieldpath.Set) []fieldpath.PathElement {
+	var result []fieldpath.PathElement
+	match := func(pe fieldpath.PathElement) {
+		switch {
+		case pe.Index != nil:
+			if *pe.Index == index {
+				result = append(result, pe)
+			}
+		case pe.Value != nil:
+			if value.Equals(*pe.Value, value.NewValueInterface(item)) {
+				result = append(result, pe)
+			}
+		case pe.Key != nil:
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return
+			}
+			for _, field := range *pe.Key {
+				v, ok := m[field.Name]
+				if !ok || !value.Equals(field.Value, value.NewValueInterface(v)) {
+					return
+				}
+			}
+			result = append(result, pe)
+		}
+	}
+	fields.Children.Iterate(match)
+	fields.Members.Iterate(match)
+	return result
+}
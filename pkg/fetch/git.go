@@ -0,0 +1,69 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CloneGit performs a shallow clone of the repository at the given ref (branch, tag or commit SHA)
+// into dir and returns the SHA of the checked out commit.
+// When ref is empty, the remote default branch is cloned.
+func CloneGit(ctx context.Context, url, ref, dir string) (string, error) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return "", fmt.Errorf("git not found in path $PATH: %w", err)
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	// the url and ref are passed after '--' so that they can't be parsed as git options
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "--", "origin", url},
+		{"fetch", "--quiet", "--depth", "1", "--", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	}
+
+	for _, args := range steps {
+		if _, err := runGit(ctx, git, dir, args...); err != nil {
+			return "", err
+		}
+	}
+
+	sha, err := runGit(ctx, git, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return sha, nil
+}
+
+func runGit(ctx context.Context, git, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, git, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed, %s %w", args[0], strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
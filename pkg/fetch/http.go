@@ -0,0 +1,114 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fetch contains utilities for downloading Kubernetes manifests from remote locations.
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxSize is the maximum size in bytes of the downloaded content
+// used when HTTPFetcher.MaxSize is not set.
+const DefaultMaxSize int64 = 50 << 20
+
+// Fetcher downloads the content found at the given URL.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// HTTPFetcher downloads Kubernetes manifests from HTTP(S) URLs.
+type HTTPFetcher struct {
+	// Client is the HTTP client used for downloads,
+	// when nil http.DefaultClient is used.
+	Client *http.Client
+
+	// Headers are added to every request.
+	Headers map[string]string
+
+	// Token is sent as a bearer token in the Authorization header.
+	Token string
+
+	// Checksums maps URLs to the expected SHA-256 hex digest of their content.
+	Checksums map[string]string
+
+	// MaxSize is the maximum size in bytes of the downloaded content,
+	// when zero DefaultMaxSize is used.
+	MaxSize int64
+}
+
+// IsURL returns true if the given path is an HTTP or HTTPS URL.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// Fetch downloads the content from the given URL and verifies
+// its checksum if one was specified. The content larger than MaxSize is rejected.
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range f.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if f.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.Token)
+	}
+
+	c := f.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status %s", resp.Status)
+	}
+
+	maxSize := f.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("content exceeds the maximum size of %d bytes", maxSize)
+	}
+
+	if checksum, ok := f.Checksums[url]; ok {
+		if sum := fmt.Sprintf("%x", sha256.Sum256(data)); sum != strings.TrimPrefix(checksum, "sha256:") {
+			return nil, fmt.Errorf("checksum mismatch, expected %s got %s", checksum, sum)
+		}
+	}
+
+	return data, nil
+}
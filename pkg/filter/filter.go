@@ -0,0 +1,123 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package filter contains utilities for selecting Kubernetes objects by kind, name and namespace.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Filter matches Kubernetes objects by kind, name and namespace.
+// The values can contain shell file name patterns e.g. 'web*', empty values match any object.
+type Filter struct {
+	// Kind of the object e.g. 'Deployment'.
+	Kind string
+
+	// Name of the object.
+	Name string
+
+	// Namespace of the object.
+	Namespace string
+}
+
+// Parse returns a filter from an expression in the format 'kind=<kind>,name=<name>,namespace=<namespace>'.
+func Parse(expr string) (Filter, error) {
+	var f Filter
+	for _, pair := range strings.Split(expr, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return f, fmt.Errorf("invalid filter '%s', must be in the format 'key=value'", pair)
+		}
+
+		value := strings.TrimSpace(kv[1])
+		if value == "" {
+			return f, fmt.Errorf("invalid filter '%s', must be in the format 'key=value'", pair)
+		}
+		if _, err := path.Match(value, ""); err != nil {
+			return f, fmt.Errorf("invalid filter pattern '%s': %w", value, err)
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "kind":
+			f.Kind = value
+		case "name":
+			f.Name = value
+		case "namespace":
+			f.Namespace = value
+		default:
+			return f, fmt.Errorf("invalid filter key '%s', can be kind, name or namespace", kv[0])
+		}
+	}
+	return f, nil
+}
+
+// ParseAll returns the filters for the given expressions.
+func ParseAll(exprs []string) ([]Filter, error) {
+	filters := make([]Filter, 0, len(exprs))
+	for _, expr := range exprs {
+		f, err := Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// Matches returns true if the object matches all the non-empty fields of this filter.
+func (f Filter) Matches(object *unstructured.Unstructured) bool {
+	return match(f.Kind, object.GetKind()) &&
+		match(f.Name, object.GetName()) &&
+		match(f.Namespace, object.GetNamespace())
+}
+
+// Objects returns the objects that match at least one of the include filters
+// and none of the exclude filters. When no include filters are specified, all objects are included.
+func Objects(objects []*unstructured.Unstructured, includes, excludes []Filter) []*unstructured.Unstructured {
+	var result []*unstructured.Unstructured
+	for _, object := range objects {
+		if len(includes) > 0 && !matchAny(includes, object) {
+			continue
+		}
+		if matchAny(excludes, object) {
+			continue
+		}
+		result = append(result, object)
+	}
+	return result
+}
+
+func matchAny(filters []Filter, object *unstructured.Unstructured) bool {
+	for _, f := range filters {
+		if f.Matches(object) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
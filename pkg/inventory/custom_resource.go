@@ -0,0 +1,285 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CustomResourceDefinition is the manifest of the Inventory CRD (inventories.kustomizer.dev).
+//
+//go:embed kustomizer.dev_inventories.yaml
+var CustomResourceDefinition string
+
+const (
+	customResourceAPIVersion = "kustomizer.dev/v1"
+	customResourceKind       = "Inventory"
+)
+
+// customResourceStorage records the inventories in Inventory custom resources.
+type customResourceStorage struct {
+	manager *ssa.ResourceManager
+	owner   ssa.Owner
+}
+
+// Store creates or updates the Inventory custom resource,
+// the CRD is installed on the cluster if not present.
+func (s *customResourceStorage) Store(ctx context.Context, i *Inventory) error {
+	if err := s.installCustomResourceDefinition(ctx); err != nil {
+		return err
+	}
+
+	resources := make([]interface{}, 0, len(i.Resources))
+	for _, entry := range i.Resources {
+		resource := map[string]interface{}{
+			"id":  entry.ObjectID,
+			"ver": entry.ObjectVersion,
+		}
+		if entry.AppliedAt != "" {
+			resource["appliedAt"] = entry.AppliedAt
+		}
+		if entry.Action != "" {
+			resource["action"] = entry.Action
+		}
+		if entry.Generation > 0 {
+			resource["generation"] = entry.Generation
+		}
+		resources = append(resources, resource)
+	}
+
+	spec := map[string]interface{}{
+		"resources": resources,
+	}
+	if i.Source != "" {
+		spec["source"] = i.Source
+	}
+	if i.Revision != "" {
+		spec["revision"] = i.Revision
+	}
+	if len(i.Artifacts) > 0 {
+		artifacts := make([]interface{}, 0, len(i.Artifacts))
+		for _, artifact := range i.Artifacts {
+			artifacts = append(artifacts, artifact)
+		}
+		spec["artifacts"] = artifacts
+	}
+	if len(i.History) > 0 {
+		history, err := toUnstructuredList(i.History)
+		if err != nil {
+			return err
+		}
+		spec["history"] = history
+	}
+	if i.Provenance != nil {
+		provenance, err := toUnstructuredMap(i.Provenance)
+		if err != nil {
+			return err
+		}
+		spec["provenance"] = provenance
+	}
+
+	obj := s.newCustomResource(i.Name, i.Namespace)
+	obj.SetAnnotations(metaToAnnotations(s.owner, i))
+	if err := unstructured.SetNestedMap(obj.Object, spec, "spec"); err != nil {
+		return err
+	}
+
+	opts := []client.PatchOption{
+		client.ForceOwnership,
+		client.FieldOwner(s.owner.Field),
+	}
+	if err := s.manager.Client().Patch(ctx, obj, client.Apply, opts...); err != nil {
+		return err
+	}
+
+	now := lastAppliedTime(i)
+	status := s.newCustomResource(i.Name, i.Namespace)
+	status.Object["status"] = map[string]interface{}{
+		"lastAppliedTime": now,
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":               "Ready",
+				"status":             "True",
+				"observedGeneration": obj.GetGeneration(),
+				"lastTransitionTime": now,
+				"reason":             "ApplySucceeded",
+				"message":            fmt.Sprintf("Applied %d objects", len(i.Resources)),
+			},
+		},
+	}
+	return s.manager.Client().Status().Patch(ctx, status, client.Apply, opts...)
+}
+
+// Load retrieves the entries and the source metadata from the Inventory custom resource.
+func (s *customResourceStorage) Load(ctx context.Context, i *Inventory) error {
+	obj := s.newCustomResource(i.Name, i.Namespace)
+	if err := s.manager.Client().Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		if meta.IsNoMatchError(err) {
+			return apierrors.NewNotFound(schema.GroupResource{Group: "kustomizer.dev", Resource: "inventories"}, i.Name)
+		}
+		return err
+	}
+
+	metaFromAnnotations(s.owner, i, obj.GetAnnotations())
+	i.Source, _, _ = unstructured.NestedString(obj.Object, "spec", "source")
+	i.Revision, _, _ = unstructured.NestedString(obj.Object, "spec", "revision")
+	if lastAppliedAt, ok, _ := unstructured.NestedString(obj.Object, "status", "lastAppliedTime"); ok {
+		i.LastAppliedAt = lastAppliedAt
+	}
+
+	spec, ok := obj.Object["spec"]
+	if !ok {
+		return fmt.Errorf("inventory data not found in %s/%s/%s", customResourceKind, i.Namespace, i.Name)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	var entries struct {
+		Resources  []Resource  `json:"resources"`
+		Artifacts  []string    `json:"artifacts"`
+		History    []Revision  `json:"history"`
+		Provenance *Provenance `json:"provenance"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	i.Resources = entries.Resources
+	if i.Resources == nil {
+		i.Resources = []Resource{}
+	}
+	i.Artifacts = entries.Artifacts
+	i.History = entries.History
+	i.Provenance = entries.Provenance
+
+	return nil
+}
+
+// List returns the inventories recorded in Inventory custom resources in the given namespace.
+func (s *customResourceStorage) List(ctx context.Context, namespace string) ([]*Inventory, error) {
+	var inventories []*Inventory
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion(customResourceAPIVersion)
+	list.SetKind(customResourceKind + "List")
+	if err := s.manager.Client().List(ctx, list, client.InNamespace(namespace), ownerLabels(s.owner)); err != nil {
+		if isMissingCRDError(err) {
+			return inventories, nil
+		}
+		return inventories, err
+	}
+
+	for _, item := range list.Items {
+		i := NewInventory(item.GetName(), namespace)
+		if err := s.Load(ctx, i); err != nil {
+			return inventories, err
+		}
+		inventories = append(inventories, i)
+	}
+
+	return inventories, nil
+}
+
+// Delete removes the Inventory custom resource for the given inventory name and namespace.
+func (s *customResourceStorage) Delete(ctx context.Context, i *Inventory) error {
+	obj := s.newCustomResource(i.Name, i.Namespace)
+	err := s.manager.Client().Delete(ctx, obj)
+	if err != nil && !isMissingCRDError(err) {
+		return fmt.Errorf("failed to delete %s/%s/%s, error: %w", customResourceKind, i.Namespace, i.Name, err)
+	}
+	return nil
+}
+
+// installCustomResourceDefinition applies the Inventory CRD and waits for it to become established.
+// The CRD is always applied so that the schema installed by an older version is upgraded,
+// the server-side apply dry-run skips the update when the schema hasn't changed.
+func (s *customResourceStorage) installCustomResourceDefinition(ctx context.Context) error {
+	crd, err := ssa.ReadObject(strings.NewReader(CustomResourceDefinition))
+	if err != nil {
+		return fmt.Errorf("failed to read the inventory CRD, error: %w", err)
+	}
+
+	change, err := s.manager.Apply(ctx, crd, ssa.DefaultApplyOptions())
+	if err != nil {
+		return fmt.Errorf("failed to install the inventory CRD, error: %w", err)
+	}
+	if change.Action == string(ssa.UnchangedAction) {
+		return nil
+	}
+
+	return s.manager.Wait([]*unstructured.Unstructured{crd}, ssa.WaitOptions{
+		Interval: time.Second,
+		Timeout:  time.Minute,
+	})
+}
+
+// toUnstructuredList converts the given list to a JSON compatible slice.
+func toUnstructuredList(list interface{}) ([]interface{}, error) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// toUnstructuredMap converts the given struct to a JSON compatible map.
+func toUnstructuredMap(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *customResourceStorage) newCustomResource(name, namespace string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(customResourceAPIVersion)
+	obj.SetKind(customResourceKind)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	obj.SetLabels(map[string]string{
+		nameLabelKey:      name,
+		componentLabelKey: KindName,
+		createdByLabelKey: s.owner.Field,
+	})
+	return obj
+}
+
+// isMissingCRDError returns true if the Inventory CRD is not installed on the cluster.
+func isMissingCRDError(err error) bool {
+	return apierrors.IsNotFound(err) || meta.IsNoMatchError(err)
+}
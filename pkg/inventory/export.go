@@ -0,0 +1,75 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// ExportAPIVersion is the version of the inventory export format.
+	ExportAPIVersion = "kustomizer.dev/v1"
+
+	// ExportKind is the kind of the inventory export document.
+	ExportKind = "InventoryExport"
+)
+
+// Export is the versioned JSON representation of an inventory used for backups and migrations.
+type Export struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// Inventory is the inventory record including its history.
+	Inventory *Inventory `json:"inventory"`
+
+	// Manifests holds the manifests stored for each revision, indexed by the revision number.
+	Manifests map[int]string `json:"manifests,omitempty"`
+}
+
+// NewExport returns the export document for the given inventory.
+func NewExport(i *Inventory) *Export {
+	return &Export{
+		APIVersion: ExportAPIVersion,
+		Kind:       ExportKind,
+		Inventory:  i,
+		Manifests:  map[int]string{},
+	}
+}
+
+// ReadExport decodes the given export document and validates its version.
+func ReadExport(data []byte) (*Export, error) {
+	var e Export
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("decoding the inventory export failed, error: %w", err)
+	}
+
+	if e.Kind != ExportKind {
+		return nil, fmt.Errorf("unsupported kind '%s', must be %s", e.Kind, ExportKind)
+	}
+	if e.APIVersion != ExportAPIVersion {
+		return nil, fmt.Errorf("unsupported apiVersion '%s', must be %s", e.APIVersion, ExportAPIVersion)
+	}
+	if e.Inventory == nil || e.Inventory.Name == "" {
+		return nil, fmt.Errorf("the inventory name is missing from the export")
+	}
+	if e.Inventory.Resources == nil {
+		e.Inventory.Resources = []Resource{}
+	}
+
+	return &e, nil
+}
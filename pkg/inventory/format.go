@@ -0,0 +1,45 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+const (
+	// LegacyFormat is the storage layout of the inventories recorded by the kustomizer versions
+	// that didn't set the format annotation, these inventories lack the namespace scope
+	// and their objects are labeled with the inventory name only.
+	LegacyFormat = 1
+
+	// CurrentFormat is the storage layout written by this version.
+	CurrentFormat = 2
+)
+
+// IsLegacy returns true if the inventory was loaded from a record written in an older format.
+func (inv *Inventory) IsLegacy() bool {
+	return inv.Format > 0 && inv.Format < CurrentFormat
+}
+
+// Upgrade fills in the fields missing from the older formats and sets the format to the current version.
+func (inv *Inventory) Upgrade() error {
+	if len(inv.Namespaces) == 0 {
+		namespaces, err := inv.NamespaceScope()
+		if err != nil {
+			return err
+		}
+		inv.Namespaces = namespaces
+	}
+	inv.Format = CurrentFormat
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ID returns the inventory identifier in the format '<namespace>/<name>'.
+func (inv *Inventory) ID() string {
+	return fmt.Sprintf("%s/%s", inv.Namespace, inv.Name)
+}
+
+// DependencyIDs returns the identifiers of the inventories this inventory depends on,
+// the dependencies specified without a namespace are in the namespace of this inventory.
+func (inv *Inventory) DependencyIDs() []string {
+	ids := make([]string, 0, len(inv.DependsOn))
+	for _, dep := range inv.DependsOn {
+		if !strings.Contains(dep, "/") {
+			dep = fmt.Sprintf("%s/%s", inv.Namespace, dep)
+		}
+		ids = append(ids, dep)
+	}
+	return ids
+}
+
+// FilterByGroup returns the inventories that belong to the given group.
+func FilterByGroup(inventories []*Inventory, group string) []*Inventory {
+	var result []*Inventory
+	for _, inv := range inventories {
+		if inv.Group == group {
+			result = append(result, inv)
+		}
+	}
+	return result
+}
+
+// SortByDependencies orders the given inventories so that each inventory comes after its dependencies,
+// the dependencies not present in the list are ignored. It fails if the dependencies form a cycle.
+func SortByDependencies(inventories []*Inventory) ([]*Inventory, error) {
+	byID := make(map[string]*Inventory, len(inventories))
+	ids := make([]string, 0, len(inventories))
+	for _, inv := range inventories {
+		byID[inv.ID()] = inv
+		ids = append(ids, inv.ID())
+	}
+	sort.Strings(ids)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(inventories))
+	result := make([]*Inventory, 0, len(inventories))
+
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("circular dependency between inventories: %s", strings.Join(append(path, id), " -> "))
+		}
+
+		state[id] = visiting
+		for _, dep := range byID[id].DependencyIDs() {
+			if _, ok := byID[dep]; !ok {
+				continue
+			}
+			if err := visit(dep, append(path, id)); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		result = append(result, byID[id])
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := visit(id, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DefaultHistoryLimit is the default number of revisions kept in the inventory history.
+const DefaultHistoryLimit = 10
+
+// Revision is a snapshot of the inventory recorded at apply time.
+type Revision struct {
+	// Number is the revision number, incremented on every apply.
+	Number int `json:"number"`
+
+	// AppliedAt is the timestamp (UTC RFC3339) of the apply.
+	AppliedAt string `json:"appliedAt"`
+
+	// Source is the repository URL.
+	Source string `json:"source,omitempty"`
+
+	// Revision is the source revision identifier.
+	Revision string `json:"revision,omitempty"`
+
+	// Artifacts is the list of the OCI URLs.
+	Artifacts []string `json:"artifacts,omitempty"`
+
+	// Checksum is the SHA256 sum of the applied objects.
+	Checksum string `json:"checksum"`
+
+	// Changes is the number of objects created, configured or deleted by the apply.
+	Changes int `json:"changes"`
+
+	// Resources is the list of Kubernetes object IDs.
+	Resources []Resource `json:"resources"`
+
+	// StoredManifests is true when the applied manifests were recorded for this revision.
+	StoredManifests bool `json:"storedManifests,omitempty"`
+}
+
+// AddRevision records the current entries and source of this inventory as a new revision,
+// the oldest revisions are removed to keep at most limit revisions in the history.
+func (inv *Inventory) AddRevision(checksum string, changes int, limit int) *Revision {
+	number := 1
+	if current := inv.CurrentRevision(); current != nil {
+		number = current.Number + 1
+	}
+
+	resources := make([]Resource, len(inv.Resources))
+	copy(resources, inv.Resources)
+
+	inv.History = append(inv.History, Revision{
+		Number:    number,
+		AppliedAt: time.Now().UTC().Format(time.RFC3339),
+		Source:    inv.Source,
+		Revision:  inv.Revision,
+		Artifacts: inv.Artifacts,
+		Checksum:  checksum,
+		Changes:   changes,
+		Resources: resources,
+	})
+
+	if limit > 0 && len(inv.History) > limit {
+		inv.History = inv.History[len(inv.History)-limit:]
+	}
+
+	return &inv.History[len(inv.History)-1]
+}
+
+// ListObjects returns the entries of this revision as Kubernetes unstructured objects
+// containing only the apiVersion, kind, name and namespace.
+func (r *Revision) ListObjects() ([]*unstructured.Unstructured, error) {
+	return listObjects(r.Resources)
+}
+
+// CurrentRevision returns the latest revision from the history, nil if the history is empty.
+func (inv *Inventory) CurrentRevision() *Revision {
+	if len(inv.History) == 0 {
+		return nil
+	}
+	return &inv.History[len(inv.History)-1]
+}
+
+// GetRevision returns the revision with the given number from the history.
+func (inv *Inventory) GetRevision(number int) (*Revision, error) {
+	for i := range inv.History {
+		if inv.History[i].Number == number {
+			return &inv.History[i], nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not found in the history of inventory %s/%s", number, inv.Namespace, inv.Name)
+}
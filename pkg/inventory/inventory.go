@@ -17,14 +17,62 @@ limitations under the License.
 package inventory
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"sort"
+	"time"
 
 	"github.com/fluxcd/pkg/ssa"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/cli-utils/pkg/object"
 )
 
+const (
+	// ReconcileAnnotation set to ReconcileDisabledValue on an object skips it from
+	// apply, prune and drift detection while keeping it in the inventory.
+	ReconcileAnnotation = "kustomizer.dev/reconcile"
+
+	// ReconcileDisabledValue is the ReconcileAnnotation value that disables the reconciliation.
+	ReconcileDisabledValue = "disabled"
+
+	// InventoryLabel is set on every applied object to the inventory namespace and name in the format '<namespace>_<name>'.
+	InventoryLabel = "kustomizer.dev/inventory"
+
+	// ChecksumLabel is set on every applied object to the truncated SHA256 sum of its desired state.
+	ChecksumLabel = "kustomizer.dev/checksum"
+
+	// MetaAnnotationPrefix is the prefix of the inventory annotations that hold the Meta entries.
+	MetaAnnotationPrefix = "meta."
+)
+
+// LabelValue returns the InventoryLabel value for the given inventory name and namespace,
+// values longer than 63 characters are truncated and suffixed with a hash to keep them unique.
+func LabelValue(name, namespace string) string {
+	value := fmt.Sprintf("%s_%s", namespace, name)
+	if len(value) <= validation.LabelValueMaxLength {
+		return value
+	}
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(value)))
+	return value[:validation.LabelValueMaxLength-17] + "-" + sum[:16]
+}
+
+// ObjectChecksum returns the ChecksumLabel value for the given object,
+// the checksum is computed without the ChecksumLabel.
+func ObjectChecksum(object *unstructured.Unstructured) (string, error) {
+	o := object.DeepCopy()
+	labels := o.GetLabels()
+	delete(labels, ChecksumLabel)
+	o.SetLabels(labels)
+
+	data, err := o.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:32], nil
+}
+
 // Inventory is a record of objects that are applied on a cluster stored as a configmap.
 type Inventory struct {
 	// Name of the inventory.
@@ -39,6 +87,33 @@ type Inventory struct {
 	// Revision is the source revision identifier.
 	Revision string `json:"revision,omitempty"`
 
+	// Group is the name of the group of inventories this inventory belongs to.
+	Group string `json:"group,omitempty"`
+
+	// DependsOn is the list of inventories in the format '<namespace>/<name>' or '<name>'
+	// that must be applied before this inventory and deleted after it.
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// DerivedFrom is the source identity the inventory name was derived from,
+	// empty when the name was specified explicitly.
+	DerivedFrom string `json:"derivedFrom,omitempty"`
+
+	// Format is the version of the storage layout the inventory was recorded with,
+	// zero for the inventories that were not loaded from the storage.
+	Format int `json:"format,omitempty"`
+
+	// Namespaces is the list of namespaces this inventory is allowed to prune objects from.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Signature is the HMAC-SHA256 of the inventory entries, empty when the inventory is not signed.
+	Signature string `json:"signature,omitempty"`
+
+	// Meta holds human-readable metadata e.g. the description and the owner of this inventory.
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// TTL is the duration after the last apply when the inventory expires, zero means the inventory doesn't expire.
+	TTL time.Duration `json:"ttl,omitempty"`
+
 	// LastAppliedAt is the timestamp (UTC RFC3339) of the last successful apply.
 	LastAppliedAt string `json:"lastAppliedTime,omitempty"`
 
@@ -47,6 +122,12 @@ type Inventory struct {
 
 	// Artifacts is the list of the OCI URLs.
 	Artifacts []string `json:"artifacts"`
+
+	// History is the list of revisions recorded at apply time, ordered from the oldest to the latest.
+	History []Revision `json:"history,omitempty"`
+
+	// Provenance is the origin of the manifests applied by the last apply.
+	Provenance *Provenance `json:"provenance,omitempty"`
 }
 
 // Resource contains the information necessary to locate the Kubernetes object.
@@ -57,6 +138,15 @@ type Resource struct {
 
 	// ObjectVersion is the API version of this entry kind.
 	ObjectVersion string `json:"ver"`
+
+	// AppliedAt is the timestamp (UTC RFC3339) of the last apply that created or configured the object.
+	AppliedAt string `json:"appliedAt,omitempty"`
+
+	// Action is the action taken for the object by the last apply e.g. 'created', 'configured' or 'unchanged'.
+	Action string `json:"action,omitempty"`
+
+	// Generation is the metadata.generation of the object observed after it was last created or configured.
+	Generation int64 `json:"generation,omitempty"`
 }
 
 func NewInventory(name, namespace string) *Inventory {
@@ -93,6 +183,38 @@ func (inv *Inventory) AddObjects(objects []*unstructured.Unstructured) error {
 	return nil
 }
 
+// RemoveObjects removes the entries of the given objects from the inventory.
+func (inv *Inventory) RemoveObjects(objects []*unstructured.Unstructured) {
+	ids := make(map[string]bool, len(objects))
+	for _, om := range objects {
+		ids[object.UnstructuredToObjMetadata(om).String()] = true
+	}
+
+	resources := make([]Resource, 0, len(inv.Resources))
+	for _, entry := range inv.Resources {
+		if !ids[entry.ObjectID] {
+			resources = append(resources, entry)
+		}
+	}
+	inv.Resources = resources
+}
+
+// CopyEntryMetadata copies the apply metadata of the entries found in the given inventory.
+func (inv *Inventory) CopyEntryMetadata(source *Inventory) {
+	entries := make(map[string]Resource, len(source.Resources))
+	for _, entry := range source.Resources {
+		entries[entry.ObjectID] = entry
+	}
+
+	for i, entry := range inv.Resources {
+		if e, ok := entries[entry.ObjectID]; ok {
+			inv.Resources[i].AppliedAt = e.AppliedAt
+			inv.Resources[i].Action = e.Action
+			inv.Resources[i].Generation = e.Generation
+		}
+	}
+}
+
 // VersionOf returns the API version of the given object if found in this inventory.
 func (inv *Inventory) VersionOf(objMetadata object.ObjMetadata) string {
 	for _, entry := range inv.Resources {
@@ -105,9 +227,14 @@ func (inv *Inventory) VersionOf(objMetadata object.ObjMetadata) string {
 
 // ListObjects returns the inventory entries as unstructured.Unstructured objects.
 func (inv *Inventory) ListObjects() ([]*unstructured.Unstructured, error) {
+	return listObjects(inv.Resources)
+}
+
+// listObjects returns the given entries as Kubernetes unstructured objects.
+func listObjects(resources []Resource) ([]*unstructured.Unstructured, error) {
 	objects := make([]*unstructured.Unstructured, 0)
 
-	for _, entry := range inv.Resources {
+	for _, entry := range resources {
 		objMetadata, err := object.ParseObjMetadata(entry.ObjectID)
 		if err != nil {
 			return nil, err
@@ -175,3 +302,29 @@ func (inv *Inventory) Diff(target *Inventory) ([]*unstructured.Unstructured, err
 	sort.Sort(ssa.SortableUnstructureds(objects))
 	return objects, nil
 }
+
+// SetMeta merges the given metadata into the inventory, the keys with an empty value are removed.
+func (inv *Inventory) SetMeta(meta map[string]string) {
+	for k, v := range meta {
+		if v == "" {
+			delete(inv.Meta, k)
+			continue
+		}
+		if inv.Meta == nil {
+			inv.Meta = make(map[string]string)
+		}
+		inv.Meta[k] = v
+	}
+}
+
+// IsExpired returns true if the inventory has a TTL and the last apply is older than the TTL.
+func (inv *Inventory) IsExpired(now time.Time) bool {
+	if inv.TTL <= 0 || inv.LastAppliedAt == "" {
+		return false
+	}
+	lastApplied, err := time.Parse(time.RFC3339, inv.LastAppliedAt)
+	if err != nil {
+		return false
+	}
+	return now.After(lastApplied.Add(inv.TTL))
+}
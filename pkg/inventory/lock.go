@@ -0,0 +1,194 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// lockComponent is the component label value of the Leases used to lock the inventories.
+const lockComponent = "lock"
+
+// Lock is an exclusive lock on an inventory, backed by a coordination.k8s.io/v1 Lease.
+// The Lease is renewed in the background until the lock is released.
+// A Lock without a Lease is returned when the inventory namespace doesn't exist yet.
+type Lock struct {
+	client client.Client
+	lease  *coordinationv1.Lease
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// LockHolder returns the identity of this process used as the lock holder.
+func LockHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s_%d", hostname, os.Getpid())
+}
+
+// AcquireLock takes the lock of the given inventory for the given holder.
+// It fails if the lock is held by another holder that renewed it less than timeout ago,
+// locks older than the timeout are considered stale and are taken over.
+// The lock is renewed every third of the timeout, so that it doesn't become stale while held.
+// When the namespace doesn't exist and createNamespace is false, the locking is skipped,
+// as the namespace is expected to be created by the apply and holds no inventory yet.
+func (s *Storage) AcquireLock(ctx context.Context, i *Inventory, holder string, timeout time.Duration, createNamespace bool) (*Lock, error) {
+	if createNamespace {
+		if err := s.createNamespace(ctx, i.Namespace); err != nil {
+			return nil, err
+		}
+	} else {
+		ns := &corev1.Namespace{}
+		err := s.Manager.Client().Get(ctx, client.ObjectKey{Name: i.Namespace}, ns)
+		if apierrors.IsNotFound(err) {
+			return &Lock{}, nil
+		}
+		// the other errors e.g. forbidden are ignored, the Lease requests report them
+	}
+
+	now := metav1.NewMicroTime(time.Now())
+	duration := int32(timeout.Seconds())
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s%s-lock", storagePrefix, i.Name),
+			Namespace: i.Namespace,
+			Labels: map[string]string{
+				nameLabelKey:      i.Name,
+				componentLabelKey: lockComponent,
+				createdByLabelKey: s.Owner.Field,
+			},
+		},
+	}
+
+	err := s.Manager.Client().Get(ctx, client.ObjectKeyFromObject(lease), lease)
+	switch {
+	case apierrors.IsNotFound(err):
+		lease.Spec = coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		}
+		if err := s.Manager.Client().Create(ctx, lease); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return nil, fmt.Errorf("inventory %s/%s is locked by another apply", i.Namespace, i.Name)
+			}
+			return nil, fmt.Errorf("failed to lock inventory %s/%s, error: %w", i.Namespace, i.Name, err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to lock inventory %s/%s, error: %w", i.Namespace, i.Name, err)
+	default:
+		current := ""
+		if lease.Spec.HolderIdentity != nil {
+			current = *lease.Spec.HolderIdentity
+		}
+		if current != "" && current != holder && lease.Spec.RenewTime != nil && time.Since(lease.Spec.RenewTime.Time) < timeout {
+			return nil, fmt.Errorf("inventory %s/%s is locked by %s since %s, the lock is considered stale after %s",
+				i.Namespace, i.Name, current, lease.Spec.RenewTime.UTC().Format(time.RFC3339), timeout)
+		}
+
+		// the update fails with a conflict if another holder took over the lock in the meantime
+		lease.Spec.HolderIdentity = &holder
+		lease.Spec.LeaseDurationSeconds = &duration
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+		if err := s.Manager.Client().Update(ctx, lease); err != nil {
+			if apierrors.IsConflict(err) {
+				return nil, fmt.Errorf("inventory %s/%s is locked by another apply", i.Namespace, i.Name)
+			}
+			return nil, fmt.Errorf("failed to lock inventory %s/%s, error: %w", i.Namespace, i.Name, err)
+		}
+	}
+
+	renewCtx, cancel := context.WithCancel(context.Background())
+	lock := &Lock{
+		client: s.Manager.Client(),
+		lease:  lease,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	interval := timeout / 3
+	if interval <= 0 {
+		interval = timeout
+	}
+	go lock.renew(renewCtx, interval)
+
+	return lock, nil
+}
+
+// renew updates the renew time of the Lease at the given interval until the context is canceled,
+// it stops if the lock was taken over or removed by another holder.
+func (l *Lock) renew(ctx context.Context, interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			lease := l.lease.DeepCopy()
+			now := metav1.NewMicroTime(time.Now())
+			lease.Spec.RenewTime = &now
+			err := l.client.Update(ctx, lease)
+			if err == nil {
+				l.lease = lease
+			}
+			l.mu.Unlock()
+
+			if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+				return
+			}
+		}
+	}
+}
+
+// Release stops the renewal and removes the lock, it doesn't fail if the lock was taken over by another holder.
+func (l *Lock) Release(ctx context.Context) error {
+	if l.lease == nil {
+		return nil
+	}
+
+	l.cancel()
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.client.Delete(ctx, l.lease, client.Preconditions{ResourceVersion: &l.lease.ResourceVersion})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return fmt.Errorf("failed to release the lock %s/%s, error: %w", l.lease.Namespace, l.lease.Name, err)
+	}
+	return nil
+}
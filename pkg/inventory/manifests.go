@@ -0,0 +1,203 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// manifestsComponent is the component label value of the Secrets holding the applied manifests.
+	manifestsComponent = "manifests"
+
+	// manifestsChunkSize is the maximum size of the compressed manifests stored in a Secret,
+	// the base64 encoding of the Secret data must fit under the 1MiB limit of Kubernetes objects.
+	manifestsChunkSize = 512 * 1024
+
+	manifestsDataKey = "manifests.gz"
+)
+
+// StoreManifests records the gzip compressed manifests of the given inventory revision in Secrets.
+// The manifests are split in chunks of 512KiB stored in separate Secrets to stay under the size limit of Kubernetes objects.
+func (s *Storage) StoreManifests(ctx context.Context, i *Inventory, number int, manifests string) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(manifests)); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	data := buf.Bytes()
+	for chunk := 0; len(data) > 0; chunk++ {
+		size := manifestsChunkSize
+		if len(data) < size {
+			size = len(data)
+		}
+
+		secret := s.newManifestsSecret(i, number, chunk)
+		secret.Data = map[string][]byte{
+			manifestsDataKey: data[:size],
+		}
+
+		opts := []client.PatchOption{
+			client.ForceOwnership,
+			client.FieldOwner(s.Owner.Field),
+		}
+		if err := s.Manager.Client().Patch(ctx, secret, client.Apply, opts...); err != nil {
+			return fmt.Errorf("failed to store the manifests of revision %d, error: %w", number, err)
+		}
+
+		data = data[size:]
+	}
+
+	return nil
+}
+
+// LoadManifests returns the manifests recorded for the given inventory revision.
+func (s *Storage) LoadManifests(ctx context.Context, i *Inventory, number int) (string, error) {
+	list := &corev1.SecretList{}
+	labels := s.manifestsLabels(i)
+	labels[s.revisionLabelKey()] = strconv.Itoa(number)
+	if err := s.Manager.Client().List(ctx, list, client.InNamespace(i.Namespace), labels); err != nil {
+		return "", err
+	}
+	if len(list.Items) == 0 {
+		return "", fmt.Errorf("no manifests stored for revision %d of inventory %s/%s", number, i.Namespace, i.Name)
+	}
+
+	chunks := list.Items
+	sort.Slice(chunks, func(a, b int) bool {
+		return s.chunkIndex(chunks[a]) < s.chunkIndex(chunks[b])
+	})
+
+	var data []byte
+	for _, chunk := range chunks {
+		data = append(data, chunk.Data[manifestsDataKey]...)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress the manifests of revision %d, error: %w", number, err)
+	}
+	defer gz.Close()
+
+	manifests, err := io.ReadAll(gz)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress the manifests of revision %d, error: %w", number, err)
+	}
+	return string(manifests), nil
+}
+
+// PruneManifests removes the stored manifests of the revisions that are no longer in the inventory history.
+func (s *Storage) PruneManifests(ctx context.Context, i *Inventory) error {
+	list := &corev1.SecretList{}
+	if err := s.Manager.Client().List(ctx, list, client.InNamespace(i.Namespace), s.manifestsLabels(i)); err != nil {
+		return err
+	}
+
+	for _, item := range list.Items {
+		number, _ := strconv.Atoi(item.GetLabels()[s.revisionLabelKey()])
+		if _, err := i.GetRevision(number); err == nil {
+			continue
+		}
+		if err := s.Manager.Client().Delete(ctx, &item); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete Secret/%s/%s, error: %w", item.Namespace, item.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteManifests removes the stored manifests of all the inventory revisions, if any revision recorded them.
+// The Secrets are listed and deleted one by one, so that the deletecollection permission is not required.
+func (s *Storage) deleteManifests(ctx context.Context, i *Inventory) error {
+	stored := false
+	for _, rev := range i.History {
+		stored = stored || rev.StoredManifests
+	}
+	if !stored {
+		return nil
+	}
+
+	list := &corev1.SecretList{}
+	if err := s.Manager.Client().List(ctx, list, client.InNamespace(i.Namespace), s.manifestsLabels(i)); err != nil {
+		return err
+	}
+
+	for _, item := range list.Items {
+		if err := s.Manager.Client().Delete(ctx, &item); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete Secret/%s/%s, error: %w", item.Namespace, item.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) newManifestsSecret(i *Inventory, number, chunk int) *corev1.Secret {
+	labels := s.manifestsLabels(i)
+	labels[s.revisionLabelKey()] = strconv.Itoa(number)
+
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s%s-r%d-%d", storagePrefix, i.Name, number, chunk),
+			Namespace: i.Namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				s.chunkAnnotationKey(): strconv.Itoa(chunk),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+}
+
+func (s *Storage) manifestsLabels(i *Inventory) client.MatchingLabels {
+	return client.MatchingLabels{
+		nameLabelKey:      i.Name,
+		componentLabelKey: manifestsComponent,
+		createdByLabelKey: s.Owner.Field,
+	}
+}
+
+func (s *Storage) revisionLabelKey() string {
+	return s.Owner.Group + "/inventory-revision"
+}
+
+func (s *Storage) chunkAnnotationKey() string {
+	return s.Owner.Group + "/manifests-chunk"
+}
+
+func (s *Storage) chunkIndex(secret corev1.Secret) int {
+	index, _ := strconv.Atoi(secret.GetAnnotations()[s.chunkAnnotationKey()])
+	return index
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// objectStorage records the inventories in ConfigMaps or Secrets.
+type objectStorage struct {
+	client client.Client
+	owner  ssa.Owner
+	kind   string
+}
+
+// Store creates or updates the ConfigMap or the Secret for the given inventory.
+func (s *objectStorage) Store(ctx context.Context, i *Inventory) error {
+	resources, err := json.Marshal(i.Resources)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{
+		"resources": string(resources),
+	}
+
+	if len(i.Artifacts) > 0 {
+		artifacts, err := json.Marshal(i.Artifacts)
+		if err != nil {
+			return err
+		}
+		data["artifacts"] = string(artifacts)
+	}
+
+	if len(i.History) > 0 {
+		history, err := json.Marshal(i.History)
+		if err != nil {
+			return err
+		}
+		data["history"] = string(history)
+	}
+
+	if i.Provenance != nil {
+		provenance, err := json.Marshal(i.Provenance)
+		if err != nil {
+			return err
+		}
+		data["provenance"] = string(provenance)
+	}
+
+	obj := s.newObject(i.Name, i.Namespace, data)
+	obj.SetAnnotations(metaToAnnotations(s.owner, i))
+
+	opts := []client.PatchOption{
+		client.ForceOwnership,
+		client.FieldOwner(s.owner.Field),
+	}
+	return s.client.Patch(ctx, obj, client.Apply, opts...)
+}
+
+// Load retrieves the entries from the ConfigMap or the Secret for the given inventory name and namespace.
+func (s *objectStorage) Load(ctx context.Context, i *Inventory) error {
+	obj := s.newObject(i.Name, i.Namespace, nil)
+
+	objKey := client.ObjectKeyFromObject(obj)
+	err := s.client.Get(ctx, objKey, obj)
+	if err != nil {
+		return err
+	}
+
+	metaFromAnnotations(s.owner, i, obj.GetAnnotations())
+
+	data := objectData(obj)
+	if _, ok := data["resources"]; !ok {
+		return fmt.Errorf("inventory data not found in %s/%s", s.kindName(), objKey)
+	}
+	var entries []Resource
+	err = json.Unmarshal([]byte(data["resources"]), &entries)
+	if err != nil {
+		return err
+	}
+	i.Resources = entries
+
+	if artifacts, ok := data["artifacts"]; ok {
+		var list []string
+		err = json.Unmarshal([]byte(artifacts), &list)
+		if err != nil {
+			return err
+		}
+		i.Artifacts = list
+	}
+
+	if history, ok := data["history"]; ok {
+		var revisions []Revision
+		err = json.Unmarshal([]byte(history), &revisions)
+		if err != nil {
+			return err
+		}
+		i.History = revisions
+	}
+
+	if provenance, ok := data["provenance"]; ok {
+		i.Provenance = &Provenance{}
+		err = json.Unmarshal([]byte(provenance), i.Provenance)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List returns the inventories stored in ConfigMaps or Secrets in the given namespace.
+func (s *objectStorage) List(ctx context.Context, namespace string) ([]*Inventory, error) {
+	var inventories []*Inventory
+	var names []string
+	if s.kind == SecretStorage {
+		list := &corev1.SecretList{}
+		if err := s.client.List(ctx, list, client.InNamespace(namespace), ownerLabels(s.owner)); err != nil {
+			return inventories, err
+		}
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+	} else {
+		list := &corev1.ConfigMapList{}
+		if err := s.client.List(ctx, list, client.InNamespace(namespace), ownerLabels(s.owner)); err != nil {
+			return inventories, err
+		}
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+	}
+
+	for _, name := range names {
+		i := NewInventory(strings.TrimPrefix(name, storagePrefix), namespace)
+		if err := s.Load(ctx, i); err != nil {
+			return inventories, err
+		}
+		inventories = append(inventories, i)
+	}
+
+	return inventories, nil
+}
+
+// Delete removes the ConfigMap or the Secret for the given inventory name and namespace.
+func (s *objectStorage) Delete(ctx context.Context, i *Inventory) error {
+	obj := s.newObject(i.Name, i.Namespace, nil)
+
+	objKey := client.ObjectKeyFromObject(obj)
+	err := s.client.Delete(ctx, obj)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s/%s, error: %w", s.kindName(), objKey, err)
+	}
+	return nil
+}
+
+func (s *objectStorage) kindName() string {
+	if s.kind == SecretStorage {
+		return "Secret"
+	}
+	return "ConfigMap"
+}
+
+// newObject returns the ConfigMap or the Secret that holds the given inventory data.
+func (s *objectStorage) newObject(name, namespace string, data map[string]string) client.Object {
+	objectMeta := metav1.ObjectMeta{
+		Name:      storagePrefix + name,
+		Namespace: namespace,
+		Labels: map[string]string{
+			nameLabelKey:      name,
+			componentLabelKey: KindName,
+			createdByLabelKey: s.owner.Field,
+		},
+	}
+
+	if s.kind == SecretStorage {
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: objectMeta,
+			Type:       corev1.SecretTypeOpaque,
+		}
+		if data != nil {
+			secret.Data = make(map[string][]byte, len(data))
+			for k, v := range data {
+				secret.Data[k] = []byte(v)
+			}
+		}
+		return secret
+	}
+
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: objectMeta,
+		Data:       data,
+	}
+}
+
+// objectData returns the inventory data from the given ConfigMap or Secret.
+func objectData(obj client.Object) map[string]string {
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		return o.Data
+	case *corev1.Secret:
+		data := make(map[string]string, len(o.Data))
+		for k, v := range o.Data {
+			data[k] = string(v)
+		}
+		return data
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+// Provenance records the origin of the manifests applied by the last apply.
+type Provenance struct {
+	// Artifacts is the list of the OCI artifacts the manifests were pulled from.
+	Artifacts []ArtifactProvenance `json:"artifacts,omitempty"`
+
+	// GitURL is the URL of the Git repository the manifests were cloned from.
+	GitURL string `json:"gitURL,omitempty"`
+
+	// GitCommit is the SHA of the cloned Git commit.
+	GitCommit string `json:"gitCommit,omitempty"`
+
+	// Paths is the list of the local kustomize overlays and manifests paths.
+	Paths []string `json:"paths,omitempty"`
+
+	// Checksum is the SHA256 sum of the applied objects.
+	Checksum string `json:"checksum,omitempty"`
+
+	// KustomizerVersion is the version of the kustomizer binary that applied the manifests.
+	KustomizerVersion string `json:"kustomizerVersion,omitempty"`
+}
+
+// ArtifactProvenance records the URL and the resolved digest of an OCI artifact.
+type ArtifactProvenance struct {
+	// URL is the artifact URL as specified at apply time e.g. 'oci://registry/org/repo:tag'.
+	URL string `json:"url"`
+
+	// Digest is the artifact reference in the format 'registry/org/repo@sha256:<digest>'.
+	Digest string `json:"digest"`
+}
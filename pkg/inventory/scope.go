@@ -0,0 +1,98 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// NamespaceScope returns the namespaces this inventory is allowed to manage.
+// When no scope was recorded, the scope is made of the inventory namespace
+// and the namespaces of the recorded objects.
+func (inv *Inventory) NamespaceScope() ([]string, error) {
+	if len(inv.Namespaces) > 0 {
+		return inv.Namespaces, nil
+	}
+
+	scope := map[string]bool{inv.Namespace: true}
+	for _, entry := range inv.Resources {
+		objMetadata, err := object.ParseObjMetadata(entry.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case objMetadata.Namespace != "":
+			scope[objMetadata.Namespace] = true
+		case objMetadata.GroupKind.Group == "" && objMetadata.GroupKind.Kind == "Namespace":
+			scope[objMetadata.Name] = true
+		}
+	}
+
+	namespaces := make([]string, 0, len(scope))
+	for ns := range scope {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// OutOfScope returns the objects that belong to namespaces outside the given scope,
+// the Namespace objects are matched by name and the other cluster-scoped objects are always in scope.
+func OutOfScope(objects []*unstructured.Unstructured, scope []string) []*unstructured.Unstructured {
+	allowed := make(map[string]bool, len(scope))
+	for _, ns := range scope {
+		allowed[ns] = true
+	}
+
+	var result []*unstructured.Unstructured
+	for _, o := range objects {
+		ns := o.GetNamespace()
+		if ns == "" && o.GroupVersionKind().Group == "" && o.GetKind() == "Namespace" {
+			ns = o.GetName()
+		}
+		if ns != "" && !allowed[ns] {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// checkNamespaceScope returns an error if any of the given objects is outside the namespace scope of the inventory.
+func checkNamespaceScope(i *Inventory, objects []*unstructured.Unstructured) error {
+	scope, err := i.NamespaceScope()
+	if err != nil {
+		return err
+	}
+
+	outOfScope := OutOfScope(objects, scope)
+	if len(outOfScope) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(outOfScope))
+	for _, o := range outOfScope {
+		ids = append(ids, ssa.FmtUnstructured(o))
+	}
+	return fmt.Errorf("refusing to prune %d object(s) outside the namespace scope [%s] of inventory %s/%s: %s",
+		len(outOfScope), strings.Join(scope, ", "), i.Namespace, i.Name, strings.Join(ids, ", "))
+}
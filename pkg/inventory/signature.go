@@ -0,0 +1,90 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNotSigned is returned by VerifySignature when the inventory has no signature.
+var ErrNotSigned = errors.New("not signed")
+
+// signedContent holds the inventory fields covered by the signature,
+// these are the fields that drive the pruning of objects, the apply metadata of the entries is not signed.
+type signedContent struct {
+	Name       string     `json:"name"`
+	Namespace  string     `json:"namespace"`
+	Resources  []Resource `json:"resources"`
+	Namespaces []string   `json:"namespaces,omitempty"`
+}
+
+// Sign sets the inventory signature to the HMAC-SHA256 of its entries computed with the given key.
+func (inv *Inventory) Sign(key []byte) error {
+	signature, err := inv.computeSignature(key)
+	if err != nil {
+		return err
+	}
+	inv.Signature = signature
+	return nil
+}
+
+// VerifySignature returns an error if the inventory is not signed
+// or if its signature doesn't match the entries and the given key.
+func (inv *Inventory) VerifySignature(key []byte) error {
+	if inv.Signature == "" {
+		return fmt.Errorf("inventory %s/%s is %w", inv.Namespace, inv.Name, ErrNotSigned)
+	}
+
+	signature, err := inv.computeSignature(key)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(inv.Signature)) {
+		return fmt.Errorf("inventory %s/%s signature verification failed, the inventory has been modified", inv.Namespace, inv.Name)
+	}
+	return nil
+}
+
+func (inv *Inventory) computeSignature(key []byte) (string, error) {
+	content := signedContent{
+		Name:       inv.Name,
+		Namespace:  inv.Namespace,
+		Resources:  make([]Resource, 0, len(inv.Resources)),
+		Namespaces: inv.Namespaces,
+	}
+	for _, entry := range inv.Resources {
+		content.Resources = append(content.Resources, Resource{
+			ObjectID:      entry.ObjectID,
+			ObjectVersion: entry.ObjectVersion,
+		})
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
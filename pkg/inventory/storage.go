@@ -18,7 +18,9 @@ package inventory
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,10 +29,20 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/util/json"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// ConfigMapStorage is the storage kind that records the inventory in a ConfigMap.
+	ConfigMapStorage = "configmap"
+
+	// SecretStorage is the storage kind that records the inventory in a Secret.
+	SecretStorage = "secret"
+
+	// CustomResourceStorage is the storage kind that records the inventory in an Inventory custom resource.
+	CustomResourceStorage = "inventory"
+)
+
 const (
 	KindName          = "inventory"
 	storagePrefix     = "inv-"
@@ -39,15 +51,50 @@ const (
 	createdByLabelKey = "app.kubernetes.io/created-by"
 )
 
-// Storage manages the Inventory in-cluster storage.
+// InventoryStorage is the interface implemented by the inventory storage backends.
+// Downstream users can implement it to record the inventories in external systems.
+type InventoryStorage interface {
+	// Store creates or updates the record of the given inventory.
+	Store(ctx context.Context, i *Inventory) error
+
+	// Load retrieves the record of the given inventory name and namespace,
+	// it must return a NotFound error (apierrors.NewNotFound) if the inventory doesn't exist.
+	Load(ctx context.Context, i *Inventory) error
+
+	// List returns the inventories recorded in the given namespace.
+	List(ctx context.Context, namespace string) ([]*Inventory, error)
+
+	// Delete removes the record of the given inventory, it must not fail if the inventory doesn't exist.
+	Delete(ctx context.Context, i *Inventory) error
+}
+
+// Storage manages the Inventory storage.
 type Storage struct {
 	Manager *ssa.ResourceManager
 	Owner   ssa.Owner
+
+	// Kind of the built-in storage, can be 'configmap', 'secret' or 'inventory', defaults to 'configmap'.
+	Kind string
+
+	// Backend is a custom storage implementation, when set the Kind is ignored.
+	Backend InventoryStorage
+
+	// AllowCrossNamespacePrune disables the namespace scope check of the stale objects.
+	AllowCrossNamespacePrune bool
+
+	// SigningKey is the HMAC key used to sign the inventories on apply and to verify
+	// the signature of the in-cluster inventory before returning its stale objects.
+	SigningKey []byte
+
+	// SignExisting trusts the in-cluster inventory when it's not signed yet,
+	// so that it can be signed on the first apply with pruning enabled.
+	// The signed inventories that were modified are still rejected.
+	SignExisting bool
 }
 
-// ApplyInventory creates or updates the storage object for the given inventory.
+// ApplyInventory creates or updates the storage record for the given inventory.
 func (s *Storage) ApplyInventory(ctx context.Context, i *Inventory, createNamespace bool) error {
-	resources, err := json.Marshal(i.Resources)
+	backend, err := s.backend()
 	if err != nil {
 		return err
 	}
@@ -58,95 +105,89 @@ func (s *Storage) ApplyInventory(ctx context.Context, i *Inventory, createNamesp
 		}
 	}
 
-	cm := s.newConfigMap(i.Name, i.Namespace)
-	cm.Annotations = s.metaToAnnotations(i)
-
-	cm.Data = map[string]string{
-		"resources": string(resources),
+	if err := i.Upgrade(); err != nil {
+		return fmt.Errorf("upgrading inventory failed: %w", err)
 	}
 
-	if len(i.Artifacts) > 0 {
-		artifacts, err := json.Marshal(i.Artifacts)
-		if err != nil {
-			return err
+	if len(s.SigningKey) > 0 {
+		if err := i.Sign(s.SigningKey); err != nil {
+			return fmt.Errorf("signing inventory failed: %w", err)
 		}
-		cm.Data["artifacts"] = string(artifacts)
 	}
 
-	opts := []client.PatchOption{
-		client.ForceOwnership,
-		client.FieldOwner(s.Owner.Field),
-	}
-	return s.Manager.Client().Patch(ctx, cm, client.Apply, opts...)
+	return backend.Store(ctx, i)
 }
 
 // GetInventory retrieves the entries from the storage for the given inventory name and namespace.
 func (s *Storage) GetInventory(ctx context.Context, i *Inventory) error {
-	cm := s.newConfigMap(i.Name, i.Namespace)
-
-	cmKey := client.ObjectKeyFromObject(cm)
-	err := s.Manager.Client().Get(ctx, cmKey, cm)
+	backend, err := s.backend()
 	if err != nil {
 		return err
 	}
+	return backend.Load(ctx, i)
+}
 
-	s.metaFromAnnotations(i, cm.GetAnnotations())
-
-	if _, ok := cm.Data["resources"]; !ok {
-		return fmt.Errorf("inventory data not found in ConfigMap/%s", cmKey)
+// ListInventories returns the inventories in the given namespace.
+func (s *Storage) ListInventories(ctx context.Context, namespace string) ([]*Inventory, error) {
+	backend, err := s.backend()
+	if err != nil {
+		return nil, err
 	}
-	var entries []Resource
-	err = json.Unmarshal([]byte(cm.Data["resources"]), &entries)
+	return backend.List(ctx, namespace)
+}
+
+// DeleteInventory removes the storage for the given inventory name and namespace.
+func (s *Storage) DeleteInventory(ctx context.Context, i *Inventory) error {
+	backend, err := s.backend()
 	if err != nil {
 		return err
 	}
-	i.Resources = entries
-
-	if artifacts, ok := cm.Data["artifacts"]; ok {
-		var list []string
-		err = json.Unmarshal([]byte(artifacts), &list)
-		if err != nil {
-			return err
-		}
-		i.Artifacts = list
+	if err := backend.Delete(ctx, i); err != nil {
+		return err
 	}
-
-	return nil
+	return s.deleteManifests(ctx, i)
 }
 
-// ListInventories returns the inventories in the given namespace.
-func (s *Storage) ListInventories(ctx context.Context, namespace string) ([]*Inventory, error) {
-	var inventories []*Inventory
-	cmList := &corev1.ConfigMapList{}
-	err := s.Manager.Client().List(ctx, cmList, client.InNamespace(namespace), s.getOwnerLabels())
+// MigrateInventory moves the record of the given inventory from this storage to the target storage,
+// the stored manifests are kept in place. The record is written and verified in the target storage
+// before it's removed from this storage, if the removal fails the target record is deleted.
+func (s *Storage) MigrateInventory(ctx context.Context, i *Inventory, target *Storage) error {
+	source, err := s.backend()
 	if err != nil {
-		return inventories, err
+		return err
 	}
-
-	for _, cm := range cmList.Items {
-		i := NewInventory(strings.TrimPrefix(cm.GetName(), storagePrefix), cm.GetNamespace())
-		if err := s.GetInventory(ctx, i); err != nil {
-			return inventories, err
-		}
-		inventories = append(inventories, i)
+	destination, err := target.backend()
+	if err != nil {
+		return err
 	}
 
-	return inventories, nil
-}
+	if err := destination.Store(ctx, i); err != nil {
+		return fmt.Errorf("failed to write the inventory to the %s storage, error: %w", target.Kind, err)
+	}
 
-// DeleteInventory removes the storage for the given inventory name and namespace.
-func (s *Storage) DeleteInventory(ctx context.Context, i *Inventory) error {
-	cm := s.newConfigMap(i.Name, i.Namespace)
+	migrated := NewInventory(i.Name, i.Namespace)
+	if err := destination.Load(ctx, migrated); err != nil {
+		return fmt.Errorf("failed to verify the inventory in the %s storage, error: %w", target.Kind, err)
+	}
+	if len(migrated.Resources) != len(i.Resources) || len(migrated.History) != len(i.History) {
+		_ = destination.Delete(ctx, i)
+		return fmt.Errorf("failed to verify the inventory in the %s storage, the entries don't match", target.Kind)
+	}
 
-	cmKey := client.ObjectKeyFromObject(cm)
-	err := s.Manager.Client().Delete(ctx, cm)
-	if err != nil && !apierrors.IsNotFound(err) {
-		return fmt.Errorf("failed to delete ConfigMap/%s, error: %w", cmKey, err)
+	if err := source.Delete(ctx, i); err != nil {
+		if rollbackErr := destination.Delete(ctx, i); rollbackErr != nil {
+			return fmt.Errorf("failed to remove the inventory from the %s storage, error: %w, cleanup failed: %s", s.Kind, err, rollbackErr)
+		}
+		return fmt.Errorf("failed to remove the inventory from the %s storage, error: %w", s.Kind, err)
 	}
+
 	return nil
 }
 
 // GetInventoryStaleObjects returns the list of objects metadata subject to pruning.
+// Unless AllowCrossNamespacePrune is set, it fails if any of the objects is outside
+// the namespace scope of the given inventory. When a SigningKey is set, it fails if
+// the in-cluster inventory is not signed, unless SignExisting is set, or if it was modified after signing.
 func (s *Storage) GetInventoryStaleObjects(ctx context.Context, i *Inventory) ([]*unstructured.Unstructured, error) {
 	objects := make([]*unstructured.Unstructured, 0)
 	existingInventory := NewInventory(i.Name, i.Namespace)
@@ -157,66 +198,134 @@ func (s *Storage) GetInventoryStaleObjects(ctx context.Context, i *Inventory) ([
 		return nil, err
 	}
 
+	if len(s.SigningKey) > 0 {
+		if err := existingInventory.VerifySignature(s.SigningKey); err != nil {
+			if !s.SignExisting || !errors.Is(err, ErrNotSigned) {
+				return nil, err
+			}
+		}
+	}
+
 	objects, err := existingInventory.Diff(i)
 	if err != nil {
 		return nil, err
 	}
 
+	if !s.AllowCrossNamespacePrune {
+		if err := checkNamespaceScope(i, objects); err != nil {
+			return nil, err
+		}
+	}
+
 	return objects, nil
 }
 
-func (s *Storage) getOwnerLabels() client.MatchingLabels {
+// backend returns the custom storage implementation or the built-in one for the storage kind.
+func (s *Storage) backend() (InventoryStorage, error) {
+	if s.Backend != nil {
+		return s.Backend, nil
+	}
+
+	switch kind := strings.ToLower(s.Kind); kind {
+	case "", ConfigMapStorage, SecretStorage:
+		if kind == "" {
+			kind = ConfigMapStorage
+		}
+		return &objectStorage{client: s.Manager.Client(), owner: s.Owner, kind: kind}, nil
+	case CustomResourceStorage:
+		return &customResourceStorage{manager: s.Manager, owner: s.Owner}, nil
+	default:
+		return nil, fmt.Errorf("unsupported inventory storage kind '%s', can be %s, %s or %s",
+			s.Kind, ConfigMapStorage, SecretStorage, CustomResourceStorage)
+	}
+}
+
+func ownerLabels(owner ssa.Owner) client.MatchingLabels {
 	return client.MatchingLabels{
 		componentLabelKey: KindName,
-		createdByLabelKey: s.Owner.Field,
+		createdByLabelKey: owner.Field,
 	}
 }
 
-func (s *Storage) metaToAnnotations(inv *Inventory) map[string]string {
+func metaToAnnotations(owner ssa.Owner, inv *Inventory) map[string]string {
 	annotations := map[string]string{
-		s.Owner.Group + "/last-applied-time": time.Now().UTC().Format(time.RFC3339),
+		owner.Group + "/last-applied-time": lastAppliedTime(inv),
+		owner.Group + "/format":            strconv.Itoa(CurrentFormat),
 	}
 	if inv.Source != "" {
-		annotations[s.Owner.Group+"/source"] = inv.Source
+		annotations[owner.Group+"/source"] = inv.Source
 	}
 	if inv.Revision != "" {
-		annotations[s.Owner.Group+"/revision"] = inv.Revision
+		annotations[owner.Group+"/revision"] = inv.Revision
+	}
+	if inv.Group != "" {
+		annotations[owner.Group+"/group"] = inv.Group
+	}
+	if len(inv.DependsOn) > 0 {
+		annotations[owner.Group+"/depends-on"] = strings.Join(inv.DependsOn, ",")
+	}
+	if inv.DerivedFrom != "" {
+		annotations[owner.Group+"/derived-from"] = inv.DerivedFrom
+	}
+	if inv.TTL > 0 {
+		annotations[owner.Group+"/ttl"] = inv.TTL.String()
+	}
+	if len(inv.Namespaces) > 0 {
+		annotations[owner.Group+"/namespaces"] = strings.Join(inv.Namespaces, ",")
+	}
+	if inv.Signature != "" {
+		annotations[owner.Group+"/signature"] = inv.Signature
+	}
+	for k, v := range inv.Meta {
+		annotations[owner.Group+"/"+MetaAnnotationPrefix+k] = v
 	}
 
 	return annotations
 }
 
-func (s *Storage) metaFromAnnotations(inv *Inventory, annotations map[string]string) {
+// lastAppliedTime returns the recorded apply timestamp of the given inventory,
+// or the current time for the inventories that are being applied.
+func lastAppliedTime(inv *Inventory) string {
+	if inv.LastAppliedAt != "" {
+		return inv.LastAppliedAt
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func metaFromAnnotations(owner ssa.Owner, inv *Inventory, annotations map[string]string) {
+	inv.Format = LegacyFormat
 	for k, v := range annotations {
 		switch k {
-		case s.Owner.Group + "/source":
+		case owner.Group + "/source":
 			inv.Source = v
-		case s.Owner.Group + "/revision":
+		case owner.Group + "/revision":
 			inv.Revision = v
-		case s.Owner.Group + "/last-applied-time":
+		case owner.Group + "/last-applied-time":
 			inv.LastAppliedAt = v
+		case owner.Group + "/group":
+			inv.Group = v
+		case owner.Group + "/depends-on":
+			inv.DependsOn = strings.Split(v, ",")
+		case owner.Group + "/derived-from":
+			inv.DerivedFrom = v
+		case owner.Group + "/ttl":
+			inv.TTL, _ = time.ParseDuration(v)
+		case owner.Group + "/namespaces":
+			inv.Namespaces = strings.Split(v, ",")
+		case owner.Group + "/signature":
+			inv.Signature = v
+		case owner.Group + "/format":
+			if format, err := strconv.Atoi(v); err == nil {
+				inv.Format = format
+			}
+		default:
+			if key := strings.TrimPrefix(k, owner.Group+"/"+MetaAnnotationPrefix); key != k {
+				inv.SetMeta(map[string]string{key: v})
+			}
 		}
 	}
 }
 
-func (s *Storage) newConfigMap(name, namespace string) *corev1.ConfigMap {
-	return &corev1.ConfigMap{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "v1",
-			Kind:       "ConfigMap",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      storagePrefix + name,
-			Namespace: namespace,
-			Labels: map[string]string{
-				nameLabelKey:      name,
-				componentLabelKey: KindName,
-				createdByLabelKey: s.Owner.Field,
-			},
-		},
-	}
-}
-
 // createNamespace creates the inventory namespace if not present.
 func (s *Storage) createNamespace(ctx context.Context, name string) error {
 	ns := &corev1.Namespace{
@@ -18,6 +18,7 @@ package registry
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 
@@ -38,20 +39,45 @@ func ParseAgeRecipients(filePath string) ([]age.Recipient, error) {
 	return nil, nil
 }
 
-func ParseAgeIdentities(filePath string) ([]age.Identity, error) {
+// ParseAgeRecipientKeys parses the age public keys e.g. 'age1...'.
+func ParseAgeRecipientKeys(keys []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, key := range keys {
+		r, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient '%s': %w", key, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// ParseAgeIdentities reads the age identities from the given files, empty paths are ignored.
+func ParseAgeIdentities(filePaths ...string) ([]age.Identity, error) {
 	var identities []age.Identity
-	if filePath != "" {
-		f, err := os.Open(filePath)
+	for _, filePath := range filePaths {
+		if filePath == "" {
+			continue
+		}
+		ids, err := parseAgeIdentitiesFile(filePath)
 		if err != nil {
 			return nil, err
 		}
-		defer f.Close()
-		return age.ParseIdentities(f)
+		identities = append(identities, ids...)
 	}
 
 	return identities, nil
 }
 
+func parseAgeIdentitiesFile(filePath string) ([]age.Identity, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return age.ParseIdentities(f)
+}
+
 func encrypt(data []byte, recipients []age.Recipient) ([]byte, error) {
 	buffer := &bytes.Buffer{}
 	aw := armor.NewWriter(buffer)
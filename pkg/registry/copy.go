@@ -0,0 +1,104 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// cosignTagSuffixes are the suffixes of the tags where cosign stores the signatures,
+// attestations and SBOMs of an artifact, in the format '<alg>-<hex>.<suffix>'.
+var cosignTagSuffixes = []string{"sig", "att", "sbom"}
+
+// Copy copies the artifact from the source to the destination by digest, without modifying the manifest,
+// and returns the destination digest URL. When withReferrers is true, the cosign signatures, attestations
+// and the artifacts attached with Attach are copied too, their tags are returned in the list of referrers.
+// The explicit credentials are sent to the destination registry, unless the options are scoped to another registry.
+func Copy(ctx context.Context, opts Options, srcURL, dstURL string, withReferrers bool) (string, []string, error) {
+	srcOpts := crane.GetOptions(opts.craneOptions(ctx, srcURL, "")...)
+	srcRef, err := name.ParseReference(srcURL, srcOpts.Name...)
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	dstOpts := crane.GetOptions(opts.craneOptions(ctx, dstURL, registryHost(dstURL))...)
+	dstRef, err := name.ParseReference(dstURL, dstOpts.Name...)
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	desc, err := remote.Get(srcRef, srcOpts.Remote...)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetching %s failed: %w", srcURL, err)
+	}
+
+	if d, ok := dstRef.(name.Digest); ok && d.DigestStr() != desc.Digest.String() {
+		return "", nil, fmt.Errorf("the destination digest %s doesn't match the source digest %s", d.DigestStr(), desc.Digest)
+	}
+
+	if err := writeDescriptor(dstRef, desc, dstOpts.Remote); err != nil {
+		return "", nil, fmt.Errorf("copying %s failed: %w", srcURL, err)
+	}
+
+	var referrers []string
+	if withReferrers {
+		tags := []string{referrersTag(srcRef.Context(), desc.Digest).TagStr()}
+		for _, suffix := range cosignTagSuffixes {
+			tags = append(tags, fmt.Sprintf("%s.%s", strings.Replace(desc.Digest.String(), ":", "-", 1), suffix))
+		}
+
+		for _, tag := range tags {
+			refDesc, err := remote.Get(srcRef.Context().Tag(tag), srcOpts.Remote...)
+			if err != nil {
+				if isNotFound(err) {
+					continue
+				}
+				return "", nil, fmt.Errorf("fetching %s failed: %w", tag, err)
+			}
+
+			if err := writeDescriptor(dstRef.Context().Tag(tag), refDesc, dstOpts.Remote); err != nil {
+				return "", nil, fmt.Errorf("copying %s failed: %w", tag, err)
+			}
+			referrers = append(referrers, dstRef.Context().Tag(tag).String())
+		}
+	}
+
+	return dstRef.Context().Digest(desc.Digest.String()).String(), referrers, nil
+}
+
+// writeDescriptor uploads the image or the index with all its manifests and blobs to the given reference.
+func writeDescriptor(ref name.Reference, desc *remote.Descriptor, opts []remote.Option) error {
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return remote.WriteIndex(ref, idx, opts...)
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return remote.Write(ref, img, opts...)
+}
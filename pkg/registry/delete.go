@@ -0,0 +1,51 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Delete removes the tag of the artifact from the repository and returns the deleted reference.
+// When digest is true, or when the URL is a digest reference, the artifact manifest is deleted
+// instead, along with all the tags that point to it.
+func Delete(ctx context.Context, opts Options, url string, digest bool) (string, error) {
+	o := crane.GetOptions(opts.craneOptions(ctx, url, registryHost(url))...)
+	ref, err := name.ParseReference(url, o.Name...)
+	if err != nil {
+		return "", fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	if _, ok := ref.(name.Digest); !ok && digest {
+		desc, err := remote.Head(ref, o.Remote...)
+		if err != nil {
+			return "", fmt.Errorf("resolving digest failed: %w", err)
+		}
+		ref = ref.Context().Digest(desc.Digest.String())
+	}
+
+	if err := remote.Delete(ref, o.Remote...); err != nil {
+		return "", err
+	}
+
+	return ref.String(), nil
+}
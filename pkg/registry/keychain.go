@@ -0,0 +1,183 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+)
+
+const (
+	// GenericProvider reads the credentials from the docker config and its credential helpers.
+	GenericProvider = "generic"
+	// AWSProvider exchanges the ambient AWS credentials for an ECR token, with a fallback to the docker config.
+	AWSProvider = "aws"
+	// GCPProvider uses the Google application default credentials or gcloud
+	// for GCR and Artifact Registry, with a fallback to the docker config.
+	GCPProvider = "gcp"
+	// AzureProvider exchanges the ambient Azure credentials for an ACR token, with a fallback to the docker config.
+	AzureProvider = "azure"
+)
+
+// Providers is the list of the supported registry authentication providers.
+var Providers = []string{GenericProvider, AWSProvider, GCPProvider, AzureProvider}
+
+// keychain returns the keychain of the given provider.
+func keychain(provider string) authn.Keychain {
+	switch provider {
+	case AWSProvider:
+		return authn.NewMultiKeychain(&cliKeychain{resolve: ecrToken}, dockerKeychain{})
+	case GCPProvider:
+		return authn.NewMultiKeychain(google.Keychain, dockerKeychain{})
+	case AzureProvider:
+		return authn.NewMultiKeychain(&cliKeychain{resolve: acrToken}, dockerKeychain{})
+	default:
+		return dockerKeychain{}
+	}
+}
+
+// hostKeychain resolves the given authenticator for a single registry host,
+// the other hosts are resolved anonymously.
+type hostKeychain struct {
+	host string
+	auth authn.Authenticator
+}
+
+func (k *hostKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if target.RegistryStr() != k.host {
+		return authn.Anonymous, nil
+	}
+	return k.auth, nil
+}
+
+// dockerKeychain resolves the credentials from the docker config, including the credentials
+// stored with the helpers set in 'credHelpers' and 'credsStore' e.g. osxkeychain, pass or ecr-login.
+type dockerKeychain struct{}
+
+func (dockerKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	auth, err := authn.DefaultKeychain.Resolve(target)
+	if err != nil {
+		host := target.RegistryStr()
+		if helper := credentialHelper(host); helper != "" {
+			return nil, fmt.Errorf("getting the credentials for %s with 'docker-credential-%s' failed: %w", host, helper, err)
+		}
+		return nil, fmt.Errorf("getting the credentials for %s from the docker config failed: %w", host, err)
+	}
+	return auth, nil
+}
+
+// credentialHelper returns the name of the docker credential helper used for the given host.
+func credentialHelper(host string) string {
+	cf, err := config.Load(os.Getenv("DOCKER_CONFIG"))
+	if err != nil {
+		return ""
+	}
+	if helper, ok := cf.CredentialHelpers[host]; ok {
+		return helper
+	}
+	return cf.CredentialsStore
+}
+
+// cliKeychain resolves the registry credentials with the cloud provider CLI
+// and caches them for the lifetime of the process.
+type cliKeychain struct {
+	mu      sync.Mutex
+	cache   map[string]authn.Authenticator
+	resolve func(host string) (authn.Authenticator, error)
+}
+
+func (k *cliKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	host := target.RegistryStr()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if auth, ok := k.cache[host]; ok {
+		return auth, nil
+	}
+
+	auth, err := k.resolve(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if k.cache == nil {
+		k.cache = make(map[string]authn.Authenticator)
+	}
+	k.cache[host] = auth
+	return auth, nil
+}
+
+var ecrHostRegex = regexp.MustCompile(`^\d{12}\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// ecrToken returns the ECR credentials issued by 'aws ecr get-login-password',
+// the hosts outside ECR are resolved anonymously.
+func ecrToken(host string) (authn.Authenticator, error) {
+	match := ecrHostRegex.FindStringSubmatch(host)
+	if match == nil {
+		return authn.Anonymous, nil
+	}
+
+	password, err := runCLI("aws", "ecr", "get-login-password", "--region", match[1])
+	if err != nil {
+		return nil, fmt.Errorf("getting the ECR token for %s failed: %w", host, err)
+	}
+
+	return &authn.Basic{Username: "AWS", Password: password}, nil
+}
+
+// acrToken returns the ACR credentials issued by 'az acr login --expose-token',
+// the hosts outside ACR are resolved anonymously.
+func acrToken(host string) (authn.Authenticator, error) {
+	if !strings.HasSuffix(host, ".azurecr.io") && !strings.HasSuffix(host, ".azurecr.cn") && !strings.HasSuffix(host, ".azurecr.us") {
+		return authn.Anonymous, nil
+	}
+
+	token, err := runCLI("az", "acr", "login", "--name", host, "--expose-token", "--output", "tsv", "--query", "accessToken")
+	if err != nil {
+		return nil, fmt.Errorf("getting the ACR token for %s failed: %w", host, err)
+	}
+
+	// ACR expects the null GUID as username when authenticating with an access token
+	return &authn.Basic{Username: "00000000-0000-0000-0000-000000000000", Password: token}, nil
+}
+
+func runCLI(name string, args ...string) (string, error) {
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in path $PATH: %w", name, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(bin, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
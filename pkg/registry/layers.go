@@ -0,0 +1,161 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// ManifestsLayerMediaType is the media type of the named layers that contain a multi-doc YAML.
+	ManifestsLayerMediaType = "application/vnd.kustomizer.manifests.v1.tar+gzip"
+	// LayerTitleAnnotation holds the name of a layer e.g. the input directory it was built from.
+	LayerTitleAnnotation = "org.opencontainers.image.title"
+
+	// VariantSeparator separates the artifact URL from the name of a layer e.g. 'oci://docker.io/user/repo:v1.0.0#prod'.
+	VariantSeparator = "#"
+)
+
+// ErrBundleArtifact is returned when pulling all the layers of an artifact that contains several variants.
+var ErrBundleArtifact = errors.New("the artifact is a bundle, a variant must be selected e.g. 'oci://docker.io/user/repo:v1.0.0#prod'")
+
+// SplitVariant returns the artifact URL without the variant and the variant name, if any.
+func SplitVariant(ociURL string) (string, string) {
+	if i := strings.LastIndex(ociURL, VariantSeparator); i >= 0 {
+		return ociURL[:i], ociURL[i+1:]
+	}
+	return ociURL, ""
+}
+
+// Content is the data stored in an artifact layer.
+type Content struct {
+	Name string
+	Data []byte
+}
+
+// JoinContents returns the multi-doc YAML made of the contents in order.
+func JoinContents(contents []Content) string {
+	if len(contents) == 1 {
+		return string(contents[0].Data)
+	}
+
+	sb := new(strings.Builder)
+	for _, c := range contents {
+		sb.Write(c.Data)
+		if len(c.Data) > 0 && !strings.HasSuffix(string(c.Data), "\n") {
+			sb.WriteString("\n---\n")
+		}
+	}
+	return sb.String()
+}
+
+// PushLayers packages each content in a separate layer annotated with its name and checksum,
+// the layers can be pulled selectively and are deduplicated by the registry across versions.
+// The metadata checksum must be computed for the contents joined with JoinContents.
+func PushLayers(ctx context.Context, opts Options, url string, contents []Content, meta *Metadata, recipients []age.Recipient) (string, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "oci")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dataFile := "all.yaml"
+	if len(recipients) > 0 {
+		meta.Encrypted = AgeEncryptionVersion
+		dataFile = "all.yaml.age"
+	}
+
+	img := empty.Image
+	for i, c := range contents {
+		data := c.Data
+		if len(recipients) > 0 {
+			data, err = encrypt(data, recipients)
+			if err != nil {
+				return "", fmt.Errorf("failed to encrypt data with age: %w", err)
+			}
+		}
+
+		tarFile := filepath.Join(tmpDir, fmt.Sprintf("layer%d.tar", i))
+		if err := tarContent(tarFile, dataFile, data); err != nil {
+			return "", err
+		}
+
+		layer, err := tarball.LayerFromFile(tarFile, tarball.WithMediaType(ManifestsLayerMediaType))
+		if err != nil {
+			return "", fmt.Errorf("creating layer %s failed: %w", c.Name, err)
+		}
+
+		img, err = mutate.Append(img, mutate.Addendum{
+			Layer: layer,
+			Annotations: map[string]string{
+				LayerTitleAnnotation: c.Name,
+				ChecksumAnnotation:   fmt.Sprintf("%x", sha256.Sum256(c.Data)),
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("appeding layer %s failed: %w", c.Name, err)
+		}
+	}
+
+	img = mutate.Annotations(img, meta.ToAnnotations()).(gcrv1.Image)
+
+	if err := crane.Push(img, url, opts.craneOptions(ctx, url, registryHost(url))...); err != nil {
+		return "", fmt.Errorf("pushing image failed: %w", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("parsing digest failed: %w", err)
+	}
+
+	return ref.Context().Digest(digest.String()).String(), nil
+}
+
+// layerName returns the title of the layer, or an empty string for unnamed layers.
+func layerName(desc gcrv1.Descriptor) string {
+	return desc.Annotations[LayerTitleAnnotation]
+}
+
+// isManifestsLayer returns true if the layer contains a multi-doc YAML.
+func isManifestsLayer(desc gcrv1.Descriptor) bool {
+	switch desc.MediaType {
+	case ManifestsLayerMediaType, types.DockerLayer, types.OCILayer:
+		return true
+	default:
+		return false
+	}
+}
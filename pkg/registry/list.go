@@ -23,8 +23,9 @@ import (
 	"github.com/google/go-containerregistry/pkg/crane"
 )
 
-func List(ctx context.Context, repo string) ([]string, error) {
-	tags, err := crane.ListTags(repo, craneOptions(ctx)...)
+func List(ctx context.Context, opts Options, repo string) ([]string, error) {
+	src := opts.MirrorURL(repo)
+	tags, err := crane.ListTags(src, opts.craneOptions(ctx, src, registryHost(repo))...)
 	if err != nil {
 		return nil, err
 	}
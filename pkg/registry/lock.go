@@ -0,0 +1,109 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	LockFileName       = "kustomizer.lock"
+	LockFileKind       = "Lock"
+	LockFileApiVersion = "kustomizer.dev/v1"
+)
+
+// LockFile holds the digests of the artifacts resolved by 'kustomizer lock'.
+type LockFile struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Artifacts is the list of the locked artifact references.
+	Artifacts []LockedArtifact `json:"artifacts"`
+}
+
+// LockedArtifact maps an artifact URL to its immutable digest URL.
+type LockedArtifact struct {
+	// URL is the artifact reference as specified by the user e.g. 'oci://docker.io/user/repo:v1.0.0'.
+	URL string `json:"url"`
+
+	// Digest is the resolved reference e.g. 'oci://docker.io/user/repo@sha256:<digest>'.
+	Digest string `json:"digest"`
+}
+
+// NewLockFile returns an empty lock file.
+func NewLockFile() *LockFile {
+	return &LockFile{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       LockFileKind,
+			APIVersion: LockFileApiVersion,
+		},
+	}
+}
+
+// ReadLockFile loads the lock file from the specified path,
+// if the file is not found, nil is returned.
+func ReadLockFile(path string) (*LockFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &LockFile{}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("parsing %s failed: %w", path, err)
+	}
+
+	if lock.Kind != LockFileKind {
+		return nil, fmt.Errorf("%s is not a lock file, expected kind '%s'", path, LockFileKind)
+	}
+
+	for _, artifact := range lock.Artifacts {
+		base, _ := SplitVariant(artifact.Digest)
+		if _, err := ParseURL(base); err != nil {
+			return nil, fmt.Errorf("invalid digest for %s: %w", artifact.URL, err)
+		}
+	}
+
+	return lock, nil
+}
+
+// Write saves the lock file at the given path.
+func (l *LockFile) Write(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Lookup returns the digest URL locked for the given artifact URL.
+func (l *LockFile) Lookup(url string) (string, bool) {
+	for _, artifact := range l.Artifacts {
+		if artifact.URL == url {
+			return artifact.Digest, true
+		}
+	}
+	return "", false
+}
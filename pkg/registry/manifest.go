@@ -0,0 +1,94 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Layer holds the media type, digest and size of an artifact layer.
+type Layer struct {
+	Title     string `json:"title,omitempty"`
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Manifest holds the OCI manifest details of an artifact.
+type Manifest struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Layers      []Layer           `json:"layers"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// GetManifest fetches the OCI manifest of the specified artifact without downloading its layers.
+func GetManifest(ctx context.Context, opts Options, url string) (*Manifest, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	src := opts.MirrorURL(url)
+	raw, err := crane.Manifest(src, opts.craneOptions(ctx, src, registryHost(url))...)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := gcrv1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest failed: %w", err)
+	}
+
+	digest, size, err := gcrv1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parsing digest failed: %w", err)
+	}
+
+	result := &Manifest{
+		MediaType:   string(manifest.MediaType),
+		Digest:      ref.Context().Digest(digest.String()).String(),
+		Size:        size,
+		Annotations: manifest.Annotations,
+	}
+	for _, layer := range manifest.Layers {
+		result.Layers = append(result.Layers, Layer{
+			Title:     layerName(layer),
+			MediaType: string(layer.MediaType),
+			Digest:    layer.Digest.String(),
+			Size:      layer.Size,
+		})
+	}
+
+	return result, nil
+}
+
+// TotalSize returns the sum of the layers size.
+func (m *Manifest) TotalSize() int64 {
+	var size int64
+	for _, layer := range m.Layers {
+		size += layer.Size
+	}
+	return size
+}
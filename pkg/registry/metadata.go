@@ -18,6 +18,7 @@ package registry
 
 import (
 	"fmt"
+	"strings"
 )
 
 const (
@@ -25,9 +26,23 @@ const (
 	ChecksumAnnotation   = "kustomizer.dev/checksum"
 	CreatedAnnotation    = "kustomizer.dev/created"
 	EncryptedAnnotation  = "kustomizer.dev/encrypted"
+	ModeAnnotation       = "kustomizer.dev/mode"
 	AgeEncryptionVersion = "age-encryption.org/v1"
 	SourceAnnotation     = "org.opencontainers.image.source"
 	RevisionAnnotation   = "org.opencontainers.image.revision"
+
+	annotationPrefix = "kustomizer.dev/"
+)
+
+const (
+	// ManifestsMode is the mode of the artifacts that contain rendered Kubernetes manifests.
+	ManifestsMode = "manifests"
+	// SourceMode is the mode of the artifacts that contain a kustomization directory
+	// which is built by the consumer.
+	SourceMode = "source"
+	// BundleMode is the mode of the artifacts that contain several variants of the
+	// Kubernetes manifests e.g. one for each environment, stored in named layers.
+	BundleMode = "bundle"
 )
 
 type Metadata struct {
@@ -35,22 +50,33 @@ type Metadata struct {
 	Checksum       string `json:"checksum"`
 	Created        string `json:"created"`
 	Encrypted      string `json:"encrypted,omitempty"`
+	Mode           string `json:"mode,omitempty"`
 	Digest         string `json:"digest,omitempty"`
 	SourceURL      string `json:"source_url"`
 	SourceRevision string `json:"source_revision"`
+
+	// Annotations holds custom annotations of the artifact manifest e.g. 'org.opencontainers.image.licenses'.
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 func (m *Metadata) ToAnnotations() map[string]string {
-	annotations := map[string]string{
-		VersionAnnotation:  m.Version,
-		ChecksumAnnotation: m.Checksum,
-		CreatedAnnotation:  m.Created,
+	annotations := make(map[string]string, len(m.Annotations)+3)
+	for k, v := range m.Annotations {
+		annotations[k] = v
 	}
 
+	annotations[VersionAnnotation] = m.Version
+	annotations[ChecksumAnnotation] = m.Checksum
+	annotations[CreatedAnnotation] = m.Created
+
 	if m.Encrypted != "" {
 		annotations[EncryptedAnnotation] = m.Encrypted
 	}
 
+	if m.Mode != "" && m.Mode != ManifestsMode {
+		annotations[ModeAnnotation] = m.Mode
+	}
+
 	if m.SourceURL != "" {
 		annotations[SourceAnnotation] = m.SourceURL
 	}
@@ -88,6 +114,11 @@ func GetMetadata(annotations map[string]string) (*Metadata, error) {
 		m.Encrypted = encrypted
 	}
 
+	m.Mode = ManifestsMode
+	if mode, ok := annotations[ModeAnnotation]; ok {
+		m.Mode = mode
+	}
+
 	if sourceURL, ok := annotations[SourceAnnotation]; ok {
 		m.SourceURL = sourceURL
 	}
@@ -96,5 +127,25 @@ func GetMetadata(annotations map[string]string) (*Metadata, error) {
 		m.SourceRevision = sourceRevision
 	}
 
+	for k, v := range annotations {
+		if strings.HasPrefix(k, annotationPrefix) || k == SourceAnnotation || k == RevisionAnnotation {
+			continue
+		}
+		if m.Annotations == nil {
+			m.Annotations = make(map[string]string)
+		}
+		m.Annotations[k] = v
+	}
+
 	return &m, nil
 }
+
+// ValidateAnnotations returns an error if the custom annotations override the kustomizer metadata.
+func ValidateAnnotations(annotations map[string]string) error {
+	for k := range annotations {
+		if strings.HasPrefix(k, annotationPrefix) {
+			return fmt.Errorf("annotation '%s' is reserved, the '%s' prefix is used for the kustomizer metadata", k, annotationPrefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Options holds the settings used to connect to the container registries,
+// Validate must be called before the options are passed to the registry operations.
+type Options struct {
+	// Credentials in the format 'username:password', when empty
+	// the credentials are read from '~/.docker/config.json'.
+	Credentials string
+
+	// Token is a registry bearer token e.g. a short-lived token issued by the CI.
+	Token string
+
+	// Registry is the host e.g. 'ghcr.io' the credentials and token are sent to, when empty they are sent
+	// to the registry of the artifact URL of each operation, and never to its mirror or to the copy source.
+	Registry string
+
+	// Provider selects the keychain used when no explicit credentials are specified,
+	// can be 'generic', 'aws', 'gcp' or 'azure'.
+	Provider string
+
+	// InsecureRegistries is the list of the registry hosts e.g. 'localhost:5000' that are accessed
+	// without TLS verification, with a fallback to plain HTTP.
+	InsecureRegistries []string
+
+	// CAFile is the path to a PEM bundle of the certificate authorities trusted in addition to the system ones.
+	CAFile string
+
+	// CertFile and KeyFile are the paths to the PEM encoded client certificate and key used for mTLS.
+	CertFile string
+	KeyFile  string
+
+	// Mirrors is the list of the locations from where the artifacts are pulled instead of their origin.
+	Mirrors []Mirror
+
+	tlsConfig *tls.Config
+}
+
+// Mirror redirects the pull operations from a registry or repository to another location.
+type Mirror struct {
+	// Source is the registry host or repository prefix e.g. 'ghcr.io' or 'ghcr.io/org'.
+	Source string
+
+	// Target is the location that replaces the source e.g. 'registry.internal/ghcr'.
+	Target string
+}
+
+// Validate checks the options and loads the TLS certificates.
+func (o *Options) Validate() error {
+	opts := *o
+	if opts.Credentials != "" && opts.Token != "" {
+		return fmt.Errorf("the registry credentials and token are mutually exclusive")
+	}
+
+	if opts.Credentials != "" {
+		if user, _, ok := strings.Cut(opts.Credentials, ":"); !ok || user == "" {
+			return fmt.Errorf("the registry credentials must be in the format 'username:password'")
+		}
+	}
+
+	if opts.Provider != "" {
+		supported := false
+		for _, p := range Providers {
+			supported = supported || p == opts.Provider
+		}
+		if !supported {
+			return fmt.Errorf("unsupported provider '%s', can be %s", opts.Provider, strings.Join(Providers, ", "))
+		}
+	}
+
+	if opts.Registry != "" {
+		if _, err := name.NewRegistry(opts.Registry); err != nil {
+			return fmt.Errorf("invalid registry host '%s': %w", opts.Registry, err)
+		}
+	}
+
+	tc, err := newTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	o.tlsConfig = tc
+	return nil
+}
+
+// newTLSConfig returns the TLS config with the custom CA bundle and client certificate,
+// or nil if none are specified.
+func newTLSConfig(opts Options) (*tls.Config, error) {
+	if opts.CAFile == "" && opts.CertFile == "" && opts.KeyFile == "" {
+		return nil, nil
+	}
+
+	tc := &tls.Config{}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading the registry CA file failed: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in the registry CA file '%s'", opts.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("the registry client certificate and key must be specified together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading the registry client certificate failed: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
+
+// MirrorURL returns the URL of the artifact or repository in the mirror with the longest matching source,
+// if no mirror matches the URL is returned unchanged.
+func (o Options) MirrorURL(url string) string {
+	var match *Mirror
+	for i, m := range o.Mirrors {
+		if url != m.Source && !strings.HasPrefix(url, m.Source+"/") {
+			continue
+		}
+		if match == nil || len(m.Source) > len(match.Source) {
+			match = &o.Mirrors[i]
+		}
+	}
+
+	if match == nil {
+		return url
+	}
+	return match.Target + strings.TrimPrefix(url, match.Source)
+}
+
+// IsInsecure returns true if the registry of the given image or repository URL is
+// in the list of the insecure registries.
+func (o Options) IsInsecure(url string) bool {
+	if len(o.InsecureRegistries) == 0 {
+		return false
+	}
+
+	host := registryHost(url)
+	for _, r := range o.InsecureRegistries {
+		if r == host {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopedTo returns a copy of the options with the explicit credentials scoped to the registry
+// of the given image or repository URL, unless the options are already scoped to a registry.
+func (o Options) ScopedTo(url string) Options {
+	if o.Registry == "" {
+		o.Registry = registryHost(url)
+	}
+	return o
+}
+
+// registryHost returns the registry host of the given image or repository URL,
+// or an empty string if the URL is invalid.
+func registryHost(url string) string {
+	ref, err := name.ParseReference(strings.TrimPrefix(url, URLPrefix))
+	if err != nil {
+		return ""
+	}
+	return ref.Context().RegistryStr()
+}
+
+// httpTransport returns a copy of the default transport with the custom TLS config,
+// or nil if the default transport can be used.
+func (o Options) httpTransport(insecure bool) http.RoundTripper {
+	if o.tlsConfig == nil && !insecure {
+		return nil
+	}
+
+	t := remote.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{}
+	if o.tlsConfig != nil {
+		t.TLSClientConfig = o.tlsConfig.Clone()
+	}
+	t.TLSClientConfig.InsecureSkipVerify = insecure
+	return t
+}
+
+// keychain returns the provider keychain, preceded by the explicit credentials scoped to
+// the registry set in the options or, when not set, to the given host.
+func (o Options) keychain(host string) authn.Keychain {
+	kc := keychain(o.Provider)
+
+	auth := o.authenticator()
+	if auth == nil {
+		return kc
+	}
+
+	if o.Registry != "" {
+		host = o.Registry
+	}
+	if host == "" {
+		return kc
+	}
+	return authn.NewMultiKeychain(&hostKeychain{host: host, auth: auth}, kc)
+}
+
+// authenticator returns the authenticator for the explicit credentials,
+// or nil if the credentials should be read from the docker config.
+func (o Options) authenticator() authn.Authenticator {
+	switch {
+	case o.Token != "":
+		return authn.FromConfig(authn.AuthConfig{RegistryToken: o.Token})
+	case o.Credentials != "":
+		user, password, _ := strings.Cut(o.Credentials, ":")
+		return authn.FromConfig(authn.AuthConfig{Username: user, Password: password})
+	default:
+		return nil
+	}
+}
@@ -21,72 +21,135 @@ import (
 	"crypto/sha256"
 	"filippo.io/age"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
-func Pull(ctx context.Context, url string, identities []age.Identity) (string, *Metadata, error) {
+// Pull downloads the artifact and returns the multi-doc YAML of all its layers
+// after verifying the content against the checksum recorded in the artifact metadata.
+func Pull(ctx context.Context, opts Options, url string, identities []age.Identity) (string, *Metadata, error) {
+	contents, meta, err := PullLayers(ctx, opts, url, identities)
+	if err != nil {
+		return "", meta, err
+	}
+
+	content := JoinContents(contents)
+	if meta.Checksum != fmt.Sprintf("%x", sha256.Sum256([]byte(content))) {
+		return "", nil, fmt.Errorf("checksum mismatch")
+	}
+
+	return content, meta, nil
+}
+
+// PullLayers downloads the named layers of the artifact, when no names are specified all the layers are returned.
+// The content of the named layers is verified against the checksum recorded in the layer annotations.
+func PullLayers(ctx context.Context, opts Options, url string, identities []age.Identity, names ...string) ([]Content, *Metadata, error) {
 	ref, err := name.ParseReference(url)
 	if err != nil {
-		return "", nil, fmt.Errorf("parsing refernce failed: %w", err)
+		return nil, nil, fmt.Errorf("parsing refernce failed: %w", err)
 	}
 
-	img, err := crane.Pull(url, craneOptions(ctx)...)
+	src := opts.MirrorURL(url)
+	img, err := crane.Pull(src, opts.craneOptions(ctx, src, registryHost(url))...)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	manifest, err := img.Manifest()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	digest, err := img.Digest()
 	if err != nil {
-		return "", nil, fmt.Errorf("parsing digest failed: %w", err)
+		return nil, nil, fmt.Errorf("parsing digest failed: %w", err)
 	}
 
 	meta, err := GetMetadata(manifest.Annotations)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 	meta.Digest = ref.Context().Digest(digest.String()).String()
 
+	if meta.Mode == SourceMode {
+		return nil, meta, ErrSourceArtifact
+	}
+
 	if meta.Encrypted != "" && len(identities) < 1 {
-		return "", meta, fmt.Errorf("encrypted artifact, you need to supply a private key for decryption")
+		return nil, meta, fmt.Errorf("encrypted artifact, you need to supply a private key for decryption")
 	}
 
 	layers, err := img.Layers()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	if len(layers) < 1 {
-		return "", nil, fmt.Errorf("no layers found in image")
+		return nil, nil, fmt.Errorf("no layers found in image")
 	}
 
-	blob, err := layers[0].Uncompressed()
-	if err != nil {
-		return "", nil, err
+	selected := make(map[string]bool, len(names))
+	for _, n := range names {
+		selected[n] = false
 	}
 
-	content, err := untarContent(blob)
-	if err != nil {
-		return "", nil, err
+	if meta.Mode == BundleMode && len(names) == 0 {
+		var variants []string
+		for _, desc := range manifest.Layers {
+			variants = append(variants, layerName(desc))
+		}
+		return nil, meta, fmt.Errorf("%w, variants: %s", ErrBundleArtifact, strings.Join(variants, ", "))
 	}
 
-	if meta.Encrypted == AgeEncryptionVersion && len(identities) > 0 {
-		plainContent, err := decrypt([]byte(content), identities)
+	var contents []Content
+	for i, layer := range layers {
+		desc := manifest.Layers[i]
+		if !isManifestsLayer(desc) {
+			continue
+		}
+
+		layerTitle := layerName(desc)
+		if len(names) > 0 {
+			if _, ok := selected[layerTitle]; !ok {
+				continue
+			}
+			selected[layerTitle] = true
+		}
+
+		blob, err := layer.Uncompressed()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		content, err := untarContent(blob)
 		if err != nil {
-			return "", nil, fmt.Errorf("failed to decrypt content: %w", err)
+			return nil, nil, err
+		}
+
+		if meta.Encrypted == AgeEncryptionVersion && len(identities) > 0 {
+			plainContent, err := decrypt([]byte(content), identities)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decrypt content: %w", err)
+			}
+			content = string(plainContent)
 		}
-		content = string(plainContent)
+
+		if checksum, ok := desc.Annotations[ChecksumAnnotation]; ok {
+			if checksum != fmt.Sprintf("%x", sha256.Sum256([]byte(content))) {
+				return nil, nil, fmt.Errorf("checksum mismatch for layer %s", layerTitle)
+			}
+		}
+
+		contents = append(contents, Content{Name: layerTitle, Data: []byte(content)})
 	}
 
-	if meta.Checksum != fmt.Sprintf("%x", sha256.Sum256([]byte(content))) {
-		return "", nil, fmt.Errorf("checksum mismatch")
+	for n, found := range selected {
+		if !found {
+			return nil, meta, fmt.Errorf("layer '%s' not found", n)
+		}
 	}
 
-	return content, meta, nil
+	return contents, meta, nil
 }
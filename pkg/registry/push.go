@@ -30,7 +30,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 )
 
-func Push(ctx context.Context, url string, data []byte, meta *Metadata, recipients []age.Recipient) (string, error) {
+func Push(ctx context.Context, opts Options, url string, data []byte, meta *Metadata, recipients []age.Recipient) (string, error) {
 	ref, err := name.ParseReference(url)
 	if err != nil {
 		return "", fmt.Errorf("parsing refernce failed: %w", err)
@@ -67,7 +67,7 @@ func Push(ctx context.Context, url string, data []byte, meta *Metadata, recipien
 
 	img = mutate.Annotations(img, meta.ToAnnotations()).(gcrv1.Image)
 
-	if err := crane.Push(img, url, craneOptions(ctx)...); err != nil {
+	if err := crane.Push(img, url, opts.craneOptions(ctx, url, registryHost(url))...); err != nil {
 		return "", fmt.Errorf("pushing image failed: %w", err)
 	}
 
@@ -0,0 +1,258 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// SBOMArtifactType is the artifact type of the SPDX documents attached to artifacts.
+	SBOMArtifactType = "application/spdx+json"
+	// AttestationArtifactType is the artifact type of the in-toto statements attached to artifacts.
+	AttestationArtifactType = "application/vnd.in-toto+json"
+
+	emptyConfigMediaType = "application/vnd.oci.empty.v1+json"
+)
+
+// Referrer describes an artifact attached to another artifact through the OCI subject field.
+type Referrer struct {
+	ArtifactType string            `json:"artifactType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// ociDescriptor is an OCI content descriptor including the artifactType field
+// which is missing from the go-containerregistry descriptor.
+type ociDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Subject       *ociDescriptor    `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// rawManifest implements remote.Taggable for manifests that are marshaled by hand.
+type rawManifest struct {
+	data      []byte
+	mediaType types.MediaType
+}
+
+func (m rawManifest) RawManifest() ([]byte, error) {
+	return m.data, nil
+}
+
+func (m rawManifest) MediaType() (types.MediaType, error) {
+	return m.mediaType, nil
+}
+
+// Attach uploads the data as an OCI artifact of the given type that refers to the specified artifact.
+// The referrer is recorded in the referrers tag schema index, as defined by the OCI distribution spec
+// for registries without the referrers API, so that it can be discovered with Referrers.
+func Attach(ctx context.Context, opts Options, url string, artifactType string, data []byte, annotations map[string]string) (string, error) {
+	o := crane.GetOptions(opts.craneOptions(ctx, url, registryHost(url))...)
+	ref, err := name.ParseReference(url, o.Name...)
+	if err != nil {
+		return "", fmt.Errorf("parsing refernce failed: %w", err)
+	}
+	repo := ref.Context()
+	remoteOpts := o.Remote
+
+	subject, err := remote.Head(ref, remoteOpts...)
+	if err != nil {
+		return "", fmt.Errorf("fetching subject failed: %w", err)
+	}
+
+	config := static.NewLayer([]byte("{}"), emptyConfigMediaType)
+	layer := static.NewLayer(data, types.MediaType(artifactType))
+	var descriptors []ociDescriptor
+	for _, l := range []gcrv1.Layer{config, layer} {
+		if err := remote.WriteLayer(repo, l, remoteOpts...); err != nil {
+			return "", fmt.Errorf("uploading blob failed: %w", err)
+		}
+		d, err := layerDescriptor(l)
+		if err != nil {
+			return "", err
+		}
+		descriptors = append(descriptors, d)
+	}
+
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     string(types.OCIManifestSchema1),
+		ArtifactType:  artifactType,
+		Config:        descriptors[0],
+		Layers:        descriptors[1:],
+		Subject: &ociDescriptor{
+			MediaType: string(subject.MediaType),
+			Digest:    subject.Digest.String(),
+			Size:      subject.Size,
+		},
+		Annotations: annotations,
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	digest, size, err := gcrv1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+
+	digestRef := repo.Digest(digest.String())
+	if err := remote.Put(digestRef, rawManifest{data: raw, mediaType: types.OCIManifestSchema1}, remoteOpts...); err != nil {
+		return "", fmt.Errorf("pushing referrer failed: %w", err)
+	}
+
+	index, err := getReferrersIndex(repo, subject.Digest, remoteOpts)
+	if err != nil {
+		return "", err
+	}
+	index.Manifests = append(index.Manifests, ociDescriptor{
+		MediaType:    string(types.OCIManifestSchema1),
+		ArtifactType: artifactType,
+		Digest:       digest.String(),
+		Size:         size,
+		Annotations:  annotations,
+	})
+	rawIndex, err := json.Marshal(index)
+	if err != nil {
+		return "", err
+	}
+	if err := remote.Put(referrersTag(repo, subject.Digest), rawManifest{data: rawIndex, mediaType: types.OCIImageIndex}, remoteOpts...); err != nil {
+		return "", fmt.Errorf("updating referrers index failed: %w", err)
+	}
+
+	return digestRef.String(), nil
+}
+
+// Referrers returns the artifacts attached to the specified artifact.
+func Referrers(ctx context.Context, opts Options, url string) ([]Referrer, error) {
+	host := registryHost(url)
+	url = opts.MirrorURL(url)
+	o := crane.GetOptions(opts.craneOptions(ctx, url, host)...)
+	ref, err := name.ParseReference(url, o.Name...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing refernce failed: %w", err)
+	}
+	remoteOpts := o.Remote
+
+	subject, err := remote.Head(ref, remoteOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching subject failed: %w", err)
+	}
+
+	index, err := getReferrersIndex(ref.Context(), subject.Digest, remoteOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Referrer
+	for _, m := range index.Manifests {
+		result = append(result, Referrer{
+			ArtifactType: m.ArtifactType,
+			Digest:       m.Digest,
+			Size:         m.Size,
+			Annotations:  m.Annotations,
+		})
+	}
+	return result, nil
+}
+
+// getReferrersIndex fetches the referrers tag schema index of the subject, an empty index is returned if not found.
+func getReferrersIndex(repo name.Repository, subject gcrv1.Hash, opts []remote.Option) (*ociIndex, error) {
+	index := &ociIndex{
+		SchemaVersion: 2,
+		MediaType:     string(types.OCIImageIndex),
+		Manifests:     []ociDescriptor{},
+	}
+
+	desc, err := remote.Get(referrersTag(repo, subject), opts...)
+	if err != nil {
+		if isNotFound(err) {
+			return index, nil
+		}
+		return nil, fmt.Errorf("fetching referrers index failed: %w", err)
+	}
+
+	if err := json.Unmarshal(desc.Manifest, index); err != nil {
+		return nil, fmt.Errorf("parsing referrers index failed: %w", err)
+	}
+	return index, nil
+}
+
+// isNotFound returns true if the registry responded with 404 e.g. the tag doesn't exist.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
+
+// referrersTag returns the tag of the referrers index in the format '<alg>-<hex>'.
+func referrersTag(repo name.Repository, subject gcrv1.Hash) name.Tag {
+	return repo.Tag(strings.Replace(subject.String(), ":", "-", 1))
+}
+
+func layerDescriptor(l gcrv1.Layer) (ociDescriptor, error) {
+	digest, err := l.Digest()
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	size, err := l.Size()
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	mediaType, err := l.MediaType()
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	return ociDescriptor{
+		MediaType: string(mediaType),
+		Digest:    digest.String(),
+		Size:      size,
+	}, nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ResolveURL parses the OCI URL and returns it in the format '<domain>/<org>/<repo>:<tag>'.
+// When the tag is a semantic version constraint e.g. 'oci://docker.io/user/repo:^1.2',
+// or when the semver expression is not empty, the URL is resolved to the newest
+// tag from the repository that matches the constraint. The digest references are returned as they are.
+func ResolveURL(ctx context.Context, opts Options, ociURL string, semverExp string) (string, error) {
+	if IsDigest(ociURL) {
+		if semverExp != "" {
+			return "", fmt.Errorf("semver '%s' can't be used with the digest reference '%s'", semverExp, ociURL)
+		}
+		return ParseURL(ociURL)
+	}
+
+	if semverExp == "" {
+		url, err := ParseURL(ociURL)
+		if err == nil {
+			return url, nil
+		}
+
+		repo, exp, ok := splitTag(strings.TrimPrefix(ociURL, URLPrefix))
+		if !ok || !strings.HasPrefix(ociURL, URLPrefix) {
+			return "", err
+		}
+		if _, cErr := semver.NewConstraint(exp); cErr != nil {
+			return "", err
+		}
+		ociURL = URLPrefix + repo
+		semverExp = exp
+	}
+
+	c, err := semver.NewConstraint(semverExp)
+	if err != nil {
+		return "", fmt.Errorf("semver '%s' parse error: %w", semverExp, err)
+	}
+
+	repo, err := ParseRepositoryURL(ociURL)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := List(ctx, opts, repo)
+	if err != nil {
+		return "", fmt.Errorf("listing tags of %s failed: %w", repo, err)
+	}
+
+	var latest *semver.Version
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("no tag matching semver '%s' found in %s", semverExp, repo)
+	}
+
+	return fmt.Sprintf("%s:%s", repo, latest.Original()), nil
+}
+
+// splitTag splits the reference into the repository and the tag.
+func splitTag(ref string) (string, string, bool) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return "", "", false
+	}
+	return ref[:i], ref[i+1:], true
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// SourceLayerMediaType is the media type of the layer that contains a kustomization directory.
+const SourceLayerMediaType = "application/vnd.kustomizer.source.v1.tar+gzip"
+
+// ErrSourceArtifact is returned when pulling the manifests of an artifact that contains kustomize sources.
+var ErrSourceArtifact = errors.New("the artifact contains kustomize sources, the sources must be pulled to a directory")
+
+// PushSource packages the given directory in a tarball layer and pushes the artifact to the registry.
+// The metadata checksum is set to the SHA-256 of the tarball.
+func PushSource(ctx context.Context, opts Options, url string, dir string, meta *Metadata) (string, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	data, err := tarDirectory(dir)
+	if err != nil {
+		return "", fmt.Errorf("packaging %s failed: %w", dir, err)
+	}
+	meta.Mode = SourceMode
+	meta.Checksum = fmt.Sprintf("%x", sha256.Sum256(data))
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, tarball.WithMediaType(SourceLayerMediaType))
+	if err != nil {
+		return "", fmt.Errorf("creating layer failed: %w", err)
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: layer,
+		Annotations: map[string]string{
+			LayerTitleAnnotation: filepath.Base(filepath.Clean(dir)),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("appeding content failed: %w", err)
+	}
+
+	img = mutate.Annotations(img, meta.ToAnnotations()).(gcrv1.Image)
+
+	if err := crane.Push(img, url, opts.craneOptions(ctx, url, registryHost(url))...); err != nil {
+		return "", fmt.Errorf("pushing image failed: %w", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("parsing digest failed: %w", err)
+	}
+
+	return ref.Context().Digest(digest.String()).String(), nil
+}
+
+// PullSource downloads an artifact pushed with PushSource and extracts the kustomization directory to dir,
+// after verifying the tarball against the checksum recorded in the artifact metadata.
+func PullSource(ctx context.Context, opts Options, url string, dir string) (*Metadata, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	src := opts.MirrorURL(url)
+	img, err := crane.Pull(src, opts.craneOptions(ctx, src, registryHost(url))...)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing digest failed: %w", err)
+	}
+
+	meta, err := GetMetadata(manifest.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	meta.Digest = ref.Context().Digest(digest.String()).String()
+
+	if meta.Mode != SourceMode {
+		return nil, fmt.Errorf("the artifact does not contain kustomize sources")
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, layer := range layers {
+		if manifest.Layers[i].MediaType != SourceLayerMediaType {
+			continue
+		}
+
+		blob, err := layer.Uncompressed()
+		if err != nil {
+			return nil, err
+		}
+		defer blob.Close()
+
+		data, err := io.ReadAll(blob)
+		if err != nil {
+			return nil, err
+		}
+
+		if meta.Checksum != fmt.Sprintf("%x", sha256.Sum256(data)) {
+			return nil, fmt.Errorf("checksum mismatch")
+		}
+
+		if err := untarDirectory(bytes.NewReader(data), dir); err != nil {
+			return nil, err
+		}
+		return meta, nil
+	}
+
+	return nil, fmt.Errorf("no source layer found in image")
+}
+
+// tarDirectory returns a reproducible tarball of the regular files in the directory,
+// the file modes and timestamps are normalised so that the same content results in the same digest.
+func tarDirectory(dir string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		header := &tar.Header{
+			Name:     filepath.ToSlash(rel),
+			Mode:     0600,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// untarDirectory extracts the regular files from the tarball to dir, rejecting paths outside dir.
+func untarDirectory(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		case header == nil || header.Typeflag != tar.TypeReg:
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path in tarball: %s", header.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+}
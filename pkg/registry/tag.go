@@ -24,13 +24,13 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
-func Tag(ctx context.Context, url, tag string) (string, error) {
+func Tag(ctx context.Context, opts Options, url, tag string) (string, error) {
 	ref, err := name.ParseReference(url)
 	if err != nil {
 		return "", fmt.Errorf("parsing refernce failed: %w", err)
 	}
 
-	if err := crane.Tag(url, tag, craneOptions(ctx)...); err != nil {
+	if err := crane.Tag(url, tag, opts.craneOptions(ctx, url, registryHost(url))...); err != nil {
 		return "", err
 	}
 
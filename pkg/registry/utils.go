@@ -30,7 +30,7 @@ const URLPrefix = "oci://"
 
 func ParseURL(ociURL string) (string, error) {
 	if !strings.HasPrefix(ociURL, URLPrefix) {
-		return "", fmt.Errorf("URL must be in format 'oci://<domain>/<org>/<repo>:<tag>'")
+		return "", fmt.Errorf("URL must be in format 'oci://<domain>/<org>/<repo>:<tag>' or 'oci://<domain>/<org>/<repo>@sha256:<digest>'")
 	}
 
 	url := strings.TrimPrefix(ociURL, URLPrefix)
@@ -40,6 +40,14 @@ func ParseURL(ociURL string) (string, error) {
 	return url, nil
 }
 
+// IsDigest returns true if the OCI URL references the artifact by digest
+// e.g. 'oci://docker.io/user/repo@sha256:<digest>', the variant fragment is ignored.
+func IsDigest(ociURL string) bool {
+	base, _ := SplitVariant(ociURL)
+	_, err := name.NewDigest(strings.TrimPrefix(base, URLPrefix))
+	return err == nil
+}
+
 func ParseRepositoryURL(ociURL string) (string, error) {
 	if !strings.HasPrefix(ociURL, URLPrefix) {
 		return "", fmt.Errorf("URL must be in format 'oci://<domain>/<org>/<repo>'")
@@ -54,8 +62,10 @@ func ParseRepositoryURL(ociURL string) (string, error) {
 	return fmt.Sprintf("%s/%s", ref.Context().RegistryStr(), ref.Context().RepositoryStr()), nil
 }
 
-func craneOptions(ctx context.Context) []crane.Option {
-	return []crane.Option{
+// craneOptions returns the options for the registry operations on the given image or repository URL,
+// the explicit credentials are sent only to the given host, unless the options are scoped to another registry.
+func (o Options) craneOptions(ctx context.Context, url string, credsHost string) []crane.Option {
+	opts := []crane.Option{
 		crane.WithContext(ctx),
 		crane.WithUserAgent("kustomizer/v2"),
 		crane.WithPlatform(&gcrv1.Platform{
@@ -64,4 +74,17 @@ func craneOptions(ctx context.Context) []crane.Option {
 			OSVersion:    "v2",
 		}),
 	}
+
+	opts = append(opts, crane.WithAuthFromKeychain(o.keychain(credsHost)))
+
+	insecure := o.IsInsecure(url)
+	if insecure {
+		opts = append(opts, crane.Insecure)
+	}
+
+	if t := o.httpTransport(insecure); t != nil {
+		opts = append(opts, crane.WithTransport(t))
+	}
+
+	return opts
 }
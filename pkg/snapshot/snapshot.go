@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package snapshot contains utilities for capturing the in-cluster state of Kubernetes objects
+// before they are applied and for restoring that state when an apply fails.
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Snapshot holds copies of the in-cluster objects captured before an apply.
+type Snapshot struct {
+	// objects is the list of objects that are about to be applied.
+	objects []*unstructured.Unstructured
+
+	// existing maps the object IDs to their in-cluster copies,
+	// the objects not present on the cluster are missing from this map.
+	existing map[string]*unstructured.Unstructured
+}
+
+// Capture retrieves the in-cluster copies of the given objects, the custom resources
+// whose definition is not yet installed on the cluster are treated as absent.
+func Capture(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured) (*Snapshot, error) {
+	s := &Snapshot{
+		objects:  objects,
+		existing: make(map[string]*unstructured.Unstructured),
+	}
+
+	for _, object := range objects {
+		existingObject := &unstructured.Unstructured{}
+		existingObject.SetGroupVersionKind(object.GroupVersionKind())
+		err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject)
+		if err != nil {
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(object), err)
+		}
+		s.existing[ssa.FmtUnstructured(object)] = existingObject
+	}
+
+	return s, nil
+}
+
+// Restore reapplies the captured copies of the objects that existed before the apply
+// and deletes the objects that were created afterwards.
+func (s *Snapshot) Restore(ctx context.Context, resMgr *ssa.ResourceManager, opts ssa.ApplyOptions) (*ssa.ChangeSet, error) {
+	changeSet := ssa.NewChangeSet()
+
+	objects := make([]*unstructured.Unstructured, len(s.objects))
+	copy(objects, s.objects)
+	sort.Sort(sort.Reverse(ssa.SortableUnstructureds(objects)))
+
+	var errors string
+	for _, object := range objects {
+		existingObject, ok := s.existing[ssa.FmtUnstructured(object)]
+		if !ok {
+			change, err := s.deleteCreated(ctx, resMgr, object)
+			if err != nil {
+				errors += err.Error() + ";"
+			} else if change != nil {
+				changeSet.Add(*change)
+			}
+			continue
+		}
+
+		change, err := resMgr.Apply(ctx, sanitize(existingObject), opts)
+		if err != nil {
+			errors += err.Error() + ";"
+			continue
+		}
+		changeSet.Add(*change)
+	}
+
+	if errors != "" {
+		return changeSet, fmt.Errorf("restore failed, errors: %s", errors)
+	}
+
+	return changeSet, nil
+}
+
+func (s *Snapshot) deleteCreated(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured) (*ssa.ChangeSetEntry, error) {
+	createdObject := &unstructured.Unstructured{}
+	createdObject.SetGroupVersionKind(object.GroupVersionKind())
+	err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), createdObject)
+	if err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	return resMgr.Delete(ctx, object, ssa.DefaultDeleteOptions())
+}
+
+// sanitize removes the server-side generated fields from the given object.
+func sanitize(object *unstructured.Unstructured) *unstructured.Unstructured {
+	o := object.DeepCopy()
+	o.SetResourceVersion("")
+	o.SetUID("")
+	o.SetGeneration(0)
+	o.SetSelfLink("")
+	o.SetManagedFields(nil)
+	unstructured.RemoveNestedField(o.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(o.Object, "status")
+	return o
+}